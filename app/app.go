@@ -21,7 +21,9 @@ type App struct {
 	dryRun              bool
 	testArgs            *Array[string]
 	genArgsFlag         bool
+	explainArgsFlag     bool
 	argsFile            Path
+	argsEnvPrefix       string
 	operDataClassArgs   DataClass
 	errorMessage        []any
 	operWithJsonArgs    OperWithJsonArgs
@@ -37,13 +39,14 @@ func NewApp() *App {
 }
 
 const (
-	ClArgVerbose  = "verbose"
-	ClArgVersion  = "version"
-	ClArgDryrun   = "dryrun"
-	ClArgGenArgs  = "gen-args"
-	ClArgArgsFile = "args"
-	ClIDE         = "ide"
-	ClStartDir    = "startdir"
+	ClArgVerbose     = "verbose"
+	ClArgVersion     = "version"
+	ClArgDryrun      = "dryrun"
+	ClArgGenArgs     = "gen-args"
+	ClArgExplainArgs = "explain-args"
+	ClArgArgsFile    = "args"
+	ClIDE            = "ide"
+	ClStartDir       = "startdir"
 )
 
 func (a *App) CmdLineArgs() *CmdLineArgs {
@@ -60,6 +63,7 @@ func (a *App) CmdLineArgs() *CmdLineArgs {
 	ca.Add(ClArgVerbose).Desc("Verbose messages").ShortName("v")
 	ca.Add(ClArgVersion).Desc("Display version number").ShortName("n")
 	ca.Add(ClArgGenArgs).Desc("Generate args for operation").ShortName("g")
+	ca.Add(ClArgExplainArgs).Desc("Show where the value of each argument came from")
 	ca.Add(ClArgArgsFile).SetString().Desc("Specify arguments file (json)")
 	ca.Add(ClStartDir).SetString().Desc("Directory to start within").ShortName("S")
 
@@ -119,6 +123,13 @@ func (a *App) AddTestArgs(args string) *App {
 	return a
 }
 
+// Have environment variables with a prefix (e.g. "MYAPP_") override values in the arguments file;
+// see ConfigOverlay.AddEnvironment
+func (a *App) SetArgsEnvPrefix(prefix string) *App {
+	a.argsEnvPrefix = prefix
+	return a
+}
+
 func (a *App) HasTestArgs() bool {
 	return a.testArgs != nil
 }
@@ -210,6 +221,7 @@ func (a *App) auxStart() {
 		a.operDataClassArgs = a.operWithJsonArgs.GetArguments()
 		CheckArg(a.operDataClassArgs != nil, "No arguments returned by oper")
 		a.genArgsFlag = c.Get(ClArgGenArgs)
+		a.explainArgsFlag = c.Get(ClArgExplainArgs)
 		var path = NewPathOrEmptyM(c.GetString(ClArgArgsFile))
 
 		if path.Empty() {
@@ -235,7 +247,7 @@ func (a *App) auxStart() {
 	if a.error() {
 		return
 	}
-	if a.genArgsFlag {
+	if a.genArgsFlag || a.explainArgsFlag {
 		return
 	}
 	var unusedArgs = c.UnusedExtraArgs()
@@ -338,7 +350,7 @@ func (a *App) processArgs() {
 			var data = a.operDataClassArgs
 			// Get default arguments by parsing an empty map
 			defaultArgs := data.Parse(NewJSMap())
-			Pr(defaultArgs)
+			Pr(SharedMigrationRegistry().ToVersionedJson(defaultArgs))
 		} else {
 			Pr("Unavailable for this operation")
 		}
@@ -362,8 +374,9 @@ func (a *App) compileDataArgs() {
 	// Start with default arguments
 	var operArgs = a.operDataClassArgs
 	//pr("...default arguments:", INDENT, operArgs)
+	overlay := NewConfigOverlay().AddDataClass("defaults", operArgs)
 
-	// Replace with args file, if there was one
+	// Merge in the args file, if there was one
 	if a.argsFile.NonEmpty() {
 		argsFile := a.argsFile
 
@@ -389,11 +402,38 @@ func (a *App) compileDataArgs() {
 				return
 			}
 		}
-		argsJSMap := JSMapFromFileIfExistsM(argsFile)
-		operArgs = operArgs.Parse(argsJSMap)
+		// Args files are edited by hand, so parse them in relaxed mode
+		argsJSMap := NewJSMap()
+		if argsFile.Exists() {
+			argsJSMap = JSMapFromFileRelaxedM(argsFile)
+			// Bring an args file written for an older version of the arguments up to date
+			migrated, err := SharedMigrationRegistry().Migrate(operArgs, argsJSMap)
+			if err != nil {
+				a.SetError("Problem with arguments file:", argsFile, INDENT, err)
+				return
+			}
+			argsJSMap = migrated.AsJSMap()
+			// Check the file's arguments against the schema, if there is one, before they are merged with
+			// the defaults (which would supply any missing keys)
+			if x, ok := oper.(OperWithArgsSchema); ok {
+				if err := x.ArgsSchema().Check(argsJSMap); err != nil {
+					a.SetError("Problem with arguments file:", argsFile, INDENT, err)
+					return
+				}
+			}
+		}
+		overlay.AddLayer(argsFile.String(), argsJSMap)
 	}
 
-	var js = operArgs.ToJson().(*JSMapStruct)
+	if a.argsEnvPrefix != "" {
+		if err := overlay.AddEnvironment(a.argsEnvPrefix); err != nil {
+			a.SetError("Problem with arguments from environment:", INDENT, err)
+			return
+		}
+	}
+
+	var js = overlay.Result()
+	overrides := NewJSMap()
 
 	// While a next arg exists, and matches one of the keys in the args map,
 	// parse a key/value pair as an override
@@ -462,22 +502,38 @@ func (a *App) compileDataArgs() {
 			return
 		}
 
-		// Replace the value within the json map
-		js.Put(key, newVal)
+		overrides.Put(key, newVal)
 	}
 	if a.handleCmdLineArgsError() {
 		return
 	}
+	overlay.AddLayer("command line", overrides)
 
-	// Re-parse the arguments from the (possibly modified) jsmap
+	// Check the values of the fully merged arguments against the schema, if there is one
+	if x, ok := oper.(OperWithArgsSchema); ok {
+		if err := x.ArgsSchema().CheckValues(overlay.Result()); err != nil {
+			a.SetError("Problem with arguments:", INDENT, err)
+			return
+		}
+	}
 
-	operArgs = operArgs.Parse(js)
+	if a.explainArgsFlag {
+		Pr(overlay.Explain())
+		return
+	}
+
+	operArgs = overlay.Parse(operArgs)
 	//pr("...modified arguments:", INDENT, operArgs)
 
 	a.operDataClassArgs = operArgs // Replace the previous version, though I don't think this field is used past this point
 	oper.AcceptArguments(operArgs)
 }
 
+// Was the dry run flag given on the command line?
+func (a *App) DryRun() bool {
+	return a.dryRun
+}
+
 func (a *App) SetError(message ...any) {
 	if !a.error() {
 		a.errorMessage = message
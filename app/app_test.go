@@ -0,0 +1,67 @@
+package app
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	. "github.com/jpsember/golang-base/gen/sample"
+	"github.com/jpsember/golang-base/jt"
+	"strings"
+	"testing"
+)
+
+type schemaOper struct {
+	args DemoConfig
+}
+
+func (o *schemaOper) UserCommand() string              { return "demo" }
+func (o *schemaOper) GetHelp() (summary, usage string) { return "Demonstrates an arguments schema", "" }
+func (o *schemaOper) Perform(app *App)                 {}
+func (o *schemaOper) GetArguments() DataClass          { return DefaultDemoConfig }
+func (o *schemaOper) ArgsFileMustExist() bool          { return true }
+func (o *schemaOper) AcceptArguments(args DataClass)   { o.args = args.(DemoConfig) }
+func (o *schemaOper) ArgsSchema() JsonSchema {
+	return NewJsonSchema(JSMapFromStringM(`
+{"type":"object",
+ "required":["name"],
+ "properties": {
+   "name":{"type":"string","minLength":1},
+   "target":{"type":"integer","maximum":100},
+ },
+}`))
+}
+
+// Run the oper with an arguments file and additional command line arguments, returning the error (if any)
+func runSchemaOper(t *testing.T, argsFile string, args string) (*schemaOper, string) {
+	file := NewPathM(t.TempDir()).JoinM("demo-args.json")
+	file.WriteStringM(argsFile)
+	oper := &schemaOper{}
+	a := NewApp()
+	a.RegisterOper(oper)
+	a.AddTestArgs("--args " + file.String() + " " + args)
+	a.auxStart()
+	if a.error() {
+		return oper, ToString(a.errorMessage...)
+	}
+	return oper, ""
+}
+
+func TestArgsSchemaRequiredKey(t *testing.T) {
+	j := jt.New(t)
+	oper, msg := runSchemaOper(t, `{"name":"fred"}`, "")
+	j.AssertEqual(msg, "")
+	j.AssertEqual(oper.args.Name(), "fred")
+	j.AssertEqual(oper.args.Target(), 12)
+
+	// The name has a default, but it must appear in the arguments file
+	_, msg = runSchemaOper(t, `{"target":5}`, "")
+	j.AssertTrue(strings.Contains(msg, "/name: required key is missing"), msg)
+}
+
+func TestArgsSchemaMergedValues(t *testing.T) {
+	j := jt.New(t)
+	_, msg := runSchemaOper(t, `{"name":"fred","target":500}`, "")
+	j.AssertTrue(strings.Contains(msg, "/target:"), msg)
+
+	// The command line is checked too, once merged
+	_, msg = runSchemaOper(t, `{"name":"fred"}`, "target 500")
+	j.AssertTrue(strings.Contains(msg, "/target:"), msg)
+}
@@ -32,3 +32,12 @@ type OperWithCmdLineArgs interface {
 	// Handle remaining arguments.  See web_server.go for an example
 	ProcessArgs(c *CmdLineArgs)
 }
+
+// A subtype of OperWithJsonArgs that supplies a JSON schema.  The arguments file must satisfy it,
+// and the values of the arguments must still satisfy it once the defaults, arguments file,
+// environment and command line have been merged
+type OperWithArgsSchema interface {
+	OperWithJsonArgs
+	// Get the schema for the arguments
+	ArgsSchema() JsonSchema
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	. "github.com/jpsember/golang-base/base"
+)
+
+// An operation that upgrades the stored json files for a DataClass to its current schema version
+// (see MigrationRegistry), e.g.
+//
+//	app.RegisterOper(NewUpgradeOper("upgrade-users", DefaultUser, "*.user.json"))
+//
+// The files to upgrade are those within the directories given on the command line whose names match
+// the glob (see MigrationRegistry.UpgradeDirectory); with --dryrun, the files needing an upgrade are
+// listed but not modified.
+type UpgradeOper struct {
+	BaseObject
+	command  string
+	parser   DataClass
+	glob     string
+	registry MigrationRegistry
+	dirs     []Path
+}
+
+func NewUpgradeOper(command string, parser DataClass, glob string) *UpgradeOper {
+	oper := &UpgradeOper{command: command, parser: parser, glob: glob, registry: SharedMigrationRegistry()}
+	oper.ProvideName(oper)
+	return oper
+}
+
+// Use a registry other than the shared one
+func (oper *UpgradeOper) WithRegistry(registry MigrationRegistry) *UpgradeOper {
+	oper.registry = registry
+	return oper
+}
+
+func (oper *UpgradeOper) UserCommand() string {
+	return oper.command
+}
+
+func (oper *UpgradeOper) GetHelp() (summary, usage string) {
+	summary = "Upgrades stored json files to the current schema version."
+	usage = "<directory>..."
+	return
+}
+
+func (oper *UpgradeOper) ProcessArgs(c *CmdLineArgs) {
+	for c.HasNextArg() {
+		oper.dirs = append(oper.dirs, NewPathM(c.NextArg()))
+	}
+}
+
+func (oper *UpgradeOper) Perform(app *App) {
+	if len(oper.dirs) == 0 {
+		app.SetError("no directories specified")
+		return
+	}
+	for _, dir := range oper.dirs {
+		files, err := oper.registry.UpgradeDirectory(oper.parser, dir, oper.glob, app.DryRun())
+		for _, f := range files {
+			Pr(Ternary(app.DryRun(), "would upgrade:", "upgraded:"), f)
+		}
+		if err != nil {
+			app.SetError(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package base
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Creating, extracting, and summarizing zip and tar.gz archives.  The format is determined by
+// the archive's extension: ".zip", or ".tar.gz" / ".tgz".
+
+type ArchiveFormat int
+
+const (
+	ArchiveZip ArchiveFormat = iota
+	ArchiveTarGz
+)
+
+// Determine an archive's format from its extension
+func ArchiveFormatOf(archive Path) (ArchiveFormat, error) {
+	name := strings.ToLower(archive.Base())
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, nil
+	}
+	return 0, Error("unsupported archive type:", archive)
+}
+
+// Create an archive holding the contents of a directory, including its subdirectories (even empty ones)
+func (dir Path) ArchiveTo(archive Path) error {
+	if !dir.IsDir() {
+		return Error("no such directory:", dir)
+	}
+	return CreateArchive(archive, NewDirWalk(dir).WithRecurse().WithDirNames())
+}
+
+func (dir Path) ArchiveToM(archive Path) {
+	CheckOk(dir.ArchiveTo(archive))
+}
+
+// Create an archive holding the files (and directories, if WithDirNames() is in effect) returned by a DirWalk.
+// The entries are named by their paths relative to the walk's start directory, and are sorted by name.
+func CreateArchive(archive Path, walk *DirWalk) (err error) {
+	format, err := ArchiveFormatOf(archive)
+	if err != nil {
+		return
+	}
+
+	var files []Path
+	if err = catchPanicAsError(func() { files = walk.Files() }); err != nil {
+		return
+	}
+	prefix := walk.startDirectory.String() + "/"
+	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+
+	out, err := os.Create(archive.AsNonEmptyString())
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(archive.String())
+		}
+	}()
+
+	var writer archiveWriter
+	if format == ArchiveZip {
+		writer = newZipArchiveWriter(out)
+	} else {
+		writer = newTarGzArchiveWriter(out)
+	}
+
+	fsys := walk.fileSystem
+	for _, file := range files {
+		name := filepath.ToSlash(strings.TrimPrefix(file.String(), prefix))
+		var info fs.FileInfo
+		info, err = fsys.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			// A broken symlink
+			err = nil
+			continue
+		}
+		if err == nil {
+			if info.IsDir() {
+				err = writer.addDir(name, info)
+			} else {
+				err = addArchiveFile(writer, fsys, file, name, info)
+			}
+		}
+		if err != nil {
+			_ = writer.close()
+			_ = out.Close()
+			return Error("problem archiving:", file, INDENT, err)
+		}
+	}
+	err = writer.close()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func CreateArchiveM(archive Path, walk *DirWalk) {
+	CheckOk(CreateArchive(archive, walk))
+}
+
+func addArchiveFile(writer archiveWriter, fsys FileSystem, file Path, name string, info fs.FileInfo) error {
+	in, err := fsys.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writer.addFile(name, info, in)
+}
+
+// Extract an archive's contents into a directory, which is created if necessary.  The entries are all
+// examined before anything is written; if any is unsafe (e.g. its name is absolute, or contains '..', or leads
+// through a symbolic link already within the directory, so it would be written outside the directory) or is
+// something other than a file or directory (e.g. a symbolic link), an error is returned and nothing is extracted.
+func (archive Path) ExtractArchive(dir Path) error {
+	err := forEachArchiveEntry(archive, false, func(entry archiveEntry, content io.Reader) error {
+		_, err := archiveEntryTarget(dir, entry)
+		return err
+	})
+	if err == nil {
+		err = dir.MkDirs()
+	}
+	if err != nil {
+		return err
+	}
+	return forEachArchiveEntry(archive, true, func(entry archiveEntry, content io.Reader) error {
+		target, _ := archiveEntryTarget(dir, entry)
+		if target.Empty() {
+			return nil
+		}
+		if entry.isDir {
+			return os.MkdirAll(target.String(), entry.mode.Perm()|0700)
+		}
+		return extractArchiveFile(target, entry, content)
+	})
+}
+
+func (archive Path) ExtractArchiveM(dir Path) {
+	CheckOk(archive.ExtractArchive(dir))
+}
+
+// Determine where an entry is to be extracted to; returns an empty path for entries that are to be skipped
+// (e.g. the root directory)
+func archiveEntryTarget(dir Path, entry archiveEntry) (Path, error) {
+	if !entry.isDir && !entry.isFile {
+		return EmptyPath, Error("unsupported entry in archive:", Quoted(entry.name))
+	}
+	name := path.Clean(strings.ReplaceAll(entry.name, `\`, "/"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || filepath.VolumeName(name) != "" {
+		return EmptyPath, Error("unsafe entry in archive:", Quoted(entry.name))
+	}
+	if name == "." {
+		return EmptyPath, nil
+	}
+	target := dir.JoinM(filepath.FromSlash(name))
+	if err := checkNoSymlinksWithin(dir, target); err != nil {
+		return EmptyPath, Error("unsafe entry in archive:", Quoted(entry.name), INDENT, err)
+	}
+	return target, nil
+}
+
+// Return an error if any existing component of a path within a directory (other than the directory itself)
+// is a symbolic link, so that writing to the path might affect something outside the directory
+func checkNoSymlinksWithin(dir Path, target Path) error {
+	rel, err := filepath.Rel(dir.String(), target.String())
+	if err != nil {
+		return err
+	}
+	p := dir.String()
+	for _, seg := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, seg)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			// Nothing further exists yet
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return Error("symbolic link within directory:", p)
+		}
+	}
+	return nil
+}
+
+func extractArchiveFile(target Path, entry archiveEntry, content io.Reader) (err error) {
+	if err = os.MkdirAll(filepath.Dir(target.String()), 0755); err != nil {
+		return
+	}
+	out, err := os.OpenFile(target.String(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, entry.mode.Perm())
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(out, content)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// The mode might have been restricted by the umask, or the file might have existed already
+		err = os.Chmod(target.String(), entry.mode.Perm())
+	}
+	if err == nil && !entry.modTime.IsZero() {
+		err = os.Chtimes(target.String(), entry.modTime, entry.modTime)
+	}
+	return
+}
+
+// Summarize an archive's contents as a JSMap, with a nested map for each directory, and for each file, a
+// map holding its size and the SHA-256 hash of its content
+func (archive Path) ArchiveSummary() (JSMap, error) {
+	result := NewJSMap()
+	err := forEachArchiveEntry(archive, true, func(entry archiveEntry, content io.Reader) error {
+		name := strings.Trim(path.Clean("/"+strings.ReplaceAll(entry.name, `\`, "/")), "/")
+		if name == "" {
+			return nil
+		}
+		segments := strings.Split(name, "/")
+		parent := result
+		for _, seg := range segments[:len(segments)-1] {
+			parent = archiveSummarySubdir(parent, seg)
+		}
+		last := segments[len(segments)-1]
+		if entry.isDir {
+			archiveSummarySubdir(parent, last)
+			return nil
+		}
+		summary := NewJSMap().Put("size", entry.size)
+		if entry.isFile {
+			hash := sha256.New()
+			if _, err := io.Copy(hash, content); err != nil {
+				return err
+			}
+			summary.Put("sha256", hex.EncodeToString(hash.Sum(nil)))
+		} else {
+			summary.Put("type", "other")
+		}
+		parent.Put(last, summary)
+		return nil
+	})
+	return result, err
+}
+
+func (archive Path) ArchiveSummaryM() JSMap {
+	return CheckOkWith(archive.ArchiveSummary())
+}
+
+func archiveSummarySubdir(parent JSMap, name string) JSMap {
+	if m, ok := parent.OptAny(name).(JSMap); ok {
+		return m
+	}
+	m := NewJSMap()
+	parent.Put(name, m)
+	return m
+}
+
+// ---------------------------------------------------------------------------------------
+// Reading archives
+// ---------------------------------------------------------------------------------------
+
+type archiveEntry struct {
+	name    string
+	isDir   bool
+	isFile  bool
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// Call a function for each entry within an archive.  If withContent is false, the function is passed a nil reader.
+func forEachArchiveEntry(archive Path, withContent bool, fn func(entry archiveEntry, content io.Reader) error) error {
+	format, err := ArchiveFormatOf(archive)
+	if err != nil {
+		return err
+	}
+	if format == ArchiveZip {
+		err = forEachZipEntry(archive, withContent, fn)
+	} else {
+		err = forEachTarGzEntry(archive, fn)
+	}
+	if err != nil {
+		err = Error("problem reading archive:", archive, INDENT, err)
+	}
+	return err
+}
+
+func forEachZipEntry(archive Path, withContent bool, fn func(entry archiveEntry, content io.Reader) error) error {
+	r, err := zip.OpenReader(archive.AsNonEmptyString())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		info := f.FileInfo()
+		entry := archiveEntry{name: f.Name, isDir: info.IsDir(), isFile: info.Mode().IsRegular(), mode: info.Mode(),
+			size: int64(f.UncompressedSize64), modTime: f.Modified}
+		if !withContent || !entry.isFile {
+			err = fn(entry, nil)
+		} else {
+			err = callWithZipContent(f, entry, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func callWithZipContent(f *zip.File, entry archiveEntry, fn func(entry archiveEntry, content io.Reader) error) error {
+	content, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return fn(entry, content)
+}
+
+func forEachTarGzEntry(archive Path, fn func(entry archiveEntry, content io.Reader) error) error {
+	file, err := os.Open(archive.AsNonEmptyString())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info := header.FileInfo()
+		entry := archiveEntry{name: header.Name, isDir: header.Typeflag == tar.TypeDir,
+			isFile: header.Typeflag == tar.TypeReg, mode: info.Mode(), size: header.Size, modTime: header.ModTime}
+		if err = fn(entry, r); err != nil {
+			return err
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------------------
+// Writing archives
+// ---------------------------------------------------------------------------------------
+
+type archiveWriter interface {
+	addDir(name string, info fs.FileInfo) error
+	addFile(name string, info fs.FileInfo, content io.Reader) error
+	close() error
+}
+
+type zipArchiveWriter struct {
+	w *zip.Writer
+}
+
+func newZipArchiveWriter(out io.Writer) archiveWriter {
+	return &zipArchiveWriter{w: zip.NewWriter(out)}
+}
+
+func (z *zipArchiveWriter) addDir(name string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	header.Method = zip.Store
+	_, err = z.w.CreateHeader(header)
+	return err
+}
+
+func (z *zipArchiveWriter) addFile(name string, info fs.FileInfo, content io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	out, err := z.w.CreateHeader(header)
+	if err == nil {
+		_, err = io.Copy(out, content)
+	}
+	return err
+}
+
+func (z *zipArchiveWriter) close() error {
+	return z.w.Close()
+}
+
+type tarGzArchiveWriter struct {
+	gz *gzip.Writer
+	w  *tar.Writer
+}
+
+func newTarGzArchiveWriter(out io.Writer) archiveWriter {
+	gz := gzip.NewWriter(out)
+	return &tarGzArchiveWriter{gz: gz, w: tar.NewWriter(gz)}
+}
+
+func (t *tarGzArchiveWriter) addDir(name string, info fs.FileInfo) error {
+	return t.w.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: int64(info.Mode().Perm()),
+		ModTime: info.ModTime()})
+}
+
+func (t *tarGzArchiveWriter) addFile(name string, info fs.FileInfo, content io.Reader) error {
+	err := t.w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: int64(info.Mode().Perm()),
+		Size: info.Size(), ModTime: info.ModTime()})
+	if err == nil {
+		_, err = io.Copy(t.w, content)
+	}
+	return err
+}
+
+func (t *tarGzArchiveWriter) close() error {
+	err := t.w.Close()
+	if gzErr := t.gz.Close(); err == nil {
+		err = gzErr
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package base_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"os"
+	"testing"
+)
+
+func archiveSource(j jt.JTest) Path {
+	dir := j.GetTestResultsDir().JoinM("source")
+	dir.JoinM("photos/2023").MkDirsM()
+	dir.JoinM("empty").MkDirsM()
+	dir.JoinM("readme.txt").WriteStringM("hello")
+	dir.JoinM("run.sh").WriteStringM("#!/bin/sh\necho hi\n")
+	dir.JoinM("run.sh").ChmodM(0755)
+	dir.JoinM("photos/a.jpg").WriteStringM("jpeg data")
+	dir.JoinM("photos/2023/b.jpg").WriteStringM("more jpeg data")
+	return dir
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	source := archiveSource(j)
+	for _, name := range []string{"backup.zip", "backup.tar.gz"} {
+		archive := j.GetTestResultsDir().JoinM(name)
+		source.ArchiveToM(archive)
+
+		summary := archive.ArchiveSummaryM()
+		j.AssertEqual(summary.GetMap("photos").GetMap("2023").GetMap("b.jpg").GetInt("size"), 14)
+		j.AssertEqual(summary.GetMap("empty").Size(), 0)
+
+		target := j.GetTestResultsDir().JoinM("extracted_" + name)
+		archive.ExtractArchiveM(target)
+		j.AssertEqual(target.JoinM("photos/2023/b.jpg").ReadStringM(), "more jpeg data")
+		j.AssertTrue(target.JoinM("empty").IsDir())
+		info := CheckOkWith(os.Stat(target.JoinM("run.sh").String()))
+		j.AssertEqual(info.Mode().Perm(), os.FileMode(0755))
+
+		// Archiving the extracted files should produce the same contents
+		copyArchive := j.GetTestResultsDir().JoinM("copy_" + name)
+		target.ArchiveToM(copyArchive)
+		j.AssertEqual(copyArchive.ArchiveSummaryM().String(), summary.String())
+	}
+}
+
+func TestArchiveFromDirWalk(t *testing.T) {
+	j := jt.New(t)
+	fsys := NewMemFileSystem()
+	root := NewPathM("/site")
+	CheckOk(fsys.MkDirs(root.JoinM("images/icons")))
+	CheckOk(fsys.WriteFile(root.JoinM("index.html"), []byte("<html>"), 0644))
+	CheckOk(fsys.WriteFile(root.JoinM("images/logo.png"), []byte("png"), 0644))
+	CheckOk(fsys.WriteFile(root.JoinM("images/icons/x.png"), []byte("icon"), 0644))
+	CheckOk(fsys.WriteFile(root.JoinM("images/notes.txt"), []byte("notes"), 0644))
+
+	// Don't write the archive to the results directory, since its timestamps differ each time
+	archive := NewPathM(t.TempDir()).JoinM("images.tgz")
+	CreateArchiveM(archive, NewDirWalk(root).WithFileSystem(fsys).WithRecurse().IncludeGlobs("*.png").WithDirNames())
+	j.AssertMessage(archive.ArchiveSummaryM())
+}
+
+func TestExtractArchiveRejectsTraversal(t *testing.T) {
+	j := jt.New(t)
+	archive := j.GetTestResultsDir().JoinM("evil.zip")
+	out := CheckOkWith(os.Create(archive.String()))
+	w := zip.NewWriter(out)
+	for _, name := range []string{"fine.txt", "../evil.txt"} {
+		f := CheckOkWith(w.Create(name))
+		CheckOkWith(f.Write([]byte(name)))
+	}
+	CheckOk(w.Close())
+	CheckOk(out.Close())
+
+	target := j.GetTestResultsDir().JoinM("target")
+	err := archive.ExtractArchive(target)
+	j.AssertTrue(err != nil)
+	j.AssertFalse(target.JoinM("fine.txt").Exists())
+	j.AssertFalse(j.GetTestResultsDir().JoinM("evil.txt").Exists())
+}
+
+func TestExtractArchiveRejectsSymlinks(t *testing.T) {
+	j := jt.New(t)
+	archive := j.GetTestResultsDir().JoinM("links.tar.gz")
+	out := CheckOkWith(os.Create(archive.String()))
+	gz := gzip.NewWriter(out)
+	w := tar.NewWriter(gz)
+	CheckOk(w.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "passwd", Linkname: "/etc/passwd"}))
+	CheckOk(w.Close())
+	CheckOk(gz.Close())
+	CheckOk(out.Close())
+
+	target := j.GetTestResultsDir().JoinM("target")
+	j.AssertTrue(archive.ExtractArchive(target) != nil)
+	j.AssertFalse(target.JoinM("passwd").Exists())
+}
+
+func TestExtractArchiveRejectsSymlinkedDirectory(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	archive := dir.JoinM("sub.zip")
+	out := CheckOkWith(os.Create(archive.String()))
+	w := zip.NewWriter(out)
+	for _, name := range []string{"fine.txt", "sub/x.txt"} {
+		f := CheckOkWith(w.Create(name))
+		CheckOkWith(f.Write([]byte(name)))
+	}
+	CheckOk(w.Close())
+	CheckOk(out.Close())
+
+	// Extract into an existing directory containing a link to a directory outside of it
+	target := dir.JoinM("target")
+	target.MkDirsM()
+	outside := dir.JoinM("outside")
+	outside.MkDirsM()
+	CheckOk(os.Symlink(outside.String(), target.JoinM("sub").String()))
+
+	j.AssertTrue(archive.ExtractArchive(target) != nil)
+	j.AssertFalse(outside.JoinM("x.txt").Exists())
+	j.AssertFalse(target.JoinM("fine.txt").Exists())
+}
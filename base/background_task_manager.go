@@ -1,17 +1,120 @@
 package base
 
 import (
+	"context"
+	"math/rand"
+	"sort"
 	"sync"
+	"time"
 )
 
-type taskInfoStruct struct {
-	key               string
-	lastExecutionTime int64
-	period            int64
-	task              func()
+// A task performed by a BackgroundTaskManager, according to a schedule
+type BackgroundTaskStruct struct {
+	key       string
+	schedule  TaskSchedule
+	task      func(ctx context.Context) error
+	jitter    time.Duration
+	runOnStop bool
+	immediate bool
+
+	// The following are guarded by the manager's lock
+	manager BackgroundTaskManager
+	paused  bool
+	running bool
+	nextRun time.Time
+	stats   TaskStats
+}
+
+type BackgroundTask = *BackgroundTaskStruct
+
+// Construct a task to be added to a BackgroundTaskManager.  The task is passed a context that is cancelled
+// when the manager is stopped; if it returns an error (or panics), the failure is recorded in its
+// statistics and reported to the manager's error handler.
+func NewBackgroundTask(key string, schedule TaskSchedule, task func(ctx context.Context) error) BackgroundTask {
+	CheckArg(schedule != nil && task != nil)
+	return &BackgroundTaskStruct{key: key, schedule: schedule, task: task}
+}
+
+// Delay each run by a random amount, up to a maximum; this keeps tasks on the same schedule
+// (possibly in different processes) from running at the same moment
+func (t BackgroundTask) WithJitter(maxDelay time.Duration) BackgroundTask {
+	t.assertNotAdded()
+	CheckArg(maxDelay >= 0)
+	t.jitter = maxDelay
+	return t
+}
+
+// Run the task once more when the manager is stopped (e.g. to flush buffered results)
+func (t BackgroundTask) WithRunOnStop() BackgroundTask {
+	t.assertNotAdded()
+	t.runOnStop = true
+	return t
+}
+
+// Run the task as soon as it is added, rather than waiting for its schedule
+func (t BackgroundTask) WithRunImmediately() BackgroundTask {
+	t.assertNotAdded()
+	t.immediate = true
+	return t
+}
+
+func (t BackgroundTask) Key() string {
+	return t.key
+}
+
+func (t BackgroundTask) assertNotAdded() {
+	CheckState(t.manager == nil, "task has already been added:", t.key)
+}
+
+// Run the task, converting any panic to an error
+func (t BackgroundTask) call(ctx context.Context) (err error) {
+	panicErr := catchPanicAsError(func() { err = t.task(ctx) })
+	if panicErr != nil {
+		err = Error("panic:", panicErr)
+	}
+	return
+}
+
+// Statistics for a task, e.g. for display on an admin page
+type TaskStats struct {
+	Key      string
+	Schedule string
+	Paused   bool
+	Running  bool
+	// The start of the most recent run, and how long it took
+	LastRun      time.Time
+	LastDuration time.Duration
+	NextRun      time.Time
+	Runs         int
+	Failures     int
+	// The error from the most recent failure
+	LastError string
+}
+
+func (s TaskStats) ToJson() JSMap {
+	m := NewJSMap()
+	m.Put("key", s.Key)
+	m.Put("schedule", s.Schedule)
+	m.Put("paused", s.Paused)
+	m.Put("running", s.Running)
+	if !s.LastRun.IsZero() {
+		m.Put("last_run", s.LastRun.Format(time.RFC3339))
+		m.Put("last_duration_ms", s.LastDuration.Milliseconds())
+	}
+	if !s.NextRun.IsZero() {
+		m.Put("next_run", s.NextRun.Format(time.RFC3339))
+	}
+	m.Put("runs", s.Runs)
+	m.Put("failures", s.Failures)
+	if s.LastError != "" {
+		m.Put("last_error", s.LastError)
+	}
+	return m
 }
 
-type taskInfo = *taskInfoStruct
+func (s TaskStats) String() string {
+	return s.ToJson().String()
+}
 
 const (
 	bgtaskmgrState_new = iota
@@ -21,9 +124,18 @@ const (
 )
 
 type BackgroundTaskManagerStruct struct {
-	state   int
-	taskMap map[string]taskInfo
-	lock    sync.RWMutex
+	BaseObject
+	state        int
+	taskMap      map[string]BackgroundTask
+	lock         sync.Mutex
+	tick         time.Duration
+	ctx          context.Context
+	cancel       context.CancelFunc
+	stopChan     chan struct{}
+	done         chan struct{}
+	running      sync.WaitGroup
+	errorHandler func(key string, err error)
+	rand         *rand.Rand
 }
 
 var shared = NewBackgroundTaskManager()
@@ -36,70 +148,246 @@ type BackgroundTaskManager = *BackgroundTaskManagerStruct
 
 func NewBackgroundTaskManager() BackgroundTaskManager {
 	t := &BackgroundTaskManagerStruct{
-		taskMap: make(map[string]taskInfo),
+		taskMap: make(map[string]BackgroundTask),
+		tick:    200 * time.Millisecond,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.errorHandler = func(key string, err error) {
+		Pr("Background task", Quoted(key), "failed:", err)
 	}
+	t.SetName("BackgroundTaskManager")
 	return t
 }
 
+// Set how often the manager checks for tasks that are due to run (default 200ms)
+func (b BackgroundTaskManager) WithTickInterval(interval time.Duration) BackgroundTaskManager {
+	CheckArg(interval > 0)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	CheckState(b.state == bgtaskmgrState_new, "manager already started")
+	b.tick = interval
+	return b
+}
+
+// Set the function that is called when a task fails (by default, the failure is printed)
+func (b BackgroundTaskManager) WithErrorHandler(handler func(key string, err error)) BackgroundTaskManager {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.errorHandler = handler
+	return b
+}
+
 func (b BackgroundTaskManager) Start() BackgroundTaskManager {
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	CheckState(b.state == bgtaskmgrState_new)
 	b.setState(bgtaskmgrState_started)
+	b.stopChan = make(chan struct{})
+	b.done = make(chan struct{})
 	go b.perform()
 	return b
 }
 
+// Stop the manager: cancel the context passed to the tasks, wait for any running tasks to finish,
+// and then run those tasks that are to run on stopping
 func (b BackgroundTaskManager) Stop() BackgroundTaskManager {
-	if b.state == bgtaskmgrState_started {
+	b.lock.Lock()
+	wasStarted := b.state == bgtaskmgrState_started
+	if wasStarted {
 		b.setState(bgtaskmgrState_stopping)
+		close(b.stopChan)
+	}
+	b.cancel()
+	b.lock.Unlock()
+
+	if wasStarted {
+		<-b.done
+		b.running.Wait()
 		b.executeTasksPriorToStopping()
 	}
+
+	b.lock.Lock()
 	b.setState(bgtaskmgrState_stopped)
+	b.lock.Unlock()
 	return b
 }
 
 func (b BackgroundTaskManager) executeTasksPriorToStopping() {
-	b.lock.RLock()
-	for _, v := range b.taskMap {
-		currTime := CurrentTimeMs()
-		v.lastExecutionTime = currTime
-		b.executeTask(v)
+	for _, t := range b.sortedTasks() {
+		if t.runOnStop {
+			b.lock.Lock()
+			t.running = true
+			b.lock.Unlock()
+			b.runTask(t, context.Background(), false)
+		}
 	}
-	b.lock.RUnlock()
 }
 
+// Add a task that runs every so many milliseconds, starting immediately, and once more when the manager stops.
+// If the manager hasn't been started, it is started.
 func (b BackgroundTaskManager) Add(key string, period int, task func()) BackgroundTaskManager {
+	return b.AddTask(NewBackgroundTask(key, EveryPeriod(time.Duration(period)*time.Millisecond),
+		func(ctx context.Context) error {
+			task()
+			return nil
+		}).WithRunImmediately().WithRunOnStop())
+}
+
+// Add a task; its key must be unique.  If the manager hasn't been started, it is started.
+func (b BackgroundTaskManager) AddTask(task BackgroundTask) BackgroundTaskManager {
+	if b.addTask(task) {
+		b.Start()
+	}
+	return b
+}
+
+// Add a task, and return true if the manager needs starting
+func (b BackgroundTaskManager) addTask(task BackgroundTask) bool {
 	b.lock.Lock()
 	defer b.lock.Unlock()
-	CheckState(!HasKey(b.taskMap, key))
-	b.taskMap[key] = &taskInfoStruct{
-		key:    key,
-		period: int64(period),
-		task:   task,
+	CheckState(!HasKey(b.taskMap, task.key), "duplicate task key:", task.key)
+	task.assertNotAdded()
+	task.manager = b
+	task.stats.Key = task.key
+	task.stats.Schedule = task.schedule.String()
+	if task.immediate {
+		task.nextRun = time.Now()
+	} else {
+		task.nextRun = b.nextRunTime(task, time.Now())
 	}
+	b.taskMap[task.key] = task
+	return b.state == bgtaskmgrState_new
+}
 
-	if b.state == bgtaskmgrState_new {
-		b.Start()
+// Remove a task; if it is running, it is allowed to finish.  Returns false if there is no such task.
+func (b BackgroundTaskManager) Remove(key string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	_, found := b.taskMap[key]
+	delete(b.taskMap, key)
+	return found
+}
+
+// Stop running a task until it is resumed; returns false if there is no such task
+func (b BackgroundTaskManager) Pause(key string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	t := b.taskMap[key]
+	if t != nil {
+		t.paused = true
 	}
-	return b
+	return t != nil
+}
+
+// Resume running a paused task, at the next time its schedule calls for; returns false if there is no such task
+func (b BackgroundTaskManager) Resume(key string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	t := b.taskMap[key]
+	if t != nil && t.paused {
+		t.paused = false
+		t.nextRun = b.nextRunTime(t, time.Now())
+	}
+	return t != nil
+}
+
+// Get the statistics for the tasks, sorted by key
+func (b BackgroundTaskManager) Stats() []TaskStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var result []TaskStats
+	for _, t := range b.taskMap {
+		s := t.stats
+		s.Paused = t.paused
+		s.Running = t.running
+		if !t.paused {
+			s.NextRun = t.nextRun
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func (b BackgroundTaskManager) sortedTasks() []BackgroundTask {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var result []BackgroundTask
+	for _, t := range b.taskMap {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].key < result[j].key })
+	return result
+}
+
+// Determine when a task is next to run; the lock must be held
+func (b BackgroundTaskManager) nextRunTime(t BackgroundTask, after time.Time) time.Time {
+	next := t.schedule.Next(after)
+	if t.jitter > 0 {
+		next = next.Add(time.Duration(b.rand.Int63n(int64(t.jitter))))
+	}
+	return next
 }
 
 func (b BackgroundTaskManager) perform() {
-	pr := PrIf("BackgroundTaskManager.perform", false)
-	for b.state != bgtaskmgrState_stopped {
-		pr("tick")
-		SleepMs(200)
-		b.lock.RLock()
-		for _, v := range b.taskMap {
-			currTime := CurrentTimeMs()
-			elapsed := currTime - v.lastExecutionTime
-			pr("currTime:", currTime, "lastExec:", v.lastExecutionTime, "elapsed:", elapsed)
-			if elapsed >= v.period {
-				v.lastExecutionTime = currTime
-				pr("executing task:", v.key)
-				b.executeTask(v)
-			}
+	defer close(b.done)
+	ticker := time.NewTicker(b.tick)
+	defer ticker.Stop()
+	for {
+		b.startDueTasks(time.Now())
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Start any tasks that are due to run, each in its own goroutine.  A task isn't started again while it is still running.
+func (b BackgroundTaskManager) startDueTasks(now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state != bgtaskmgrState_started {
+		return
+	}
+	for _, t := range b.taskMap {
+		if t.paused || t.running || now.Before(t.nextRun) {
+			continue
+		}
+		b.Log("executing task:", t.key)
+		t.running = true
+		b.running.Add(1)
+		go func(t BackgroundTask) {
+			defer b.running.Done()
+			b.runTask(t, b.ctx, true)
+		}(t)
+	}
+}
+
+func (b BackgroundTaskManager) runTask(t BackgroundTask, ctx context.Context, reschedule bool) {
+	start := time.Now()
+	err := t.call(ctx)
+
+	b.lock.Lock()
+	t.running = false
+	t.stats.LastRun = start
+	t.stats.LastDuration = time.Since(start)
+	t.stats.Runs++
+	if err != nil {
+		t.stats.Failures++
+		t.stats.LastError = err.Error()
+	}
+	if reschedule {
+		t.nextRun = b.nextRunTime(t, start)
+	}
+	handler := b.errorHandler
+	b.lock.Unlock()
+
+	if err != nil && handler != nil {
+		if handlerErr := catchPanicAsError(func() { handler(t.key, err) }); handlerErr != nil {
+			Pr("Caught panic in error handler for task:", t.key, handlerErr)
 		}
-		b.lock.RUnlock()
 	}
 }
 
@@ -108,10 +396,3 @@ func (b BackgroundTaskManager) setState(state int) {
 	pr("state changing from", b.state, "to", state)
 	b.state = state
 }
-
-func (b BackgroundTaskManager) executeTask(v taskInfo) {
-	defer CatchPanic(func() {
-		Pr("Caught panic executing task:", v.key)
-	})
-	v.task()
-}
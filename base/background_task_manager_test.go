@@ -0,0 +1,157 @@
+package base_test
+
+import (
+	"context"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var scheduleStart = time.Date(2023, time.July, 14, 9, 41, 30, 0, time.UTC)
+
+// Describe the next few times a schedule calls for
+func nextTimes(s TaskSchedule, count int) string {
+	var result []string
+	t := scheduleStart.In(time.Local)
+	for i := 0; i < count; i++ {
+		t = s.Next(t)
+		result = append(result, t.Format("Mon 2006-01-02 15:04"))
+	}
+	return strings.Join(result, ", ")
+}
+
+func TestCronSchedule(t *testing.T) {
+	j := jt.New(t)
+	// Use UTC, so the results don't depend on the local time zone
+	saved := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = saved }()
+
+	j.AssertEqual(nextTimes(ParseCronM("*/15 * * * *"), 3), "Fri 2023-07-14 09:45, Fri 2023-07-14 10:00, Fri 2023-07-14 10:15")
+	j.AssertEqual(nextTimes(ParseCronM("30 2 * * mon-wed"), 3), "Mon 2023-07-17 02:30, Tue 2023-07-18 02:30, Wed 2023-07-19 02:30")
+	j.AssertEqual(nextTimes(ParseCronM("0 0 1,15 * 5"), 3), "Sat 2023-07-15 00:00, Fri 2023-07-21 00:00, Fri 2023-07-28 00:00")
+	j.AssertEqual(nextTimes(ParseCronM("0 12 29 feb *"), 1), "Thu 2024-02-29 12:00")
+	j.AssertEqual(nextTimes(ParseCronM("@daily"), 2), "Sat 2023-07-15 00:00, Sun 2023-07-16 00:00")
+	j.AssertEqual(nextTimes(DailyAtM("17:00", "08:30"), 3), "Fri 2023-07-14 17:00, Sat 2023-07-15 08:30, Sat 2023-07-15 17:00")
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 31 2 *", "0 0 * foo *"} {
+		_, err := ParseCron(bad)
+		j.AssertTrue(err != nil, bad)
+	}
+}
+
+func newTestTaskManager() BackgroundTaskManager {
+	return NewBackgroundTaskManager().WithTickInterval(5 * time.Millisecond)
+}
+
+func TestBackgroundTaskFailures(t *testing.T) {
+	j := jt.New(t)
+	var lock sync.Mutex
+	var failures []string
+	m := newTestTaskManager().WithErrorHandler(func(key string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		failures = append(failures, key)
+	})
+	var good atomic.Int32
+	m.AddTask(NewBackgroundTask("panics", EveryPeriod(10*time.Millisecond), func(ctx context.Context) error {
+		panic("oops")
+	}))
+	m.AddTask(NewBackgroundTask("fails", EveryPeriod(10*time.Millisecond), func(ctx context.Context) error {
+		return Error("failed")
+	}))
+	m.AddTask(NewBackgroundTask("good", EveryPeriod(10*time.Millisecond), func(ctx context.Context) error {
+		good.Add(1)
+		return nil
+	}))
+	time.Sleep(100 * time.Millisecond)
+	m.Stop()
+
+	j.AssertTrue(good.Load() >= 3)
+	stats := m.Stats()
+	j.AssertEqual(stats[0].Key, "fails")
+	j.AssertEqual(stats[0].Failures, stats[0].Runs)
+	j.AssertEqual(stats[0].LastError, "failed")
+	j.AssertEqual(stats[1].Key, "good")
+	j.AssertEqual(stats[1].Failures, 0)
+	j.AssertTrue(stats[2].Failures >= 3)
+	j.AssertTrue(strings.Contains(stats[2].LastError, "oops"))
+	lock.Lock()
+	j.AssertEqual(len(failures), stats[0].Failures+stats[2].Failures)
+	lock.Unlock()
+}
+
+func TestBackgroundTaskPauseResumeRemove(t *testing.T) {
+	j := jt.New(t)
+	m := newTestTaskManager()
+	var count atomic.Int32
+	m.AddTask(NewBackgroundTask("count", EveryPeriod(5*time.Millisecond), func(ctx context.Context) error {
+		count.Add(1)
+		return nil
+	}))
+	time.Sleep(50 * time.Millisecond)
+	j.AssertTrue(m.Pause("count"))
+	time.Sleep(20 * time.Millisecond)
+	paused := count.Load()
+	time.Sleep(50 * time.Millisecond)
+	j.AssertEqual(count.Load(), paused)
+	j.AssertTrue(m.Stats()[0].Paused)
+
+	j.AssertTrue(m.Resume("count"))
+	time.Sleep(50 * time.Millisecond)
+	j.AssertTrue(count.Load() > paused)
+
+	j.AssertTrue(m.Remove("count"))
+	j.AssertFalse(m.Remove("count"))
+	j.AssertFalse(m.Pause("count"))
+	j.AssertEqual(len(m.Stats()), 0)
+	m.Stop()
+}
+
+func TestBackgroundTaskStopCancelsContext(t *testing.T) {
+	j := jt.New(t)
+	m := newTestTaskManager()
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	m.AddTask(NewBackgroundTask("long", EveryPeriod(time.Millisecond), func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			cancelled.Store(true)
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	}).WithRunImmediately())
+
+	var flushes atomic.Int32
+	m.Add("flush", 3600*1000, func() { flushes.Add(1) })
+
+	<-started
+	begin := time.Now()
+	m.Stop()
+	j.AssertTrue(time.Since(begin) < 2*time.Second)
+	j.AssertTrue(cancelled.Load())
+	// A task added with Add runs when it is added, and when the manager stops
+	j.AssertEqual(flushes.Load(), int32(2))
+}
+
+func TestBackgroundTaskJitter(t *testing.T) {
+	j := jt.New(t)
+	m := newTestTaskManager()
+	schedule := EveryPeriod(time.Hour)
+	before := time.Now()
+	m.AddTask(NewBackgroundTask("jittery", schedule, func(ctx context.Context) error { return nil }).
+		WithJitter(10 * time.Minute))
+	after := time.Now()
+	next := m.Stats()[0].NextRun
+	j.AssertFalse(next.Before(schedule.Next(before)))
+	j.AssertTrue(next.Before(schedule.Next(after).Add(10 * time.Minute)))
+	m.Stop()
+}
@@ -394,15 +394,24 @@ type DataClass interface {
 var regexpCache = &sync.Map{}
 
 func Regexp(expr string) *regexp.Regexp {
+	pat, err := CompileRegexp(expr)
+	CheckOk(err, "trouble compiling regexp:", Quoted(expr))
+	return pat
+}
+
+// Compile a regular expression (caching the result), returning an error if it is invalid
+func CompileRegexp(expr string) (*regexp.Regexp, error) {
 	value, ok := regexpCache.Load(expr)
 	if ok {
-		return value.(*regexp.Regexp)
+		return value.(*regexp.Regexp), nil
 	}
 
 	pat, err := regexp.Compile(expr)
-	CheckOk(err, "trouble compiling regexp:", Quoted(expr))
+	if err != nil {
+		return nil, err
+	}
 	regexpCache.Store(expr, pat)
-	return pat
+	return pat, nil
 }
 
 func JoinElementToList(obj any, list2 []any) []any {
@@ -581,7 +590,7 @@ func processAlertForMultipleSessions(info alertInfo) bool {
 	m.Put("r", currTime)
 	priorityAlertMap.Put(info.key, m)
 	if !testAlertState {
-		priorityAlertPersistPath.WriteStringM(priorityAlertMap.CompactString())
+		priorityAlertPersistPath.WriteStringAtomicM(priorityAlertMap.CompactString())
 	}
 	return true
 }
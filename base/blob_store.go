@@ -0,0 +1,294 @@
+package base
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The SHA-256 hash of a blob's content, as 64 lowercase hex digits
+type BlobHash string
+
+// Compute the hash of some content
+func BlobHashOf(content []byte) BlobHash {
+	sum := sha256.Sum256(content)
+	return BlobHash(hex.EncodeToString(sum[:]))
+}
+
+// Determine if a string is a well-formed hash
+func (h BlobHash) Valid() bool {
+	if len(h) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range h {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (h BlobHash) String() string {
+	return string(h)
+}
+
+// A content-addressable store of blobs, held in a directory.  Each blob is stored in a file named by
+// the hash of its content, within subdirectories named by the hash's first two pairs of digits
+// (e.g. "3f/a2/3fa2..."), so storing the same content twice stores it once.  Files are written to a
+// temporary directory and renamed into place, so a blob's file is always complete.
+//
+// Blobs aren't reference counted; instead, unused blobs are deleted by CollectGarbage, given a function
+// that identifies the blobs still in use (e.g. those referred to by database rows).
+type BlobStoreStruct struct {
+	BaseObject
+	dir    Path
+	tmpDir Path
+	// Held (for reading) while writing blobs, and (for writing) while collecting garbage
+	lock sync.RWMutex
+}
+
+type BlobStore = *BlobStoreStruct
+
+// Open the blob store within a directory, creating the directory if necessary
+func OpenBlobStore(dir Path) (BlobStore, error) {
+	s := &BlobStoreStruct{dir: dir.AssertNonEmpty(), tmpDir: dir.JoinM("tmp")}
+	s.SetName("BlobStore")
+	if err := s.tmpDir.MkDirs(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func OpenBlobStoreM(dir Path) BlobStore {
+	return CheckOkWith(OpenBlobStore(dir))
+}
+
+// Get the path of the file holding a blob
+func (s BlobStore) BlobPath(hash BlobHash) Path {
+	CheckArg(hash.Valid(), "invalid blob hash:", Quoted(hash.String()))
+	h := hash.String()
+	return s.dir.JoinM(h[0:2]).JoinM(h[2:4]).JoinM(h)
+}
+
+// Store a blob, returning its hash
+func (s BlobStore) Put(content []byte) (BlobHash, error) {
+	return s.Write(bytes.NewReader(content))
+}
+
+func (s BlobStore) PutM(content []byte) BlobHash {
+	return CheckOkWith(s.Put(content))
+}
+
+// Store a blob read from a reader, returning its hash
+func (s BlobStore) Write(r io.Reader) (hash BlobHash, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	temp, err := os.CreateTemp(s.tmpDir.String(), "blob*")
+	if err != nil {
+		return
+	}
+	tempName := temp.Name()
+	defer func() {
+		// If the temporary file was renamed into place, this has no effect
+		_ = os.Remove(tempName)
+	}()
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(temp, hasher), r)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	hash = BlobHash(hex.EncodeToString(hasher.Sum(nil)))
+	target := s.BlobPath(hash)
+
+	if target.Exists() {
+		// We already have this blob; touch it, so it survives any garbage collection that is waiting for the lock
+		now := time.Now()
+		err = os.Chtimes(target.String(), now, now)
+		return
+	}
+	if err = target.Parent().MkDirs(); err == nil {
+		err = os.Chmod(tempName, 0444)
+	}
+	if err == nil {
+		err = os.Rename(tempName, target.String())
+	}
+	return
+}
+
+func (s BlobStore) WriteM(r io.Reader) BlobHash {
+	return CheckOkWith(s.Write(r))
+}
+
+// Determine if a blob is in the store
+func (s BlobStore) Has(hash BlobHash) bool {
+	return hash.Valid() && s.BlobPath(hash).Exists()
+}
+
+// Get the size of a blob, in bytes
+func (s BlobStore) Size(hash BlobHash) (int64, error) {
+	if !hash.Valid() {
+		return 0, Error("invalid blob hash:", Quoted(hash.String()))
+	}
+	info, err := os.Stat(s.BlobPath(hash).String())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Open a blob for reading
+func (s BlobStore) Open(hash BlobHash) (io.ReadCloser, error) {
+	if !hash.Valid() {
+		return nil, Error("invalid blob hash:", Quoted(hash.String()))
+	}
+	return os.Open(s.BlobPath(hash).String())
+}
+
+// Read a blob's content
+func (s BlobStore) Get(hash BlobHash) ([]byte, error) {
+	if !hash.Valid() {
+		return nil, Error("invalid blob hash:", Quoted(hash.String()))
+	}
+	return s.BlobPath(hash).ReadBytes()
+}
+
+func (s BlobStore) GetM(hash BlobHash) []byte {
+	return CheckOkWith(s.Get(hash))
+}
+
+// Delete a blob; does nothing if it is not in the store
+func (s BlobStore) Delete(hash BlobHash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.delete(hash)
+}
+
+func (s BlobStore) delete(hash BlobHash) error {
+	err := os.Remove(s.BlobPath(hash).String())
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Get the hashes of the blobs in the store, sorted
+func (s BlobStore) List() ([]BlobHash, error) {
+	var result []BlobHash
+	err := filepath.WalkDir(s.dir.String(), func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == s.tmpDir.String() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		hash := BlobHash(d.Name())
+		if hash.Valid() && s.BlobPath(hash).String() == p {
+			result = append(result, hash)
+		}
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, err
+}
+
+func (s BlobStore) ListM() []BlobHash {
+	return CheckOkWith(s.List())
+}
+
+// Verify that a blob's content matches its hash
+func (s BlobStore) Verify(hash BlobHash) error {
+	r, err := s.Open(hash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, r); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != hash.String() {
+		return Error("blob", hash, "is corrupt; its content has hash", actual)
+	}
+	return nil
+}
+
+// Verify every blob in the store, returning those whose content doesn't match their hash
+func (s BlobStore) VerifyAll() ([]BlobHash, error) {
+	hashes, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var corrupt []BlobHash
+	for _, h := range hashes {
+		if err := s.Verify(h); err != nil {
+			if os.IsNotExist(err) {
+				// Deleted since we listed it
+				continue
+			}
+			s.Log(err)
+			corrupt = append(corrupt, h)
+		}
+	}
+	return corrupt, nil
+}
+
+// Delete the blobs that aren't in use, returning their hashes.  A blob is in use if the isLive function
+// returns true for it, or if it was stored (or stored again) within minAge of now; the latter protects blobs
+// whose references haven't been recorded yet.  Any stale temporary files are deleted as well.
+func (s BlobStore) CollectGarbage(isLive func(hash BlobHash) bool, minAge time.Duration) ([]BlobHash, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hashes, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-minAge)
+	var deleted []BlobHash
+	for _, h := range hashes {
+		if isLive(h) {
+			continue
+		}
+		info, err := os.Stat(s.BlobPath(h).String())
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err = s.delete(h); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, h)
+	}
+
+	// Since we hold the lock, no temporary files are in use
+	entries, err := os.ReadDir(s.tmpDir.String())
+	if err == nil {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), "blob") {
+				_ = os.Remove(s.tmpDir.JoinM(e.Name()).String())
+			}
+		}
+	}
+	return deleted, err
+}
+
+func (s BlobStore) CollectGarbageM(isLive func(hash BlobHash) bool, minAge time.Duration) []BlobHash {
+	return CheckOkWith(s.CollectGarbage(isLive, minAge))
+}
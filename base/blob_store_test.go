@@ -0,0 +1,84 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlobStorePutAndGet(t *testing.T) {
+	j := jt.New(t)
+	s := OpenBlobStoreM(NewPathM(t.TempDir()))
+
+	h := s.PutM([]byte("hello"))
+	j.AssertEqual(h, BlobHashOf([]byte("hello")))
+	j.AssertEqual(h.String(), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	j.AssertTrue(strings.HasSuffix(s.BlobPath(h).String(), "/2c/f2/"+h.String()))
+	j.AssertEqual(string(s.GetM(h)), "hello")
+
+	// Storing the same content again (here, by streaming it) stores one copy
+	h2 := s.WriteM(strings.NewReader("hello"))
+	j.AssertEqual(h2, h)
+	h3 := s.PutM([]byte("world"))
+	j.AssertEqual(len(s.ListM()), 2)
+	j.AssertEqual(CheckOkWith(s.Size(h3)), int64(5))
+
+	r := CheckOkWith(s.Open(h3))
+	content := CheckOkWith(io.ReadAll(r))
+	CheckOk(r.Close())
+	j.AssertEqual(string(content), "world")
+
+	CheckOk(s.Delete(h))
+	j.AssertFalse(s.Has(h))
+	j.AssertTrue(s.Has(h3))
+	_, err := s.Get(BlobHash("bogus"))
+	j.AssertTrue(err != nil)
+}
+
+func TestBlobStoreVerify(t *testing.T) {
+	j := jt.New(t)
+	s := OpenBlobStoreM(NewPathM(t.TempDir()))
+	good := s.PutM([]byte("good"))
+	bad := s.PutM([]byte("bad"))
+	CheckOk(s.Verify(good))
+
+	file := s.BlobPath(bad)
+	file.ChmodM(0644)
+	file.WriteStringM("tampered")
+	j.AssertTrue(s.Verify(bad) != nil)
+	corrupt := CheckOkWith(s.VerifyAll())
+	j.AssertEqual(len(corrupt), 1)
+	j.AssertEqual(corrupt[0], bad)
+}
+
+func TestBlobStoreCollectGarbage(t *testing.T) {
+	j := jt.New(t)
+	s := OpenBlobStoreM(NewPathM(t.TempDir()))
+	live := s.PutM([]byte("live"))
+	dead := s.PutM([]byte("dead"))
+
+	isLive := func(h BlobHash) bool { return h == live }
+
+	// Recently stored blobs are kept
+	j.AssertEqual(len(s.CollectGarbageM(isLive, time.Hour)), 0)
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, h := range []BlobHash{live, dead} {
+		CheckOk(os.Chtimes(s.BlobPath(h).String(), old, old))
+	}
+	deleted := s.CollectGarbageM(isLive, time.Hour)
+	j.AssertEqual(len(deleted), 1)
+	j.AssertEqual(deleted[0], dead)
+	j.AssertTrue(s.Has(live))
+	j.AssertFalse(s.Has(dead))
+
+	// Storing a blob again protects it from collection
+	again := s.PutM([]byte("live"))
+	CheckOk(os.Chtimes(s.BlobPath(again).String(), old, old))
+	s.PutM([]byte("live"))
+	j.AssertEqual(len(s.CollectGarbageM(func(h BlobHash) bool { return false }, time.Hour)), 0)
+}
@@ -0,0 +1,463 @@
+package base
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A compact binary encoding of JSEntities, using CBOR (RFC 8949).
+//
+// Byte arrays (JBytes) are stored natively as CBOR byte strings, and decoded as JBytes (which print
+// using EncodeBase64, as they would have before encoding).  Strings are always stored as CBOR text,
+// even if they hold base64-encoded byte arrays, so they decode as the JStrings they were.
+//
+// The encoding is deterministic: map keys are written in the order given by OrderedKeys, integers
+// and lengths use their shortest forms, and floats are written as 32 bits if that is exact.
+// JNumbers are stored as integers, bignums (tags 2, 3) or decimal fractions (tag 4); except for
+// negative zero, which only a float can represent.
+
+type CborError struct {
+	// Offset within the data where the problem was found
+	Offset  int
+	Problem string
+}
+
+func (e *CborError) Error() string {
+	return "Problem decoding cbor at offset " + IntToString(e.Offset) + ": " + e.Problem
+}
+
+const (
+	cborMajorUnsigned = 0
+	cborMajorNegative = 1
+	cborMajorBytes    = 2
+	cborMajorText     = 3
+	cborMajorArray    = 4
+	cborMajorMap      = 5
+	cborMajorTag      = 6
+	cborMajorSimple   = 7
+
+	cborFalse      = 0xf4
+	cborTrue       = 0xf5
+	cborNull       = 0xf6
+	cborUndefined  = 0xf7
+	cborFloat16    = 0xf9
+	cborFloat32    = 0xfa
+	cborFloat64    = 0xfb
+	cborBreak      = 0xff
+	cborIndefinite = 31
+
+	cborTagPositiveBignum = 2
+	cborTagNegativeBignum = 3
+	cborTagDecimal        = 4
+
+	// Limit on the nesting of arrays and maps, to protect against malicious input
+	cborMaxDepth = 1000
+)
+
+// ---------------------------------------------------------------------------------------
+// Encoding
+// ---------------------------------------------------------------------------------------
+
+func EncodeCbor(e JSEntity) []byte {
+	return appendCbor(nil, e)
+}
+
+func appendCbor(b []byte, e JSEntity) []byte {
+	switch x := e.(type) {
+	case JSMap:
+		keys := x.OrderedKeys()
+		b = appendCborHead(b, cborMajorMap, uint64(len(keys)))
+		for _, k := range keys {
+			b = appendCborText(b, k)
+			b = appendCbor(b, x.wrappedMap[k])
+		}
+	case JSList:
+		b = appendCborHead(b, cborMajorArray, uint64(x.Length()))
+		for _, v := range x.wrappedList {
+			b = appendCbor(b, v)
+		}
+	case JString:
+		b = appendCborText(b, string(x))
+	case JBytes:
+		b = appendCborHead(b, cborMajorBytes, uint64(len(x)))
+		b = append(b, x...)
+	case JInteger:
+		b = appendCborInt(b, int64(x))
+	case JFloat:
+		b = appendCborFloat(b, float64(x))
+	case JNumber:
+		b = appendCborNumber(b, x)
+	case JBool:
+		b = append(b, Ternary(bool(x), byte(cborTrue), byte(cborFalse)))
+	case nil, JNull:
+		b = append(b, cborNull)
+	default:
+		BadArg("unsupported type:", Info(e))
+	}
+	return b
+}
+
+func appendCborHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+func appendCborText(b []byte, s string) []byte {
+	b = appendCborHead(b, cborMajorText, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendCborInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCborHead(b, cborMajorNegative, uint64(-1-v))
+	}
+	return appendCborHead(b, cborMajorUnsigned, uint64(v))
+}
+
+func appendCborFloat(b []byte, f float64) []byte {
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		return binary.BigEndian.AppendUint32(append(b, cborFloat32), math.Float32bits(f32))
+	}
+	return binary.BigEndian.AppendUint64(append(b, cborFloat64), math.Float64bits(f))
+}
+
+// Write an integer that may not fit in an int64, using a bignum if necessary
+func appendCborBigInt(b []byte, v *big.Int) []byte {
+	major := byte(cborMajorUnsigned)
+	magnitude := v
+	if v.Sign() < 0 {
+		// CBOR stores negative integers as -1 - n
+		major = cborMajorNegative
+		magnitude = new(big.Int).Sub(new(big.Int).Neg(v), big.NewInt(1))
+	}
+	if magnitude.IsUint64() {
+		return appendCborHead(b, major, magnitude.Uint64())
+	}
+	b = appendCborHead(b, cborMajorTag, uint64(Ternary(major == cborMajorUnsigned, cborTagPositiveBignum, cborTagNegativeBignum)))
+	bytes := magnitude.Bytes()
+	b = appendCborHead(b, cborMajorBytes, uint64(len(bytes)))
+	return append(b, bytes...)
+}
+
+func appendCborNumber(b []byte, n JNumber) []byte {
+	if r, err := n.BigRat(); err == nil && r.Sign() == 0 && strings.HasPrefix(string(n), "-") {
+		return appendCborFloat(b, math.Copysign(0, -1))
+	}
+	if v, err := n.BigInt(); err == nil {
+		return appendCborBigInt(b, v)
+	}
+	// Store as a decimal fraction: mantissa * 10^exponent
+	text := strings.TrimPrefix(string(n), "+")
+	var exponent int64
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		exponent = ParseInt64M(text[i+1:])
+		text = text[:i]
+	}
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		exponent -= int64(len(text) - i - 1)
+		text = text[:i] + text[i+1:]
+	}
+	mantissa, ok := new(big.Int).SetString(text, 10)
+	CheckState(ok, "can't parse number:", n)
+	b = appendCborHead(b, cborMajorTag, cborTagDecimal)
+	b = appendCborHead(b, cborMajorArray, 2)
+	b = appendCborInt(b, exponent)
+	return appendCborBigInt(b, mantissa)
+}
+
+// ---------------------------------------------------------------------------------------
+// Decoding
+// ---------------------------------------------------------------------------------------
+
+func DecodeCbor(data []byte) (result JSEntity, err error) {
+	d := &cborDecoder{data: data}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*CborError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e
+		}
+	}()
+	result = d.decode(0)
+	if d.cursor != len(data) {
+		d.fail("extra data following value")
+	}
+	return result, nil
+}
+
+func DecodeCborM(data []byte) JSEntity {
+	return CheckOkWith(DecodeCbor(data))
+}
+
+func JSMapFromCbor(data []byte) (JSMap, error) {
+	e, err := DecodeCbor(data)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := e.(JSMap)
+	if !ok {
+		return nil, Error("cbor value is not a map:", TypeOf(e))
+	}
+	return m, nil
+}
+
+func JSMapFromCborM(data []byte) JSMap {
+	return CheckOkWith(JSMapFromCbor(data))
+}
+
+type cborDecoder struct {
+	data   []byte
+	cursor int
+}
+
+func (d *cborDecoder) fail(problem ...any) {
+	panic(&CborError{Offset: d.cursor, Problem: ToString(problem...)})
+}
+
+func (d *cborDecoder) readBytes(n uint64) []byte {
+	if n > uint64(len(d.data)-d.cursor) {
+		d.fail("unexpected end of data")
+	}
+	result := d.data[d.cursor : d.cursor+int(n)]
+	d.cursor += int(n)
+	return result
+}
+
+func (d *cborDecoder) readByte() byte {
+	return d.readBytes(1)[0]
+}
+
+// Read an item's initial byte and argument (unless it has indefinite length)
+func (d *cborDecoder) readHead() (major byte, info byte, arg uint64, indefinite bool) {
+	initial := d.readByte()
+	major, info = initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		arg = uint64(d.readByte())
+	case info == 25:
+		arg = uint64(binary.BigEndian.Uint16(d.readBytes(2)))
+	case info == 26:
+		arg = uint64(binary.BigEndian.Uint32(d.readBytes(4)))
+	case info == 27:
+		arg = binary.BigEndian.Uint64(d.readBytes(8))
+	case info == cborIndefinite && major >= cborMajorBytes && major <= cborMajorMap:
+		indefinite = true
+	default:
+		d.cursor--
+		d.fail("invalid initial byte:", IntToString(int(initial)))
+	}
+	return
+}
+
+// Determine if the next byte is a 'break', and if so, skip it
+func (d *cborDecoder) readBreak() bool {
+	if d.cursor < len(d.data) && d.data[d.cursor] == cborBreak {
+		d.cursor++
+		return true
+	}
+	return false
+}
+
+// Make sure a container's declared length is plausible, since each element takes at least one byte
+func (d *cborDecoder) checkLength(n uint64, indefinite bool) int {
+	if !indefinite && n > uint64(len(d.data)-d.cursor) {
+		d.fail("length exceeds remaining data:", strconv.FormatUint(n, 10))
+	}
+	return int(n)
+}
+
+func (d *cborDecoder) decode(depth int) JSEntity {
+	if depth > cborMaxDepth {
+		d.fail("too deeply nested")
+	}
+	start := d.cursor
+	major, info, arg, indefinite := d.readHead()
+	switch major {
+	case cborMajorUnsigned:
+		if arg > math.MaxInt64 {
+			return JNumber(new(big.Int).SetUint64(arg).String())
+		}
+		return JInteger(arg)
+	case cborMajorNegative:
+		if arg > math.MaxInt64 {
+			v := new(big.Int).SetUint64(arg)
+			return JNumber(v.Sub(v.Neg(v), big.NewInt(1)).String())
+		}
+		return JInteger(-1 - int64(arg))
+	case cborMajorBytes:
+		return JBytes(append([]byte(nil), d.readString(cborMajorBytes, arg, indefinite)...))
+	case cborMajorText:
+		s := d.readString(cborMajorText, arg, indefinite)
+		if !utf8.Valid(s) {
+			d.cursor = start
+			d.fail("text string is not valid utf-8")
+		}
+		return JString(s)
+	case cborMajorArray:
+		list := NewJSList()
+		length := d.checkLength(arg, indefinite)
+		for i := 0; indefinite || i < length; i++ {
+			if indefinite && d.readBreak() {
+				break
+			}
+			list.wrappedList = append(list.wrappedList, d.decode(depth+1))
+		}
+		return list
+	case cborMajorMap:
+		m := NewJSMap()
+		length := d.checkLength(arg, indefinite)
+		for i := 0; indefinite || i < length; i++ {
+			if indefinite && d.readBreak() {
+				break
+			}
+			keyStart := d.cursor
+			key, ok := d.decode(depth + 1).(JString)
+			if !ok {
+				d.cursor = keyStart
+				d.fail("map key is not a text string")
+			}
+			if m.HasKey(string(key)) {
+				d.cursor = keyStart
+				d.fail("duplicate key:", Quoted(string(key)))
+			}
+			m.wrappedMap[string(key)] = d.decode(depth + 1)
+		}
+		return m
+	case cborMajorTag:
+		return d.decodeTagged(arg, depth)
+	}
+	return d.decodeSimple(info, arg, start)
+}
+
+// Read the content of a byte or text string, concatenating the chunks of an indefinite-length string
+func (d *cborDecoder) readString(major byte, length uint64, indefinite bool) []byte {
+	if !indefinite {
+		return d.readBytes(length)
+	}
+	var result []byte
+	for !d.readBreak() {
+		chunkMajor, _, chunkLength, chunkIndefinite := d.readHead()
+		if chunkMajor != major || chunkIndefinite {
+			d.fail("bad chunk within indefinite-length string")
+		}
+		result = append(result, d.readBytes(chunkLength)...)
+	}
+	return result
+}
+
+func (d *cborDecoder) decodeTagged(tag uint64, depth int) JSEntity {
+	start := d.cursor
+	content := d.decode(depth + 1)
+	switch tag {
+	case cborTagPositiveBignum, cborTagNegativeBignum:
+		bytes, ok := content.(JBytes)
+		if !ok {
+			d.cursor = start
+			d.fail("bignum is not a byte string")
+		}
+		v := new(big.Int).SetBytes(bytes)
+		if tag == cborTagNegativeBignum {
+			v.Sub(v.Neg(v), big.NewInt(1))
+		}
+		if v.IsInt64() {
+			return JInteger(v.Int64())
+		}
+		return JNumber(v.String())
+	case cborTagDecimal:
+		list, ok := content.(JSList)
+		var exponent, mantissa *big.Int
+		var err1, err2 error
+		if ok && list.Length() == 2 {
+			exponent, err1 = JSEntityBigInt(list.Get(0))
+			mantissa, err2 = JSEntityBigInt(list.Get(1))
+		}
+		if !ok || list.Length() != 2 || err1 != nil || err2 != nil || !exponent.IsInt64() {
+			d.cursor = start
+			d.fail("malformed decimal fraction")
+		}
+		n := decimalFractionNumber(mantissa, exponent.Int64())
+		if !jnumberExponentOk(string(n)) {
+			d.cursor = start
+			d.fail("decimal fraction's exponent is too large")
+		}
+		return n
+	}
+	// Other tags (e.g. dates) are ignored, leaving their content
+	return content
+}
+
+// Construct a JNumber whose value is mantissa * 10^exponent; it is written positionally unless that
+// would require a lot of zeros, in which case scientific notation is used
+func decimalFractionNumber(mantissa *big.Int, exponent int64) JNumber {
+	digits := new(big.Int).Abs(mantissa).String()
+	sign := Ternary(mantissa.Sign() < 0, "-", "")
+	if exponent == 0 {
+		return JNumber(sign + digits)
+	}
+	if exponent < 0 && -exponent <= int64(len(digits))+20 {
+		point := len(digits) + int(exponent)
+		if point <= 0 {
+			return JNumber(sign + "0." + strings.Repeat("0", -point) + digits)
+		}
+		return JNumber(sign + digits[:point] + "." + digits[point:])
+	}
+	text := sign + digits[:1]
+	if len(digits) > 1 {
+		text += "." + digits[1:]
+	}
+	return JNumber(text + "e" + IntToString(int(exponent)+len(digits)-1))
+}
+
+func (d *cborDecoder) decodeSimple(info byte, arg uint64, start int) JSEntity {
+	switch info {
+	case cborFalse & 0x1f:
+		return JBoolFalse
+	case cborTrue & 0x1f:
+		return JBoolTrue
+	case cborNull & 0x1f, cborUndefined & 0x1f:
+		return JNullValue
+	case cborFloat16 & 0x1f:
+		return JFloat(float16ToFloat64(uint16(arg)))
+	case cborFloat32 & 0x1f:
+		return JFloat(math.Float32frombits(uint32(arg)))
+	case cborFloat64 & 0x1f:
+		return JFloat(math.Float64frombits(arg))
+	}
+	d.cursor = start
+	d.fail("unsupported simple value:", IntToString(int(arg)))
+	return nil
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := Ternary(h&0x8000 != 0, -1.0, 1.0)
+	exponent := int(h>>10) & 0x1f
+	fraction := float64(h & 0x3ff)
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(fraction, -24)
+	case 0x1f:
+		if fraction != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(fraction+1024, exponent-25)
+}
@@ -0,0 +1,162 @@
+package base_test
+
+import (
+	"encoding/hex"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func cborHex(e JSEntity) string {
+	return hex.EncodeToString(EncodeCbor(e))
+}
+
+// Examples from RFC 8949, Appendix A
+func TestCborEncodeVectors(t *testing.T) {
+	j := jt.New(t)
+	j.AssertEqual(cborHex(JInteger(0)), "00")
+	j.AssertEqual(cborHex(JInteger(23)), "17")
+	j.AssertEqual(cborHex(JInteger(24)), "1818")
+	j.AssertEqual(cborHex(JInteger(1000)), "1903e8")
+	j.AssertEqual(cborHex(JInteger(1000000000000)), "1b000000e8d4a51000")
+	j.AssertEqual(cborHex(JInteger(-1)), "20")
+	j.AssertEqual(cborHex(JInteger(-1000)), "3903e7")
+	j.AssertEqual(cborHex(JNumber("18446744073709551615")), "1bffffffffffffffff")
+	j.AssertEqual(cborHex(JNumber("18446744073709551616")), "c249010000000000000000")
+	j.AssertEqual(cborHex(JNumber("-18446744073709551617")), "c349010000000000000000")
+	j.AssertEqual(cborHex(JFloat(100000.0)), "fa47c35000")
+	j.AssertEqual(cborHex(JFloat(1.1)), "fb3ff199999999999a")
+	j.AssertEqual(cborHex(JNumber("12.50")), "c482211904e2")
+	// Only a float can represent negative zero
+	j.AssertEqual(cborHex(JNumber("-0.0")), "fa80000000")
+	j.AssertEqual(cborHex(JNumber("0.0")), "c4822000")
+	j.AssertEqual(cborHex(JBoolFalse), "f4")
+	j.AssertEqual(cborHex(JNullValue), "f6")
+	j.AssertEqual(cborHex(JString("IETF")), "6449455446")
+	j.AssertEqual(cborHex(JString("ü")), "62c3bc")
+	j.AssertEqual(cborHex(JBytes{1, 2, 3, 4}), "4401020304")
+	j.AssertEqual(cborHex(JSListWith([]int{1, 2, 3})), "83010203")
+	j.AssertEqual(cborHex(JSMapFromStringM(`{"b":[2,3],"a":1}`)), "a26161016162820203")
+}
+
+func TestCborDecodeVectors(t *testing.T) {
+	j := jt.New(t)
+	decode := func(h string) string {
+		b, _ := hex.DecodeString(h)
+		e, err := DecodeCbor(b)
+		if err != nil {
+			return err.Error()
+		}
+		return PrintJSEntity(e, false)
+	}
+	j.AssertEqual(decode("f93c00"), "1.000000")
+	j.AssertEqual(decode("f97bff"), "65504.000000")
+	j.AssertEqual(decode("f90001"), "0.000000")
+	j.AssertEqual(decode("f7"), "null")
+	j.AssertEqual(decode("c11a514b67b0"), "1363896240")
+	j.AssertEqual(decode("c482213903e7"), "-10.00")
+	j.AssertEqual(decode("5f42010243030405ff"), `"AQIDBAU=`+"`b\"")
+	j.AssertEqual(decode("7f657374726561646d696e67ff"), `"streaming"`)
+	j.AssertEqual(decode("9f018202039f0405ffff"), "[1,[2,3],[4,5]]")
+	j.AssertEqual(decode("bf61610161629f0203ffff"), `{"a":1,"b":[2,3]}`)
+	j.AssertTrue(math.IsInf(DecodeCborM([]byte{0xf9, 0x7c, 0x00}).AsFloat(), 1))
+}
+
+func TestCborErrors(t *testing.T) {
+	j := jt.New(t)
+	result := NewJSMap()
+	for _, h := range []string{
+		"",
+		"1a0000",
+		"6261",
+		"62c328",
+		"a10102",
+		"a2616101616102",
+		"9bffffffffffffffff",
+		"0000",
+		"ff",
+		"5f6161ff",
+		"f0",
+		strings.Repeat("81", 1100) + "00",
+	} {
+		b, _ := hex.DecodeString(h)
+		_, err := DecodeCbor(b)
+		_, ok := err.(*CborError)
+		j.AssertTrue(ok, h)
+		result.Put(Truncated(h), err.Error())
+	}
+	j.AssertMessage(result)
+}
+
+var cborSampleJson = `{"name":"fido","age":3,"weight":12.5,"ratio":0.1,"alive":true,"owner":null,` +
+	`"tags":["a",{"x":[]},{}],"photo":"AAECAwQFBgcICQ==` + "`b" + `","notBytes":"hello` + "`b" + `",` +
+	`"big":123456789012345678901234567890,"price":19.990,"tiny":-1.5e-300,"text":"café 😀"}`
+
+func TestCborJsonRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	var p JSONParser
+	p.WithLosslessNumbers(true).WithText(cborSampleJson)
+	m, err := p.ParseMap()
+	j.AssertEqual(err, nil)
+
+	data := EncodeCbor(m)
+	m2 := JSMapFromCborM(data)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+	j.AssertTrue(JSEntitiesEqual(m, m2))
+
+	// Strings remain strings, even if they hold base64-encoded byte arrays
+	_, ok := m2.OptAny("photo").(JString)
+	j.AssertTrue(ok)
+	j.AssertEqual(m2.OptBytes("photo", nil), []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	_, ok = m2.OptAny("notBytes").(JString)
+	j.AssertTrue(ok)
+
+	// Byte arrays are encoded natively, and print as they would have before encoding
+	m.Put("photo", JBytes(m.OptBytes("photo", nil)))
+	m3 := JSMapFromCborM(EncodeCbor(m))
+	_, ok = m3.OptAny("photo").(JBytes)
+	j.AssertTrue(ok)
+	j.AssertEqual(m3.CompactString(), m2.CompactString())
+
+	// The encoding is deterministic, and smaller than the json
+	j.AssertEqual(EncodeCbor(m2), data)
+
+	// The sign of negative zero is kept
+	z := DecodeCborM(EncodeCbor(JNumber("-0.0")))
+	j.AssertTrue(JSEntitiesEqual(z, JNumber("-0.0")))
+	j.AssertTrue(math.Signbit(z.AsFloat()))
+	j.AssertTrue(len(data) < len(m.CompactString()))
+	j.AssertMessage(hex.EncodeToString(data))
+}
+
+type cborBlob struct {
+	Name    string
+	Content []byte
+}
+
+func TestCborMarshalBytes(t *testing.T) {
+	j := jt.New(t)
+	blob := cborBlob{Name: "x", Content: []byte("binary\x00data")}
+	e := MarshalM(blob)
+	m := JSMapFromCborM(EncodeCbor(e))
+	j.AssertEqual(m.CompactString(), e.(JSMap).CompactString())
+
+	var blob2 cborBlob
+	j.AssertEqual(Unmarshal(m, &blob2), nil)
+	j.AssertEqual(blob2.Content, blob.Content)
+
+	// The marshalled value is also readable from its json form
+	var blob3 cborBlob
+	j.AssertEqual(Unmarshal(JSMapFromStringM(m.CompactString()), &blob3), nil)
+	j.AssertEqual(blob3.Content, blob.Content)
+}
+
+func TestCborFile(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromStringM(`{"a":[1,2.5,"three"]}`)
+	file := j.GetTestResultsDir().JoinM("sample.cbor")
+	WriteCborFileM(file, m)
+	j.AssertEqual(JSMapFromCborFileM(file).CompactString(), m.CompactString())
+}
@@ -0,0 +1,381 @@
+package base
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Builds a configuration by deep-merging a sequence of layers (e.g. built-in defaults, a project
+// file, environment variables, and command line overrides), recording which layer each final
+// value came from.
+//
+// When a layer is added, its maps are merged with existing maps key by key; a null value removes
+// a key; and any other value replaces the existing one.  Lists are replaced by default, but can
+// instead be appended to, or merged by key (where maps within the lists are matched by the value
+// of a particular field).
+
+type ListStrategy int
+
+const (
+	ListReplace ListStrategy = iota
+	ListAppend
+	ListMergeByKey
+)
+
+type listRule struct {
+	// Pattern for the list's JSON pointer, where a "*" segment matches anything
+	pattern  []string
+	strategy ListStrategy
+	// For ListMergeByKey, the field within each map that identifies it
+	keyField string
+}
+
+type ConfigOverlayStruct struct {
+	result JSMap
+	// Source of each leaf value, keyed by JSON pointer
+	sources map[string]string
+	rules   []listRule
+}
+
+type ConfigOverlay = *ConfigOverlayStruct
+
+func NewConfigOverlay() ConfigOverlay {
+	return &ConfigOverlayStruct{
+		result:  NewJSMap(),
+		sources: make(map[string]string),
+	}
+}
+
+// Specify how lists at a particular location are merged.  The pointer can include "*" segments,
+// e.g. "/servers/*/ports".
+func (c ConfigOverlay) WithListStrategy(pointer string, strategy ListStrategy) ConfigOverlay {
+	CheckArg(strategy != ListMergeByKey, "use WithMergeKey to merge by key")
+	c.rules = append(c.rules, listRule{pattern: CheckOkWith(ParseJsonPointer(pointer)), strategy: strategy})
+	return c
+}
+
+// Specify that lists at a particular location are to be merged by key: a map in a later layer
+// whose keyField matches that of a map in the existing list is merged with it; otherwise it is appended.
+func (c ConfigOverlay) WithMergeKey(pointer string, keyField string) ConfigOverlay {
+	c.rules = append(c.rules, listRule{pattern: CheckOkWith(ParseJsonPointer(pointer)), strategy: ListMergeByKey, keyField: keyField})
+	return c
+}
+
+// Merge a layer into the configuration
+func (c ConfigOverlay) AddLayer(source string, layer JSMap) ConfigOverlay {
+	c.merge(c.result, layer, nil, source)
+	return c
+}
+
+// Merge a DataClass (e.g. one holding the built-in defaults) into the configuration
+func (c ConfigOverlay) AddDataClass(source string, data DataClass) ConfigOverlay {
+	return c.AddLayer(source, data.ToJson().AsJSMap())
+}
+
+// Merge a file into the configuration.  Its format is determined by its extension: .yaml, .yml,
+// .toml, .cbor, or (otherwise) json, which is parsed in relaxed mode.
+func (c ConfigOverlay) AddFile(file Path) error {
+	var m JSMap
+	var err error
+	switch strings.ToLower(file.Extension()) {
+	case "yaml", "yml":
+		m, err = JSMapFromYamlFile(file)
+	case "toml":
+		m, err = JSMapFromTomlFile(file)
+	case "cbor":
+		m, err = JSMapFromCborFile(file)
+	default:
+		m, err = JSMapFromFileRelaxed(file)
+	}
+	if err != nil {
+		return Error("problem reading config file:", file, INDENT, err)
+	}
+	c.AddLayer(file.String(), m)
+	return nil
+}
+
+// Merge environment variables whose names start with a prefix into the configuration.  The remainder
+// of each name is converted to lower case to form the key, with "__" separating the keys of nested
+// maps; e.g. with prefix "APP_", APP_SERVER__MAX_CONNECTIONS sets /server/max_connections.
+func (c ConfigOverlay) AddEnvironment(prefix string) error {
+	return c.AddEnvironmentVars(prefix, os.Environ())
+}
+
+// Merge environment variables, supplied as "NAME=value" strings, into the configuration (see AddEnvironment)
+func (c ConfigOverlay) AddEnvironmentVars(prefix string, environ []string) error {
+	sorted := append([]string(nil), environ...)
+	sort.Strings(sorted)
+	for _, entry := range sorted {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		keys := strings.Split(strings.ToLower(name[len(prefix):]), "__")
+		if err := c.setValue("env:"+name, keys, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge overrides, each of the form "key=value", into the configuration.  Keys of nested maps are
+// separated by dots, e.g. "server.port=8080".
+func (c ConfigOverlay) AddOverrides(source string, assignments ...string) error {
+	for _, a := range assignments {
+		key, value, found := strings.Cut(a, "=")
+		if !found || key == "" {
+			return Error("expected key=value:", Quoted(a))
+		}
+		if err := c.setValue(source, strings.Split(key, "."), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set a single value, converting its text to the type of any existing value
+func (c ConfigOverlay) setValue(source string, keys []string, text string) error {
+	var existing JSEntity = c.result
+	for _, k := range keys {
+		if m, ok := existing.(JSMap); ok {
+			existing = m.wrappedMap[k]
+		} else {
+			existing = nil
+		}
+	}
+	value, err := ConfigValueFromString(existing, text)
+	if err != nil {
+		return Error("problem with key", Quoted(strings.Join(keys, "."))+":", err)
+	}
+	layer := NewJSMap()
+	m := layer
+	for _, k := range keys[:len(keys)-1] {
+		child := NewJSMap()
+		m.wrappedMap[k] = child
+		m = child
+	}
+	m.wrappedMap[keys[len(keys)-1]] = value
+	c.AddLayer(source, layer)
+	return nil
+}
+
+// Convert text (e.g. from an environment variable or the command line) to a value of the same type
+// as an existing one; if there is no existing value, the text is parsed as json if possible, or
+// treated as a string otherwise.
+func ConfigValueFromString(existing JSEntity, text string) (JSEntity, error) {
+	switch existing.(type) {
+	case JInteger:
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, Error("can't convert to integer:", Quoted(text))
+		}
+		return JInteger(v), nil
+	case JFloat:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, Error("can't convert to float:", Quoted(text))
+		}
+		return JFloat(v), nil
+	case JBool:
+		switch text {
+		case "true":
+			return JBoolTrue, nil
+		case "false":
+			return JBoolFalse, nil
+		}
+		return nil, Error("can't convert to boolean:", Quoted(text))
+	case JString:
+		return JString(text), nil
+	}
+	list, err := JSListFromString("[" + text + "]")
+	if err == nil && list.Length() == 1 {
+		return list.Get(0), nil
+	}
+	if existing != nil {
+		return nil, Error("can't convert to", TypeOf(existing)+":", Quoted(text))
+	}
+	return JString(text), nil
+}
+
+// Get the merged configuration
+func (c ConfigOverlay) Result() JSMap {
+	return c.result
+}
+
+// Parse the merged configuration into a DataClass
+func (c ConfigOverlay) Parse(parser DataClass) DataClass {
+	return parser.Parse(c.result)
+}
+
+// Get the source of the value at a location, or "" if there is no value there (or it is a nonempty
+// map or list, whose values may have different sources)
+func (c ConfigOverlay) Source(pointer string) string {
+	return c.sources[pointer]
+}
+
+// Get a description of each value in the configuration, and where it came from
+func (c ConfigOverlay) Explain() string {
+	var paths, values []string
+	c.collectLeaves(c.result, nil, &paths, &values)
+	width := 0
+	for i, p := range paths {
+		width = MaxInt(width, len(p)+len(values[i]))
+	}
+	sb := strings.Builder{}
+	for i, p := range paths {
+		sb.WriteString(p)
+		sb.WriteString(" = ")
+		sb.WriteString(values[i])
+		sb.WriteString(Spaces(width - len(p) - len(values[i])))
+		sb.WriteString("  # ")
+		sb.WriteString(c.sources[p])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (c ConfigOverlay) collectLeaves(e JSEntity, path []string, paths *[]string, values *[]string) {
+	switch x := e.(type) {
+	case JSMap:
+		if x.Size() != 0 {
+			for _, k := range x.OrderedKeys() {
+				c.collectLeaves(x.wrappedMap[k], append(path[:len(path):len(path)], k), paths, values)
+			}
+			return
+		}
+	case JSList:
+		if x.Length() != 0 {
+			for i, v := range x.wrappedList {
+				c.collectLeaves(v, append(path[:len(path):len(path)], IntToString(i)), paths, values)
+			}
+			return
+		}
+	}
+	*paths = append(*paths, JsonPointer(path...))
+	*values = append(*values, Truncated(PrintJSEntity(e, false)))
+}
+
+// ---------------------------------------------------------------------------------------
+// Merging
+// ---------------------------------------------------------------------------------------
+
+func (c ConfigOverlay) merge(target JSMap, layer JSMap, path []string, source string) {
+	for _, k := range layer.OrderedKeys() {
+		value := layer.wrappedMap[k]
+		childPath := append(path[:len(path):len(path)], k)
+		existing := target.wrappedMap[k]
+		switch x := value.(type) {
+		case JNull:
+			delete(target.wrappedMap, k)
+			c.forget(childPath)
+			continue
+		case JSMap:
+			m, ok := existing.(JSMap)
+			if !ok || m.Size() == 0 {
+				c.forget(childPath)
+				m = NewJSMap()
+				target.wrappedMap[k] = m
+				if x.Size() == 0 {
+					c.sources[JsonPointer(childPath...)] = source
+				}
+			}
+			c.merge(m, x, childPath, source)
+			continue
+		case JSList:
+			if list, ok := existing.(JSList); ok {
+				target.wrappedMap[k] = c.mergeList(list, x, childPath, source)
+				continue
+			}
+		}
+		c.forget(childPath)
+		target.wrappedMap[k] = DeepCopyJSEntity(value)
+		c.recordLeaves(value, childPath, source)
+	}
+}
+
+func (c ConfigOverlay) mergeList(existing JSList, layer JSList, path []string, source string) JSList {
+	rule := c.ruleFor(path)
+	if rule.strategy == ListReplace || existing.Length() == 0 {
+		c.forget(path)
+		c.recordLeaves(layer, path, source)
+		return DeepCopyJSEntity(layer).AsJSList()
+	}
+	for _, item := range layer.wrappedList {
+		if rule.strategy == ListMergeByKey {
+			if i := findByKey(existing, item, rule.keyField); i >= 0 {
+				c.merge(existing.wrappedList[i].AsJSMap(), item.AsJSMap(), append(path[:len(path):len(path)], IntToString(i)), source)
+				continue
+			}
+		}
+		c.recordLeaves(item, append(path[:len(path):len(path)], IntToString(existing.Length())), source)
+		existing.wrappedList = append(existing.wrappedList, DeepCopyJSEntity(item))
+	}
+	return existing
+}
+
+// Find the index of the map within a list that has the same key as another, or -1
+func findByKey(list JSList, item JSEntity, keyField string) int {
+	m, ok := item.(JSMap)
+	if !ok || !m.HasKey(keyField) {
+		return -1
+	}
+	for i, x := range list.wrappedList {
+		if y, ok := x.(JSMap); ok && y.HasKey(keyField) && JSEntitiesEqual(y.wrappedMap[keyField], m.wrappedMap[keyField]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c ConfigOverlay) ruleFor(path []string) listRule {
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		r := c.rules[i]
+		if len(r.pattern) != len(path) {
+			continue
+		}
+		match := true
+		for j, s := range r.pattern {
+			if s != "*" && s != path[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return r
+		}
+	}
+	return listRule{strategy: ListReplace}
+}
+
+// Record the source of each leaf value within an entity
+func (c ConfigOverlay) recordLeaves(e JSEntity, path []string, source string) {
+	switch x := e.(type) {
+	case JSMap:
+		if x.Size() != 0 {
+			for k, v := range x.wrappedMap {
+				c.recordLeaves(v, append(path[:len(path):len(path)], k), source)
+			}
+			return
+		}
+	case JSList:
+		if x.Length() != 0 {
+			for i, v := range x.wrappedList {
+				c.recordLeaves(v, append(path[:len(path):len(path)], IntToString(i)), source)
+			}
+			return
+		}
+	}
+	c.sources[JsonPointer(path...)] = source
+}
+
+// Discard the sources recorded for a location and everything within it
+func (c ConfigOverlay) forget(path []string) {
+	pointer := JsonPointer(path...)
+	for p := range c.sources {
+		if p == pointer || strings.HasPrefix(p, pointer+"/") {
+			delete(c.sources, p)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+var overlayDefaults = `{
+  "name": "demo",
+  "port": 80,
+  "verbose": false,
+  "ratio": 0.5,
+  "tags": ["a", "b"],
+  "plugins": ["core"],
+  "servers": [
+    {"id": "alpha", "host": "a.example.com", "weight": 1},
+    {"id": "beta", "host": "b.example.com", "weight": 1}
+  ],
+  "db": {"host": "localhost", "pool": {"min": 1, "max": 4}}
+}`
+
+var overlayProject = `{
+  "port": 8080,
+  "tags": ["c"],
+  "plugins": ["extra"],
+  "servers": [
+    {"id": "beta", "weight": 5},
+    {"id": "gamma", "host": "g.example.com"}
+  ],
+  "db": {"pool": {"max": 16}, "user": "admin"},
+  "ratio": null
+}`
+
+func buildOverlay(j jt.JTest) ConfigOverlay {
+	c := NewConfigOverlay().
+		WithListStrategy("/plugins", ListAppend).
+		WithMergeKey("/servers", "id")
+	c.AddLayer("defaults", JSMapFromStringM(overlayDefaults))
+	c.AddLayer("project.json", JSMapFromStringM(overlayProject))
+	j.AssertEqual(c.AddEnvironmentVars("DEMO_", []string{"DEMO_DB__POOL__MIN=2", "DEMO_VERBOSE=true", "OTHER=7"}), nil)
+	j.AssertEqual(c.AddOverrides("command line", "port=9090", "db.host=db.internal", "extra={\"x\":1}"), nil)
+	return c
+}
+
+func TestOverlayMerge(t *testing.T) {
+	j := jt.New(t)
+	c := buildOverlay(j)
+	m := c.Result()
+	j.AssertEqual(m.GetInt("port"), 9090)
+	j.AssertFalse(m.HasKey("ratio"))
+	j.AssertEqual(m.GetList("tags").CompactString(), `["c"]`)
+	j.AssertEqual(m.GetList("plugins").CompactString(), `["core","extra"]`)
+	j.AssertEqual(m.GetPathM("/servers/1/weight"), JInteger(5))
+	j.AssertEqual(m.GetPathM("/servers/1/host"), JString("b.example.com"))
+	j.AssertEqual(m.GetPathM("/servers/2/id"), JString("gamma"))
+	j.AssertEqual(m.GetPathM("/db/pool/min"), JInteger(2))
+	j.AssertEqual(m.GetPathM("/verbose"), JBoolTrue)
+	j.AssertMessage(m)
+}
+
+func TestOverlayProvenance(t *testing.T) {
+	j := jt.New(t)
+	c := buildOverlay(j)
+	j.AssertEqual(c.Source("/name"), "defaults")
+	j.AssertEqual(c.Source("/port"), "command line")
+	j.AssertEqual(c.Source("/plugins/0"), "defaults")
+	j.AssertEqual(c.Source("/plugins/1"), "project.json")
+	j.AssertEqual(c.Source("/servers/1/weight"), "project.json")
+	j.AssertEqual(c.Source("/servers/1/host"), "defaults")
+	j.AssertEqual(c.Source("/db/pool/min"), "env:DEMO_DB__POOL__MIN")
+	j.AssertEqual(c.Source("/ratio"), "")
+	j.AssertMessage(c.Explain())
+}
+
+func TestOverlayConversionErrors(t *testing.T) {
+	j := jt.New(t)
+	c := NewConfigOverlay().AddLayer("defaults", JSMapFromStringM(overlayDefaults))
+	j.AssertTrue(c.AddOverrides("cl", "port=eighty") != nil)
+	j.AssertTrue(c.AddOverrides("cl", "verbose=yes") != nil)
+	j.AssertTrue(c.AddOverrides("cl", "tags=notalist") != nil)
+	j.AssertTrue(c.AddOverrides("cl", "novalue") != nil)
+	j.AssertEqual(c.AddOverrides("cl", "tags=[\"x\",\"y\"]", "newkey=plain text"), nil)
+	j.AssertEqual(c.Result().GetString("newkey"), "plain text")
+}
+
+func TestOverlayFile(t *testing.T) {
+	j := jt.New(t)
+	file := j.GetTestResultsDir().JoinM("config.yaml")
+	file.WriteStringM("port: 1234\ndb:\n  user: guest\n")
+	c := NewConfigOverlay().AddLayer("defaults", JSMapFromStringM(overlayDefaults))
+	j.AssertEqual(c.AddFile(file), nil)
+	j.AssertEqual(c.Result().GetInt("port"), 1234)
+	j.AssertEqual(c.Source("/db/user"), file.String())
+	j.AssertEqual(c.Source("/db/host"), "defaults")
+}
@@ -0,0 +1,116 @@
+package base
+
+import (
+	"strings"
+)
+
+// Equality, hashing, and field-level differences for DataClass values.  These work with the values'
+// json (see ToJson), so they apply to every DataClass, including generated ones.
+
+// Determine if two DataClass values are structurally equal: they must be of the same class (a builder
+// is of the same class as its built version), and have equal json (see JSEntitiesEqual)
+func DataClassesEqual(a DataClass, b DataClass) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return dataClassKey(a) == dataClassKey(b) && JSEntitiesEqual(a.ToJson(), b.ToJson())
+}
+
+// Calculate a hash code for a DataClass value; values that are equal (see DataClassesEqual) have the same hash code
+func DataClassHashCode(d DataClass) uint64 {
+	if d == nil {
+		return 0
+	}
+	return ContentHash64(d.ToJson())
+}
+
+// A comparable wrapper for a DataClass value, so it can be used as a map key; e.g.
+//
+//	var counts = make(map[DataKey[Animal]]int)
+//	counts[KeyOf(animal)]++
+//
+// Keys of equal values are equal.
+type DataKey[T DataClass] struct {
+	canonical string
+}
+
+func KeyOf[T DataClass](d T) DataKey[T] {
+	return DataKey[T]{canonical: CanonicalJSON(d.ToJson())}
+}
+
+// Get the value a key was constructed from, by parsing a copy of its json with a parser (e.g. DefaultAnimal)
+func (k DataKey[T]) Value(parser DataClass) T {
+	return parser.Parse(JSListFromStringM("[" + k.canonical + "]").Get(0)).(T)
+}
+
+func (k DataKey[T]) String() string {
+	return k.canonical
+}
+
+// ---------------------------------------------------------------------------------------
+// Field-level differences
+// ---------------------------------------------------------------------------------------
+
+// A change to a single field; for fields holding (or within) data classes, Path identifies
+// the nested field, e.g. "/location/x"
+type FieldChange struct {
+	Path string
+	// The old and new values; nil if the field was absent
+	Old JSEntity
+	New JSEntity
+}
+
+func (c FieldChange) String() string {
+	return c.Path + ": " + fieldChangeValue(c.Old) + " -> " + fieldChangeValue(c.New)
+}
+
+func fieldChangeValue(e JSEntity) string {
+	if e == nil {
+		return "(none)"
+	}
+	return Truncated(PrintJSEntity(e, false))
+}
+
+// Determine which fields differ between two values of the same DataClass.  Fields within maps (e.g. those
+// holding nested data classes) are compared individually; lists are compared as a whole.  The changes
+// are ordered by path.
+func DataClassChanges(before DataClass, after DataClass) []FieldChange {
+	CheckArg(dataClassKey(before) == dataClassKey(after), "values are of different classes:", dataClassKey(before),
+		dataClassKey(after))
+	var changes []FieldChange
+	auxFieldChanges(&changes, nil, before.ToJson(), after.ToJson())
+	return changes
+}
+
+func auxFieldChanges(changes *[]FieldChange, path []string, before JSEntity, after JSEntity) {
+	if before != nil && after != nil && JSEntitiesEqual(before, after) {
+		return
+	}
+	bm, bIsMap := before.(JSMap)
+	am, aIsMap := after.(JSMap)
+	if !(bIsMap && aIsMap) {
+		*changes = append(*changes, FieldChange{Path: JsonPointer(path...), Old: before, New: after})
+		return
+	}
+	keys := NewArray[string]()
+	keys.Append(bm.OrderedKeys()...)
+	for _, k := range am.OrderedKeys() {
+		if !bm.HasKey(k) {
+			keys.Add(k)
+		}
+	}
+	keys.Sort()
+	for _, k := range keys.Array() {
+		auxFieldChanges(changes, append(path[:len(path):len(path)], k), bm.OptAny(k), am.OptAny(k))
+	}
+}
+
+// Describe a list of changes, one per line
+func DescribeChanges(changes []FieldChange) string {
+	sb := strings.Builder{}
+	for _, c := range changes {
+		sb.WriteString(c.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
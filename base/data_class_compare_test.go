@@ -0,0 +1,70 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	. "github.com/jpsember/golang-base/gen/sample"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+// A DataClass whose json is an arbitrary map, for testing nested fields
+type record struct {
+	m JSMap
+}
+
+func (r record) String() string                  { return r.m.String() }
+func (r record) ToJson() JSEntity                { return r.m }
+func (r record) Parse(source JSEntity) DataClass { return record{m: source.AsJSMap()} }
+
+func TestDataClassesEqual(t *testing.T) {
+	j := jt.New(t)
+	a := NewDemoConfig().SetName("fido").SetTarget(3).Build()
+	b := NewDemoConfig().SetTarget(3).SetName("fido")
+	j.AssertTrue(DataClassesEqual(a, b))
+	j.AssertTrue(DataClassesEqual(a, b.Build()))
+	j.AssertEqual(DataClassHashCode(a), DataClassHashCode(b))
+	j.AssertFalse(DataClassesEqual(a, b.SetTarget(4)))
+	j.AssertFalse(DataClassesEqual(a, nil))
+	j.AssertTrue(DataClassesEqual(nil, nil))
+
+	// Values of different classes with the same json are not equal
+	p := record{m: JSMapFromStringM(`{"x":1}`)}
+	j.AssertFalse(DataClassesEqual(p, ParseDemoConfig(JSMapFromStringM(`{"x":1}`))))
+
+	// Numbers are compared by value
+	j.AssertTrue(DataClassesEqual(record{m: JSMapFromStringM(`{"x":1}`)}, record{m: JSMapFromStringM(`{"x":1.0}`)}))
+	j.AssertEqual(DataClassHashCode(record{m: JSMapFromStringM(`{"x":1}`)}),
+		DataClassHashCode(record{m: JSMapFromStringM(`{"x":1.0}`)}))
+}
+
+func TestDataKey(t *testing.T) {
+	j := jt.New(t)
+	counts := make(map[DataKey[DemoConfig]]int)
+	counts[KeyOf(NewDemoConfig().SetName("a").Build())]++
+	counts[KeyOf(NewDemoConfig().SetName("b").Build())]++
+	counts[KeyOf(NewDemoConfig().SetName("a").Build())]++
+	j.AssertEqual(len(counts), 2)
+
+	key := KeyOf(NewDemoConfig().SetName("a").Build())
+	j.AssertEqual(counts[key], 2)
+	j.AssertEqual(key.Value(DefaultDemoConfig).Name(), "a")
+
+	points := make(map[DataKey[IPoint]]bool)
+	points[KeyOf(IPointWith(3, 4))] = true
+	j.AssertTrue(points[KeyOf(IPointWith(3, 4))])
+	j.AssertEqual(KeyOf(IPointWith(3, 4)).Value(DefaultIPoint), IPointWith(3, 4))
+}
+
+func TestDataClassChanges(t *testing.T) {
+	j := jt.New(t)
+	a := NewDemoConfig().SetName("fido").SetTarget(3).Build()
+	b := a.ToBuilder().SetName("rex").SetSimulate(true).Build()
+	changes := DataClassChanges(a, b)
+	j.AssertEqual(len(changes), 2)
+	j.AssertEqual(changes[0].Path, "/name")
+	j.AssertEqual(len(DataClassChanges(a, a.ToBuilder())), 0)
+
+	before := record{m: JSMapFromStringM(`{"name":"x","loc":{"x":1,"y":2},"tags":["a"],"old":5}`)}
+	after := record{m: JSMapFromStringM(`{"name":"x","loc":{"x":1,"y":3},"tags":["a","b"],"new":true}`)}
+	j.AssertMessage(DescribeChanges(DataClassChanges(a, b)) + DescribeChanges(DataClassChanges(before, after)))
+}
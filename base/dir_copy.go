@@ -0,0 +1,340 @@
+package base
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Copies or mirrors a directory tree, e.g.
+//
+//	ops, err := NewDirCopier(source, target).WithMirror().WithDryRun(app.DryRun()).Run()
+//
+// The source files are selected by a DirWalk (see Walk), so its patterns and filters can be used to
+// choose which files are copied.  A file is copied if it is missing from the target, or differs from the
+// target's version; by default, files are considered to differ if their sizes or modification times differ,
+// or (see WithHashComparison) if their contents differ.  Copied files (and created directories) are given
+// the permissions of their source, and copied files are given the modification time of their source.
+//
+// When mirroring, any files or directories within the target that aren't in the source selection
+// are deleted; this includes any the walk's patterns or filters excluded (other than names omitted by
+// every DirWalk, e.g. .DS_Store).  Symbolic links within the target are deleted rather than followed,
+// and nothing is copied through them (it is an error if the source has a file or directory there).
+type DirCopierStruct struct {
+	BaseObject
+	source      Path
+	target      Path
+	walk        *DirWalk
+	mirror      bool
+	compareHash bool
+	dryRun      bool
+}
+
+type DirCopier = *DirCopierStruct
+
+type CopyOpType int
+
+const (
+	CopyOpMkDir CopyOpType = iota
+	CopyOpCopy
+	CopyOpChmod
+	CopyOpDelete
+)
+
+var copyOpNames = []string{"mkdir", "copy", "chmod", "delete"}
+
+func (t CopyOpType) String() string {
+	return copyOpNames[t]
+}
+
+// An operation performed (or, for a dry run, planned) by a DirCopier
+type CopyOp struct {
+	Type CopyOpType
+	// The source file; empty for mkdir and delete operations
+	Source Path
+	Target Path
+	Mode   fs.FileMode
+}
+
+func (op CopyOp) String() string {
+	switch op.Type {
+	case CopyOpCopy:
+		return op.Type.String() + " " + op.Source.String() + " -> " + op.Target.String()
+	case CopyOpChmod:
+		return op.Type.String() + " " + op.Mode.Perm().String() + " " + op.Target.String()
+	}
+	return op.Type.String() + " " + op.Target.String()
+}
+
+func NewDirCopier(source Path, target Path) DirCopier {
+	c := &DirCopierStruct{
+		source: source.AssertNonEmpty(),
+		target: target.AssertNonEmpty(),
+		walk:   NewDirWalk(source).WithRecurse().WithDirNames(),
+	}
+	c.SetName("DirCopier")
+	return c
+}
+
+// Copy the files within a directory (and its subdirectories) to another, replacing any that differ
+func CopyDirectory(source Path, target Path) error {
+	_, err := NewDirCopier(source, target).Run()
+	return err
+}
+
+func CopyDirectoryM(source Path, target Path) {
+	CheckOk(CopyDirectory(source, target))
+}
+
+// Make a directory a copy of another, copying files that differ and deleting extra ones
+func MirrorDirectory(source Path, target Path) error {
+	_, err := NewDirCopier(source, target).WithMirror().Run()
+	return err
+}
+
+func MirrorDirectoryM(source Path, target Path) {
+	CheckOk(MirrorDirectory(source, target))
+}
+
+// Get the DirWalk that selects the source files, to add patterns or filters to it
+func (c DirCopier) Walk() *DirWalk {
+	return c.walk
+}
+
+// Delete files and directories from the target that aren't in the source selection
+func (c DirCopier) WithMirror() DirCopier {
+	c.mirror = true
+	return c
+}
+
+// Compare files' contents (rather than their modification times) to determine if they differ
+func (c DirCopier) WithHashComparison() DirCopier {
+	c.compareHash = true
+	return c
+}
+
+// If true, Run() returns the operations it would perform without performing them
+func (c DirCopier) WithDryRun(dryRun bool) DirCopier {
+	c.dryRun = dryRun
+	return c
+}
+
+// Perform the copy, returning the operations performed (or, for a dry run, that would be performed).
+// The operations are ordered: directories created, files copied, permissions changed (including those
+// of the directories created), then files and directories deleted.
+func (c DirCopier) Run() ([]CopyOp, error) {
+	ops, err := c.plan()
+	if err != nil || c.dryRun {
+		return ops, err
+	}
+	for i, op := range ops {
+		c.Log(op)
+		if err = c.perform(op); err != nil {
+			return ops[:i], Error("failed to", op, INDENT, err)
+		}
+	}
+	return ops, nil
+}
+
+func (c DirCopier) RunM() []CopyOp {
+	return CheckOkWith(c.Run())
+}
+
+func (c DirCopier) plan() ([]CopyOp, error) {
+	sourceFS := c.walk.fileSystem
+	if !IsDirFS(sourceFS, c.source) {
+		return nil, Error("no such directory:", c.source)
+	}
+	if sourceFS == OSFileSystem && isWithinDirectory(c.target, c.source) {
+		return nil, Error("target directory", c.target, "is within source", c.source)
+	}
+	if c.mirror && sourceFS == OSFileSystem && isWithinDirectory(c.source, c.target) {
+		// The source would be deleted from the target, since it isn't one of the source files
+		return nil, Error("can't mirror source", c.source, "to a directory containing it:", c.target)
+	}
+	if c.target.Exists() && !c.target.IsDir() {
+		return nil, Error("target is not a directory:", c.target)
+	}
+
+	var sources []Path
+	if err := catchPanicAsError(func() { sources = c.walk.Files() }); err != nil {
+		return nil, err
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i] < sources[j] })
+
+	var mkdirs, copies, chmods, deletes []CopyOp
+	selected := NewSet[string]()
+
+	if !c.target.Exists() {
+		mkdirs = append(mkdirs, CopyOp{Type: CopyOpMkDir, Target: c.target, Mode: 0755})
+	}
+	for _, src := range sources {
+		rel := c.relativeTo(src, c.source)
+		selected.Add(rel)
+		dst := c.target.JoinM(rel)
+		if err := checkNoSymlinksWithin(c.target, dst); err != nil {
+			return nil, Error("can't copy", src, INDENT, err)
+		}
+		srcInfo, err := sourceFS.Stat(src)
+		if errors.Is(err, fs.ErrNotExist) {
+			// A broken symlink, which isn't copied
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		dstInfo, err := os.Stat(dst.String())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil && dstInfo.IsDir() != srcInfo.IsDir() {
+			return nil, Error("can't replace", Ternary(dstInfo.IsDir(), "directory", "file"), dst, "with",
+				Ternary(srcInfo.IsDir(), "directory", "file"), src)
+		}
+		perm := srcInfo.Mode().Perm()
+		if srcInfo.IsDir() {
+			if dstInfo == nil {
+				// The directory's permissions are applied after the files are copied into it,
+				// in case they don't allow writing
+				mkdirs = append(mkdirs, CopyOp{Type: CopyOpMkDir, Target: dst, Mode: perm})
+				chmods = append(chmods, CopyOp{Type: CopyOpChmod, Target: dst, Mode: perm})
+			} else if dstInfo.Mode().Perm() != perm {
+				chmods = append(chmods, CopyOp{Type: CopyOpChmod, Target: dst, Mode: perm})
+			}
+			continue
+		}
+		differs := dstInfo == nil
+		if !differs {
+			differs, err = c.filesDiffer(src, srcInfo, dst, dstInfo)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if differs {
+			copies = append(copies, CopyOp{Type: CopyOpCopy, Source: src, Target: dst, Mode: perm})
+		} else if dstInfo.Mode().Perm() != perm {
+			chmods = append(chmods, CopyOp{Type: CopyOpChmod, Target: dst, Mode: perm})
+		}
+	}
+
+	if c.mirror && c.target.Exists() {
+		if err := c.planDeletes(c.target, selected, NewDirWalk(c.target), &deletes); err != nil {
+			return nil, err
+		}
+	}
+
+	var ops []CopyOp
+	ops = append(ops, mkdirs...)
+	ops = append(ops, copies...)
+	ops = append(ops, chmods...)
+	ops = append(ops, deletes...)
+	return ops, nil
+}
+
+// Add operations to delete the files and directories within a target directory that aren't in the source
+// selection.  A directory that is deleted is deleted with its contents, and symbolic links are deleted
+// rather than followed.  Names omitted by a DirWalk are left alone.
+func (c DirCopier) planDeletes(dir Path, selected *Set[string], walk *DirWalk, deletes *[]CopyOp) error {
+	entries, err := os.ReadDir(dir.String())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		dst := dir.JoinM(entry.Name())
+		rel := c.relativeTo(dst, c.target)
+		// This is false for a symbolic link, even to a directory
+		isDir := entry.IsDir()
+		if walk.omitted(entry.Name(), rel, isDir) {
+			continue
+		}
+		if !selected.Contains(rel) {
+			*deletes = append(*deletes, CopyOp{Type: CopyOpDelete, Target: dst})
+		} else if isDir {
+			if err = c.planDeletes(dst, selected, walk, deletes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c DirCopier) relativeTo(path Path, dir Path) string {
+	return strings.TrimPrefix(path.String(), dir.String()+"/")
+}
+
+// Determine if a path is the same as, or within, a directory
+func isWithinDirectory(path Path, dir Path) bool {
+	p, err1 := path.GetAbs()
+	d, err2 := dir.GetAbs()
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return p == d || strings.HasPrefix(p.String(), strings.TrimSuffix(d.String(), "/")+"/")
+}
+
+func (c DirCopier) filesDiffer(src Path, srcInfo fs.FileInfo, dst Path, dstInfo fs.FileInfo) (bool, error) {
+	if srcInfo.Size() != dstInfo.Size() {
+		return true, nil
+	}
+	if !c.compareHash {
+		return !srcInfo.ModTime().Equal(dstInfo.ModTime()), nil
+	}
+	h1, err := fileHashFS(c.walk.fileSystem, src)
+	if err != nil {
+		return false, err
+	}
+	h2, err := fileHashFS(OSFileSystem, dst)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(h1, h2), nil
+}
+
+func fileHashFS(fsys FileSystem, file Path) ([]byte, error) {
+	in, err := fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, in); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func (c DirCopier) perform(op CopyOp) error {
+	target := op.Target.String()
+	switch op.Type {
+	case CopyOpMkDir:
+		// Its permissions (other than allowing the owner access) are applied by a later chmod operation
+		return os.MkdirAll(target, op.Mode|0700)
+	case CopyOpCopy:
+		sourceFS := c.walk.fileSystem
+		info, err := sourceFS.Stat(op.Source)
+		if err == nil && op.Target.Exists() {
+			// Make sure an existing (perhaps read-only) file can be replaced
+			err = os.Chmod(target, 0600)
+		}
+		if err == nil {
+			err = CopyFileFS(sourceFS, op.Source, OSFileSystem, op.Target)
+		}
+		if err == nil {
+			err = os.Chmod(target, op.Mode)
+		}
+		if err == nil {
+			err = os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+		return err
+	case CopyOpChmod:
+		return os.Chmod(target, op.Mode)
+	case CopyOpDelete:
+		return os.RemoveAll(target)
+	}
+	return nil
+}
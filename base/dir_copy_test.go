@@ -0,0 +1,158 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type copyFixture struct {
+	j      jt.JTest
+	source Path
+	target Path
+}
+
+func newCopyFixture(t *testing.T, j jt.JTest) *copyFixture {
+	// Use a temporary directory, since AssertMessage replaces the test results directory
+	dir := NewPathM(t.TempDir())
+	f := &copyFixture{j: j, source: dir.JoinM("source"), target: dir.JoinM("target")}
+	f.write("a.txt", "alpha")
+	f.write("sub/b.txt", "bravo")
+	f.write("sub/deeper/c.sh", "#!/bin/sh")
+	f.source.JoinM("sub/deeper/c.sh").ChmodM(0755)
+	f.write("skip/d.tmp", "scratch")
+	return f
+}
+
+func (f *copyFixture) write(name string, content string) {
+	p := f.source.JoinM(name)
+	p.Parent().MkDirsM()
+	p.WriteStringM(content)
+}
+
+// Describe operations, with paths relative to the fixture's directories
+func (f *copyFixture) describe(ops []CopyOp) string {
+	var s []string
+	for _, op := range ops {
+		desc := op.String()
+		desc = strings.ReplaceAll(desc, f.source.String()+"/", "")
+		desc = strings.ReplaceAll(desc, f.target.String(), "T")
+		s = append(s, desc)
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestCopyDirectory(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	ops := NewDirCopier(f.source, f.target).RunM()
+	j.AssertMessage(f.describe(ops))
+	j.AssertEqual(f.target.JoinM("sub/b.txt").ReadStringM(), "bravo")
+	info := CheckOkWith(os.Stat(f.target.JoinM("sub/deeper/c.sh").String()))
+	j.AssertEqual(info.Mode().Perm(), os.FileMode(0755))
+
+	// A second copy has nothing to do
+	j.AssertEqual(len(NewDirCopier(f.source, f.target).RunM()), 0)
+
+	// Only the changed file is copied, and a permission change is applied
+	f.write("a.txt", "alpha 2")
+	f.source.JoinM("sub/b.txt").ChmodM(0600)
+	j.AssertEqual(f.describe(NewDirCopier(f.source, f.target).RunM()), "copy a.txt -> T/a.txt\nchmod -rw------- T/sub/b.txt")
+	j.AssertEqual(f.target.JoinM("a.txt").ReadStringM(), "alpha 2")
+}
+
+func TestMirrorDirectory(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	CopyDirectoryM(f.source, f.target)
+	f.target.JoinM("extra/old.txt").Parent().MkDirsM()
+	f.target.JoinM("extra/old.txt").WriteStringM("old")
+	f.target.JoinM("sub/stale.txt").WriteStringM("stale")
+
+	// Omitted files are deleted from the target too
+	c := NewDirCopier(f.source, f.target).WithMirror().WithDryRun(true)
+	c.Walk().OmitNames(`.*\.tmp`)
+	ops := c.RunM()
+	// A directory is deleted with its contents
+	j.AssertEqual(f.describe(ops), "delete T/extra\ndelete T/skip/d.tmp\ndelete T/sub/stale.txt")
+	// A dry run doesn't change anything
+	j.AssertTrue(f.target.JoinM("extra/old.txt").Exists())
+
+	c = NewDirCopier(f.source, f.target).WithMirror()
+	c.Walk().OmitNames(`.*\.tmp`)
+	j.AssertEqual(f.describe(c.RunM()), f.describe(ops))
+	j.AssertFalse(f.target.JoinM("extra").Exists())
+	j.AssertFalse(f.target.JoinM("skip/d.tmp").Exists())
+	j.AssertTrue(f.target.JoinM("skip").IsDir())
+}
+
+func TestCopyDirectoryHashComparison(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	CopyDirectoryM(f.source, f.target)
+
+	// Change a file's content without changing its size or modification time
+	file := f.source.JoinM("a.txt")
+	info := CheckOkWith(os.Stat(file.String()))
+	file.WriteStringM("ALPHA")
+	CheckOk(os.Chtimes(file.String(), time.Now(), info.ModTime()))
+
+	j.AssertEqual(len(NewDirCopier(f.source, f.target).RunM()), 0)
+	j.AssertEqual(f.describe(NewDirCopier(f.source, f.target).WithHashComparison().RunM()), "copy a.txt -> T/a.txt")
+	j.AssertEqual(f.target.JoinM("a.txt").ReadStringM(), "ALPHA")
+}
+
+func TestCopyDirectoryIntoItself(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	j.AssertTrue(CopyDirectory(f.source, f.source.JoinM("sub/copy")) != nil)
+	// Mirroring into a directory containing the source would delete the source
+	j.AssertTrue(MirrorDirectory(f.source, f.source.Parent()) != nil)
+	j.AssertTrue(f.source.JoinM("a.txt").Exists())
+}
+
+func TestCopyDirectoryReadOnly(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	f.source.JoinM("sub/deeper").ChmodM(0555)
+	defer f.source.JoinM("sub/deeper").ChmodM(0755)
+	CopyDirectoryM(f.source, f.target)
+	defer f.target.JoinM("sub/deeper").ChmodM(0755)
+	j.AssertEqual(f.target.JoinM("sub/deeper/c.sh").ReadStringM(), "#!/bin/sh")
+	info := CheckOkWith(os.Stat(f.target.JoinM("sub/deeper").String()))
+	j.AssertEqual(info.Mode().Perm(), os.FileMode(0555))
+}
+
+func TestCopyDirectorySymlinks(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	// A link to a directory is followed; a broken link is skipped
+	CheckOk(os.Symlink(f.source.JoinM("sub/deeper").String(), f.source.JoinM("linked").String()))
+	CheckOk(os.Symlink(f.source.JoinM("missing").String(), f.source.JoinM("broken").String()))
+	CheckOk(CopyDirectory(f.source, f.target))
+	j.AssertEqual(f.target.JoinM("linked/c.sh").ReadStringM(), "#!/bin/sh")
+	j.AssertFalse(f.target.JoinM("broken").Exists())
+}
+
+func TestMirrorDirectorySymlinksInTarget(t *testing.T) {
+	j := jt.New(t)
+	f := newCopyFixture(t, j)
+	CopyDirectoryM(f.source, f.target)
+	elsewhere := f.target.Parent().JoinM("elsewhere")
+	elsewhere.MkDirsM()
+	elsewhere.JoinM("keep.txt").WriteStringM("keep")
+
+	// A link in the target is deleted, rather than what it links to
+	CheckOk(os.Symlink(elsewhere.String(), f.target.JoinM("link").String()))
+	j.AssertEqual(f.describe(NewDirCopier(f.source, f.target).WithMirror().RunM()), "delete T/link")
+	j.AssertEqual(elsewhere.JoinM("keep.txt").ReadStringM(), "keep")
+
+	// Nothing is copied through a link in the target
+	j.AssertTrue(os.RemoveAll(f.target.JoinM("sub").String()) == nil)
+	CheckOk(os.Symlink(elsewhere.String(), f.target.JoinM("sub").String()))
+	j.AssertTrue(CopyDirectory(f.source, f.target) != nil)
+	j.AssertFalse(elsewhere.JoinM("b.txt").Exists())
+}
@@ -1,8 +1,14 @@
 package base
 
 import (
-	"os"
+	"io/fs"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Use a type alias, but don't export it
@@ -10,15 +16,21 @@ type regex = *regexp.Regexp
 
 type DirWalk struct {
 	BaseObject
-	startDirectory Path
-	withRecurse    bool
-	filePatterns   *patternCollection
-	dirPatterns    *patternCollection
-	absFilesList   []Path
-	relFilesList   []Path
-	patternFlags   int
-	regexpSet      map[string]regex
-	includeDirs    bool
+	startDirectory  Path
+	withRecurse     bool
+	filePatterns    *patternCollection
+	dirPatterns     *patternCollection
+	absFilesList    []Path
+	relFilesList    []Path
+	patternFlags    int
+	regexpSet       map[string]regex
+	includeDirs     bool
+	fileSystem      FileSystem
+	includeGlobs    *Array[string]
+	ignoreFileNames []string
+	filters         []func(path Path, info fs.FileInfo) bool
+	skipLinkDirs    bool
+	workers         int
 }
 
 const patflagFile = 1 << 0
@@ -30,8 +42,10 @@ func NewDirWalk(directory Path) *DirWalk {
 	w.regexpSet = make(map[string]regex)
 	w.SetName("DirWalk")
 	w.startDirectory = directory.AssertNonEmpty()
+	w.fileSystem = OSFileSystem
 	w.filePatterns = newPatternCollection()
 	w.dirPatterns = newPatternCollection()
+	w.includeGlobs = NewArray[string]()
 	w.OmitNames(defaultOmitExprs...)
 	return w
 }
@@ -47,6 +61,13 @@ func (w *DirWalk) WithDirNames() *DirWalk {
 	return w
 }
 
+// Walk a filesystem other than the OS's (e.g. an in-memory one)
+func (w *DirWalk) WithFileSystem(fsys FileSystem) *DirWalk {
+	w.assertMutable()
+	w.fileSystem = fsys
+	return w
+}
+
 // Have subsequent patterns affect only files
 func (w *DirWalk) ForFiles() *DirWalk {
 	w.assertMutable()
@@ -138,8 +159,98 @@ func (w *DirWalk) OmitNamesWithSubstrings(nameExprs ...string) *DirWalk {
 	return w
 }
 
+// Include only files whose paths match one of a list of glob patterns (see GlobMatch).  A pattern
+// containing a '/' is matched against the path relative to the start directory (e.g. "src/**/*.go");
+// otherwise, it is matched against the file's name (e.g. "*.go").  Unlike IncludeExtensions(), these
+// never affect which directories are examined.
+func (w *DirWalk) IncludeGlobs(globs ...string) *DirWalk {
+	w.assertMutable()
+	for _, g := range globs {
+		CheckOk(validateGlob(g))
+		w.includeGlobs.Add(g)
+	}
+	return w
+}
+
+// Omit files or directories whose paths match one of a list of glob patterns (see IncludeGlobs())
+func (w *DirWalk) OmitGlobs(globs ...string) *DirWalk {
+	w.assertMutable()
+	for _, g := range globs {
+		CheckOk(validateGlob(g))
+		if (w.patternFlags & patflagFile) != 0 {
+			w.filePatterns.OmitGlobs.Add(g)
+		}
+		if (w.patternFlags & patflagDir) != 0 {
+			w.dirPatterns.OmitGlobs.Add(g)
+		}
+	}
+	return w
+}
+
+// Honour ignore files with particular names (e.g. ".gitignore") found in each directory.  These use the
+// syntax of .gitignore files, and affect the directory containing them and its subdirectories.
+func (w *DirWalk) WithIgnoreFiles(names ...string) *DirWalk {
+	w.assertMutable()
+	w.ignoreFileNames = append(w.ignoreFileNames, names...)
+	return w
+}
+
+// Include only files for which a function returns true
+func (w *DirWalk) WithFilter(filter func(path Path, info fs.FileInfo) bool) *DirWalk {
+	w.assertMutable()
+	w.filters = append(w.filters, filter)
+	return w
+}
+
+// Include only files of at least a particular size (in bytes)
+func (w *DirWalk) WithMinSize(size int64) *DirWalk {
+	return w.WithFilter(func(path Path, info fs.FileInfo) bool { return info.Size() >= size })
+}
+
+// Include only files of at most a particular size (in bytes)
+func (w *DirWalk) WithMaxSize(size int64) *DirWalk {
+	return w.WithFilter(func(path Path, info fs.FileInfo) bool { return info.Size() <= size })
+}
+
+// Include only files modified after a particular time
+func (w *DirWalk) ModifiedAfter(t time.Time) *DirWalk {
+	return w.WithFilter(func(path Path, info fs.FileInfo) bool { return info.ModTime().After(t) })
+}
+
+// Include only files modified before a particular time
+func (w *DirWalk) ModifiedBefore(t time.Time) *DirWalk {
+	return w.WithFilter(func(path Path, info fs.FileInfo) bool { return info.ModTime().Before(t) })
+}
+
+// Include only files whose mode bits, masked, have a particular value; e.g. WithMode(0111, 0111) for files
+// executable by everyone
+func (w *DirWalk) WithMode(mask fs.FileMode, value fs.FileMode) *DirWalk {
+	return w.WithFilter(func(path Path, info fs.FileInfo) bool { return info.Mode()&mask == value })
+}
+
+// Omit symbolic links to directories.  Normally, they are followed (except those that would lead to
+// a cycle, which are skipped).  Symbolic links to files, and broken links, are always treated as files.
+func (w *DirWalk) OmitSymlinkDirs() *DirWalk {
+	w.assertMutable()
+	w.skipLinkDirs = true
+	return w
+}
+
+// Read directories concurrently, using a number of workers (if zero, the number of CPUs); the
+// results are sorted by path
+func (w *DirWalk) WithParallel(workers int) *DirWalk {
+	w.assertMutable()
+	CheckArg(workers >= 0)
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	w.workers = workers
+	return w
+}
+
 // Return files (and optionally, directories) within the start directory.
-// Does *not* include the start directory itself
+// Does *not* include the start directory itself.  If WithParallel() is in effect, the
+// paths are sorted; otherwise, they are in the order they were encountered.
 func (w *DirWalk) Files() []Path {
 
 	var pr = w.Log
@@ -150,76 +261,251 @@ func (w *DirWalk) Files() []Path {
 		pr("start dir:", w.startDirectory)
 
 		var lst []Path
+		if w.workers > 0 {
+			lst = w.walkParallel()
+		} else {
+			lst = w.walkSerial()
+		}
+		w.absFilesList = lst
+		if w.absFilesList == nil {
+			w.absFilesList = []Path{}
+		}
+	}
+	return w.absFilesList
+}
 
-		var stack = NewArray[Path]()
-		stack.Add(w.startDirectory)
+func (w *DirWalk) rootTask() dirTask {
+	return dirTask{path: w.startDirectory, ancestors: []string{w.resolveDir(w.startDirectory)}}
+}
 
-		for !stack.IsEmpty() {
-			var dir = stack.Pop()
-			if dir != w.startDirectory {
-				if w.includeDirs {
-					lst = append(lst, dir)
-				}
-				if !w.withRecurse {
-					continue
-				}
+// A directory to be read
+type dirTask struct {
+	path Path
+	// The path relative to the start directory ("" for the start directory itself)
+	rel    string
+	ignore *ignoreRules
+	// The resolved paths of this directory and its ancestors, for detecting symlink cycles
+	ancestors []string
+}
+
+func (w *DirWalk) walkSerial() []Path {
+	var lst []Path
+	var stack = NewArray[dirTask]()
+	stack.Add(w.rootTask())
+
+	for !stack.IsEmpty() {
+		var task = stack.Pop()
+		if task.path != w.startDirectory {
+			if w.includeDirs {
+				lst = append(lst, task.path)
+			}
+			if !w.withRecurse {
+				continue
+			}
+		}
+		files, subdirs := w.readDir(task)
+		lst = append(lst, files...)
+		for _, sub := range subdirs {
+			w.Log("stacking dir", sub.path)
+			stack.Add(sub)
+		}
+	}
+	return lst
+}
+
+func (w *DirWalk) walkParallel() []Path {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var lst []Path
+	var firstErr error
+	var semaphore = make(chan struct{}, w.workers)
+
+	var visit func(task dirTask)
+	visit = func(task dirTask) {
+		defer wg.Done()
+		semaphore <- struct{}{}
+		var files []Path
+		var subdirs []dirTask
+		err := catchPanicAsError(func() { files, subdirs = w.readDir(task) })
+		<-semaphore
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		lst = append(lst, files...)
+		for _, sub := range subdirs {
+			if w.includeDirs {
+				lst = append(lst, sub.path)
 			}
+			if w.withRecurse {
+				wg.Add(1)
+				go visit(sub)
+			}
+		}
+	}
 
-			files, err := os.ReadDir(dir.String())
-			CheckOkWith(files, err, "failed to read dir:", dir)
+	wg.Add(1)
+	visit(w.rootTask())
+	wg.Wait()
+	CheckOk(firstErr)
+	sort.Slice(lst, func(i, j int) bool { return lst[i] < lst[j] })
+	return lst
+}
 
-			for _, file := range files {
-				var nm = file.Name()
-				var omit = false
+// Read a directory, returning the files within it that pass the filters, and the subdirectories
+// that haven't been omitted
+func (w *DirWalk) readDir(task dirTask) (files []Path, subdirs []dirTask) {
+	var pr = w.Log
+	var dir = task.path
 
-				var child = dir.JoinM(nm)
-				var childIsDir = child.IsDir()
+	entries, err := w.fileSystem.ReadDir(dir)
+	CheckOkWith(entries, err, "failed to read dir:", dir)
 
-				// Determine which pattern set to apply
-				var pats *patternCollection
-				if childIsDir {
-					pats = w.dirPatterns
-				} else {
-					pats = w.filePatterns
-				}
+	var ignore = task.ignore
+	for _, name := range w.ignoreFileNames {
+		content, err := w.fileSystem.ReadFile(dir.JoinM(name))
+		if err != nil {
+			continue
+		}
+		rules, err := parseIgnoreRules(string(content))
+		CheckOkWith(rules, err, "failed to parse:", dir.JoinM(name))
+		ignore = &ignoreRules{parent: ignore, baseDir: task.rel, rules: rules}
+	}
 
-				for _, pat := range pats.Omit.Array() {
-					if pat.MatchString(nm) {
-						omit = true
-						break
-					}
-				}
+	for _, entry := range entries {
+		var nm = entry.Name()
+		var child = dir.JoinM(nm)
+		var rel = nm
+		if task.rel != "" {
+			rel = task.rel + "/" + nm
+		}
 
-				if omit {
-					continue
-				}
+		var isLink = entry.Type()&fs.ModeSymlink != 0
+		info, err := w.fileSystem.Stat(child)
+		if err != nil && isLink {
+			// A broken symlink, which is treated as a file
+			info, err = entry.Info()
+		}
+		if err != nil {
+			// A file that was deleted since the directory was read
+			pr("can't stat:", child)
+			continue
+		}
+		var childIsDir = info.IsDir()
+		if childIsDir && isLink && w.skipLinkDirs {
+			pr("not following link:", child)
+			continue
+		}
 
-				if pats.Include.NonEmpty() {
-					omit = true
-					for _, pat := range pats.Include.Array() {
-						if pat.MatchString(nm) {
-							omit = false
-							break
-						}
-					}
-				}
+		if w.omitted(nm, rel, childIsDir) || ignore.ignores(rel, childIsDir) {
+			continue
+		}
 
-				if omit {
+		if childIsDir {
+			// Only a link can lead back to an ancestor
+			var resolved = filepath.Join(task.ancestors[len(task.ancestors)-1], nm)
+			if isLink {
+				resolved = w.resolveDir(child)
+				if stringSliceContains(task.ancestors, resolved) {
+					pr("skipping symlink cycle:", child)
 					continue
 				}
+			}
+			subdirs = append(subdirs, dirTask{path: child, rel: rel, ignore: ignore,
+				ancestors: append(task.ancestors[:len(task.ancestors):len(task.ancestors)], resolved)})
+		} else if w.passesFilters(child, info) {
+			pr("adding  file", child)
+			files = append(files, child)
+		}
+	}
+	return
+}
 
-				if childIsDir {
-					stack.Add(child)
-					pr("stacking dir", child)
-				} else {
-					pr("adding  file", child)
-					lst = append(lst, child)
-				}
+// Determine if a name (or relative path) is omitted by the regular expressions or globs
+func (w *DirWalk) omitted(nm string, rel string, isDir bool) bool {
+	// Determine which pattern set to apply
+	var pats *patternCollection
+	if isDir {
+		pats = w.dirPatterns
+	} else {
+		pats = w.filePatterns
+	}
+
+	for _, pat := range pats.Omit.Array() {
+		if pat.MatchString(nm) {
+			return true
+		}
+	}
+	for _, g := range pats.OmitGlobs.Array() {
+		if matchGlobPattern(g, nm, rel) {
+			return true
+		}
+	}
+
+	if pats.Include.NonEmpty() {
+		var found = false
+		for _, pat := range pats.Include.Array() {
+			if pat.MatchString(nm) {
+				found = true
+				break
 			}
 		}
-		w.absFilesList = lst
+		if !found {
+			return true
+		}
 	}
-	return w.absFilesList
+
+	if !isDir && w.includeGlobs.NonEmpty() {
+		for _, g := range w.includeGlobs.Array() {
+			if matchGlobPattern(g, nm, rel) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Patterns without a '/' are matched against the name; others, against the path relative to the start directory
+func matchGlobPattern(pattern string, nm string, rel string) bool {
+	var subject = rel
+	if !strings.Contains(pattern, "/") {
+		subject = nm
+	}
+	return globSegmentsMatch(strings.Split(pattern, "/"), strings.Split(subject, "/"))
+}
+
+func (w *DirWalk) passesFilters(path Path, info fs.FileInfo) bool {
+	for _, f := range w.filters {
+		if !f(path, info) {
+			return false
+		}
+	}
+	return true
+}
+
+func stringSliceContains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine the path a directory resolves to, for detecting symlink cycles
+func (w *DirWalk) resolveDir(dir Path) string {
+	if w.fileSystem == OSFileSystem {
+		if resolved, err := filepath.EvalSymlinks(dir.String()); err == nil {
+			return resolved
+		}
+	}
+	return dir.String()
 }
 
 func (w *DirWalk) FilesRelative() []Path {
@@ -239,18 +525,51 @@ func (w *DirWalk) FilesRelative() []Path {
 	return w.relFilesList
 }
 
+// Discard any results, so the next call to Files() walks the directory again
+func (w *DirWalk) rewind() {
+	w.absFilesList = nil
+	w.relFilesList = nil
+}
+
+// Get a copy of the walk (without any results), which is unaffected by later changes to this one
+func (w *DirWalk) copy() *DirWalk {
+	c := *w
+	c.rewind()
+	c.filePatterns = w.filePatterns.copy()
+	c.dirPatterns = w.dirPatterns.copy()
+	c.includeGlobs = NewArray[string]()
+	c.includeGlobs.Append(w.includeGlobs.Array()...)
+	c.ignoreFileNames = append([]string(nil), w.ignoreFileNames...)
+	c.filters = append([]func(path Path, info fs.FileInfo) bool(nil), w.filters...)
+	c.regexpSet = make(map[string]regex)
+	for k, v := range w.regexpSet {
+		c.regexpSet[k] = v
+	}
+	return &c
+}
+
 func (w *DirWalk) assertMutable() {
 	CheckState(w.absFilesList == nil, "results already generated")
 }
 
 type patternCollection struct {
-	Include *Array[regex]
-	Omit    *Array[regex]
+	Include   *Array[regex]
+	Omit      *Array[regex]
+	OmitGlobs *Array[string]
 }
 
 func newPatternCollection() *patternCollection {
 	var p = new(patternCollection)
 	p.Include = NewArray[regex]()
 	p.Omit = NewArray[regex]()
+	p.OmitGlobs = NewArray[string]()
 	return p
 }
+
+func (p *patternCollection) copy() *patternCollection {
+	var c = newPatternCollection()
+	c.Include.Append(p.Include.Array()...)
+	c.Omit.Append(p.Omit.Array()...)
+	c.OmitGlobs.Append(p.OmitGlobs.Array()...)
+	return c
+}
@@ -3,7 +3,12 @@ package base_test
 import (
 	. "github.com/jpsember/golang-base/base"
 	"github.com/jpsember/golang-base/jt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 var _ = Pr
@@ -70,6 +75,139 @@ func TestIncludePrefixes(t *testing.T) {
 	assertWalk(j, w)
 }
 
+// Construct an in-memory tree, with files of various sizes
+func memTree(files map[string]string) (MemFileSystem, Path) {
+	fsys := NewMemFileSystem()
+	root := NewPathM("/tree")
+	for name, content := range files {
+		p := root.JoinM(name)
+		CheckOk(fsys.MkDirs(p.Parent()))
+		CheckOk(fsys.WriteFile(p, []byte(content), 0644))
+	}
+	return fsys, root
+}
+
+var sampleTree = map[string]string{
+	"main.go":              "package main",
+	"README.md":            "# readme",
+	"src/a.go":             "package src",
+	"src/a_test.go":        "package src_test",
+	"src/x/y/b.go":         "package y",
+	"src/x/y/notes.txt":    "",
+	"build/out.bin":        "0123456789",
+	"build/keep/stamp.txt": "x",
+	"docs/guide.md":        "guide",
+	"docs/draft/unused.md": "draft",
+	"docs/.gitignore":      "draft/\n*.tmp\n",
+	"docs/scratch.tmp":     "tmp",
+	".gitignore":           "build/\n*.log\n!important.log\n/README.md\n",
+	"debug.log":            "log",
+	"important.log":        "log",
+	"src/x/README.md":      "nested readme",
+}
+
+func relFiles(w *DirWalk) string {
+	var names []string
+	for _, p := range w.WithParallel(2).FilesRelative() {
+		names = append(names, p.String())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestDirWalkGlobs(t *testing.T) {
+	j := jt.New(t)
+	fsys, root := memTree(sampleTree)
+	w := NewDirWalk(root).WithFileSystem(fsys).WithRecurse().IncludeGlobs("src/**/*.go").OmitGlobs("*_test.go")
+	j.AssertEqual(relFiles(w), "src/a.go src/x/y/b.go")
+
+	w = NewDirWalk(root).WithFileSystem(fsys).WithRecurse().IncludeGlobs("*.md").ForDirs().OmitGlobs("docs/*")
+	j.AssertEqual(relFiles(w), "README.md docs/guide.md src/x/README.md")
+}
+
+func TestGlobMatch(t *testing.T) {
+	j := jt.New(t)
+	j.AssertTrue(GlobMatchM("**/*.go", "a.go"))
+	j.AssertTrue(GlobMatchM("a/**/b/*.txt", "a/b/c.txt"))
+	j.AssertTrue(GlobMatchM("a/**/b/*.txt", "a/x/y/b/c.txt"))
+	j.AssertFalse(GlobMatchM("a/*/b", "a/x/y/b"))
+	j.AssertTrue(GlobMatchM("a/**", "a/x/y"))
+	_, err := GlobMatch("a/[x", "a/x")
+	j.AssertTrue(err != nil)
+}
+
+func TestDirWalkIgnoreFiles(t *testing.T) {
+	j := jt.New(t)
+	fsys, root := memTree(sampleTree)
+	w := NewDirWalk(root).WithFileSystem(fsys).WithRecurse().WithIgnoreFiles(".gitignore")
+	j.AssertEqual(relFiles(w), ".gitignore docs/.gitignore docs/guide.md important.log main.go src/a.go "+
+		"src/a_test.go src/x/README.md src/x/y/b.go src/x/y/notes.txt")
+}
+
+func TestDirWalkSizeFilters(t *testing.T) {
+	j := jt.New(t)
+	fsys, root := memTree(sampleTree)
+	w := NewDirWalk(root).WithFileSystem(fsys).WithRecurse().WithMinSize(5).WithMaxSize(10).OmitGlobs(".*")
+	j.AssertEqual(relFiles(w), "README.md build/out.bin docs/draft/unused.md docs/guide.md src/x/y/b.go")
+}
+
+func TestDirWalkTimeAndModeFilters(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"old.sh", "old.txt", "new.sh", "new.txt"} {
+		p := dir.JoinM(name)
+		p.WriteStringM(name)
+		if strings.HasSuffix(name, ".sh") {
+			CheckOk(os.Chmod(p.String(), 0755))
+		}
+		if strings.HasPrefix(name, "old") {
+			CheckOk(os.Chtimes(p.String(), old, old))
+		}
+	}
+	cutoff := time.Now().Add(-time.Minute)
+	j.AssertEqual(relFiles(NewDirWalk(dir).ModifiedBefore(cutoff)), "old.sh old.txt")
+	j.AssertEqual(relFiles(NewDirWalk(dir).ModifiedAfter(cutoff)), "new.sh new.txt")
+	j.AssertEqual(relFiles(NewDirWalk(dir).WithMode(0111, 0111)), "new.sh old.sh")
+	j.AssertEqual(relFiles(NewDirWalk(dir).WithMode(fs.ModeType|0100, 0)), "new.txt old.txt")
+}
+
+func TestDirWalkSymlinks(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	dir.JoinM("a/b").MkDirsM()
+	dir.JoinM("a/b/file.txt").WriteStringM("hello")
+	dir.JoinM("target").MkDirsM()
+	dir.JoinM("target/t.txt").WriteStringM("target")
+	// A link to an ancestor (a cycle), one to an unrelated directory, and a broken one
+	CheckOk(os.Symlink(dir.JoinM("a").String(), dir.JoinM("a/b/loop").String()))
+	CheckOk(os.Symlink(dir.JoinM("target").String(), dir.JoinM("a/link").String()))
+	CheckOk(os.Symlink(dir.JoinM("missing").String(), dir.JoinM("a/broken").String()))
+
+	w := NewDirWalk(dir.JoinM("a")).WithRecurse()
+	j.AssertEqual(relFiles(w), "b/file.txt broken link/t.txt")
+
+	w = NewDirWalk(dir.JoinM("a")).WithRecurse().OmitSymlinkDirs()
+	j.AssertEqual(relFiles(w), "b/file.txt broken")
+}
+
+func TestDirWalkParallelMatchesSerial(t *testing.T) {
+	j := jt.New(t)
+	files := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		files["d"+IntToString(i%7)+"/e"+IntToString(i%5)+"/f"+IntToString(i)+".txt"] = "x"
+	}
+	fsys, root := memTree(files)
+	serial := NewDirWalk(root).WithFileSystem(fsys).WithRecurse().WithDirNames().Files()
+	sort.Slice(serial, func(a, b int) bool { return serial[a] < serial[b] })
+	for i := 0; i < 5; i++ {
+		parallel := NewDirWalk(root).WithFileSystem(fsys).WithRecurse().WithDirNames().WithParallel(0).Files()
+		j.AssertEqual(len(parallel), len(serial))
+		for k := range serial {
+			j.AssertEqual(parallel[k], serial[k])
+		}
+	}
+}
+
 //func TestAscendToDirectoryContainingFile(t *testing.T) {
 //	j := jt.New(t)
 //	_, err := AscendToDirectoryContainingFile(EmptyPath, "hello")
@@ -0,0 +1,165 @@
+package base
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Atomic (crash-safe) file writes, and advisory file locking
+
+// Write bytes to a file atomically: they are written to a temporary file in the same directory,
+// which is synced to disk and then renamed to replace the file, and the directory is then synced.
+// If the process crashes, the file holds either its old contents or its new ones.  If the file
+// already exists, its permissions are retained.
+func (path Path) WriteBytesAtomic(content []byte) (err error) {
+	target := path.AsNonEmptyString()
+	dir := filepath.Dir(target)
+	perm := os.FileMode(0644)
+	if info, statErr := os.Stat(target); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempName := temp.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tempName)
+		}
+	}()
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempName, target)
+	}
+	if err == nil {
+		err = syncDirectory(dir)
+	}
+	return err
+}
+
+func (path Path) WriteBytesAtomicM(content []byte) {
+	CheckOk(path.WriteBytesAtomic(content))
+}
+
+// Write a string to a file atomically; see WriteBytesAtomic
+func (path Path) WriteStringAtomic(content string) error {
+	return path.WriteBytesAtomic([]byte(content))
+}
+
+func (path Path) WriteStringAtomicM(content string) {
+	CheckOk(path.WriteStringAtomic(content))
+}
+
+// Sync a directory, so a rename within it is durable
+func syncDirectory(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && !syncDirectorySupported {
+		err = nil
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------------------
+// Advisory locks
+// ---------------------------------------------------------------------------------------
+
+// An exclusive advisory lock on a file, held by this process (e.g. to keep two processes of an
+// app from writing the same state file at once).  Other processes are only excluded if they
+// also use locks.  On platforms without flock (e.g. Windows), a lock only excludes other locks
+// on the same file within this process.
+//
+// The lock is held on a separate file, <path>.lock, since writing the file atomically replaces it.
+type FileLockStruct struct {
+	path Path
+	file *os.File
+}
+
+type FileLock = *FileLockStruct
+
+func (path Path) lockFile() (*os.File, error) {
+	return os.OpenFile(path.AsNonEmptyString()+".lock", os.O_CREATE|os.O_RDWR, 0644)
+}
+
+// Acquire a lock on a file, waiting until any other process holding it releases it
+func (path Path) Lock() (FileLock, error) {
+	f, err := path.lockFile()
+	if err == nil {
+		err = lockFile(f, true)
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return nil, Error("unable to lock:", path, INDENT, err)
+	}
+	return &FileLockStruct{path: path, file: f}, nil
+}
+
+func (path Path) LockM() FileLock {
+	return CheckOkWith(path.Lock())
+}
+
+// Attempt to acquire a lock on a file without waiting; returns nil if another process holds it
+func (path Path) TryLock() (FileLock, error) {
+	f, err := path.lockFile()
+	if err != nil {
+		return nil, Error("unable to lock:", path, INDENT, err)
+	}
+	if err = lockFile(f, false); err != nil {
+		f.Close()
+		if err == lockHeldError {
+			return nil, nil
+		}
+		return nil, Error("unable to lock:", path, INDENT, err)
+	}
+	return &FileLockStruct{path: path, file: f}, nil
+}
+
+// Release the lock
+func (lock FileLock) Unlock() error {
+	CheckState(lock.file != nil, "lock already released:", lock.path)
+	err := unlockFile(lock.file)
+	if closeErr := lock.file.Close(); err == nil {
+		err = closeErr
+	}
+	lock.file = nil
+	return err
+}
+
+func (lock FileLock) UnlockM() {
+	CheckOk(lock.Unlock())
+}
+
+// Call a function while holding the lock on a file
+func (path Path) WithLock(fn func() error) error {
+	lock, err := path.Lock()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if unlockErr := lock.Unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+var lockHeldError = Error("lock is held by another process")
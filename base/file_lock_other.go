@@ -0,0 +1,38 @@
+//go:build !unix
+
+package base
+
+import (
+	"os"
+	"sync"
+)
+
+// Directories can't be synced on some platforms (e.g. Windows)
+const syncDirectorySupported = false
+
+// Without flock, a lock only excludes other locks on the same file within this process
+
+var heldLocks = make(map[string]bool)
+var heldLocksLock sync.Mutex
+var heldLocksChanged = sync.NewCond(&heldLocksLock)
+
+func lockFile(f *os.File, wait bool) error {
+	heldLocksLock.Lock()
+	defer heldLocksLock.Unlock()
+	for heldLocks[f.Name()] {
+		if !wait {
+			return lockHeldError
+		}
+		heldLocksChanged.Wait()
+	}
+	heldLocks[f.Name()] = true
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	heldLocksLock.Lock()
+	defer heldLocksLock.Unlock()
+	delete(heldLocks, f.Name())
+	heldLocksChanged.Broadcast()
+	return nil
+}
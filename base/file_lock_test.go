@@ -0,0 +1,53 @@
+package base_test
+
+import (
+	"errors"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"os"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	file := dir.JoinM("state.json")
+	file.WriteStringAtomicM(`{"a":1}`)
+	j.AssertEqual(file.ReadStringM(), `{"a":1}`)
+
+	// Existing permissions are retained
+	file.ChmodM(0600)
+	file.WriteStringAtomicM(`{"a":2}`)
+	j.AssertEqual(file.ReadStringM(), `{"a":2}`)
+	info := CheckOkWith(os.Stat(file.String()))
+	j.AssertEqual(info.Mode().Perm(), os.FileMode(0600))
+
+	// No temporary files are left behind
+	j.AssertEqual(len(NewDirWalk(dir).Files()), 1)
+
+	j.AssertTrue(dir.JoinM("missing/state.json").WriteStringAtomic("x") != nil)
+	j.AssertEqual(len(NewDirWalk(dir).Files()), 1)
+}
+
+func TestFileLock(t *testing.T) {
+	j := jt.New(t)
+	file := j.GetTestResultsDir().JoinM("state.json")
+
+	lock := file.LockM()
+	other := CheckOkWith(file.TryLock())
+	j.AssertTrue(other == nil)
+	lock.UnlockM()
+
+	other = CheckOkWith(file.TryLock())
+	j.AssertTrue(other != nil)
+	other.UnlockM()
+
+	problem := errors.New("problem")
+	j.AssertEqual(file.WithLock(func() error {
+		j.AssertTrue(CheckOkWith(file.TryLock()) == nil)
+		return problem
+	}), problem)
+	other = CheckOkWith(file.TryLock())
+	j.AssertTrue(other != nil)
+	other.UnlockM()
+}
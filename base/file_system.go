@@ -0,0 +1,371 @@
+package base
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A filesystem, for the file operations performed by DirWalk, JSMapFromFileFS, CopyFileFS, and so on.
+// There are three implementations: OSFileSystem (the real one), in-memory filesystems (see
+// NewMemFileSystem), and read-only adapters for io/fs filesystems such as embed.FS (see NewFSAdapter).
+type FileSystem interface {
+	ReadFile(path Path) ([]byte, error)
+	WriteFile(path Path, content []byte, perm fs.FileMode) error
+	// Open a file for reading
+	Open(path Path) (io.ReadCloser, error)
+	// Create (or truncate) a file for writing
+	Create(path Path) (io.WriteCloser, error)
+	Stat(path Path) (fs.FileInfo, error)
+	// Read a directory's entries, sorted by name
+	ReadDir(dir Path) ([]fs.DirEntry, error)
+	// Create a directory, along with any missing parents
+	MkDirs(dir Path) error
+	// Remove a file or (empty) directory
+	Remove(path Path) error
+}
+
+// Determine if a path exists within a filesystem
+func ExistsFS(fsys FileSystem, path Path) bool {
+	_, err := fsys.Stat(path)
+	return err == nil
+}
+
+// Determine if a path is a directory within a filesystem
+func IsDirFS(fsys FileSystem, path Path) bool {
+	info, err := fsys.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ---------------------------------------------------------------------------------------
+// OS filesystem
+// ---------------------------------------------------------------------------------------
+
+type osFileSystem struct{}
+
+// The filesystem provided by the operating system
+var OSFileSystem FileSystem = osFileSystem{}
+
+func (osFileSystem) ReadFile(path Path) ([]byte, error) {
+	return os.ReadFile(path.AsNonEmptyString())
+}
+
+func (osFileSystem) WriteFile(path Path, content []byte, perm fs.FileMode) error {
+	return os.WriteFile(path.AsNonEmptyString(), content, perm)
+}
+
+func (osFileSystem) Open(path Path) (io.ReadCloser, error) {
+	return os.Open(path.AsNonEmptyString())
+}
+
+func (osFileSystem) Create(path Path) (io.WriteCloser, error) {
+	return os.Create(path.AsNonEmptyString())
+}
+
+func (osFileSystem) Stat(path Path) (fs.FileInfo, error) {
+	return os.Stat(path.AsNonEmptyString())
+}
+
+func (osFileSystem) ReadDir(dir Path) ([]fs.DirEntry, error) {
+	return os.ReadDir(dir.AsNonEmptyString())
+}
+
+func (osFileSystem) MkDirs(dir Path) error {
+	return os.MkdirAll(dir.AsNonEmptyString(), os.ModePerm)
+}
+
+func (osFileSystem) Remove(path Path) error {
+	return os.Remove(path.AsNonEmptyString())
+}
+
+// ---------------------------------------------------------------------------------------
+// In-memory filesystem
+// ---------------------------------------------------------------------------------------
+
+// A filesystem held in memory, e.g. for tests.  Paths are cleaned (see filepath.Clean) before use,
+// so relative and absolute paths are distinct; the root ("/") and current (".") directories
+// always exist.
+type MemFileSystemStruct struct {
+	lock    sync.RWMutex
+	entries map[string]*memEntry
+}
+
+type MemFileSystem = *MemFileSystemStruct
+
+type memEntry struct {
+	content []byte
+	mode    fs.FileMode
+	modTime time.Time
+	dir     bool
+}
+
+func NewMemFileSystem() MemFileSystem {
+	m := &MemFileSystemStruct{entries: make(map[string]*memEntry)}
+	for _, root := range []string{"/", "."} {
+		m.entries[root] = &memEntry{dir: true, mode: fs.ModeDir | 0755, modTime: time.Now()}
+	}
+	return m
+}
+
+func memKey(path Path) string {
+	return filepath.Clean(path.AsNonEmptyString())
+}
+
+func memPathError(op string, path Path, err error) error {
+	return &fs.PathError{Op: op, Path: path.String(), Err: err}
+}
+
+func (m MemFileSystem) ReadFile(path Path) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	e := m.entries[memKey(path)]
+	if e == nil {
+		return nil, memPathError("read", path, fs.ErrNotExist)
+	}
+	if e.dir {
+		return nil, memPathError("read", path, Error("is a directory"))
+	}
+	return append([]byte(nil), e.content...), nil
+}
+
+func (m MemFileSystem) WriteFile(path Path, content []byte, perm fs.FileMode) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := memKey(path)
+	if parent := m.entries[filepath.Dir(key)]; parent == nil || !parent.dir {
+		return memPathError("write", path, fs.ErrNotExist)
+	}
+	e := m.entries[key]
+	if e == nil {
+		e = &memEntry{mode: perm.Perm()}
+		m.entries[key] = e
+	} else if e.dir {
+		return memPathError("write", path, Error("is a directory"))
+	}
+	e.content = append([]byte(nil), content...)
+	e.modTime = time.Now()
+	return nil
+}
+
+func (m MemFileSystem) Open(path Path) (io.ReadCloser, error) {
+	content, err := m.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Content written to the returned writer is stored when it is closed
+func (m MemFileSystem) Create(path Path) (io.WriteCloser, error) {
+	if err := m.WriteFile(path, nil, 0644); err != nil {
+		return nil, err
+	}
+	return &memWriter{fsys: m, path: path}, nil
+}
+
+type memWriter struct {
+	fsys MemFileSystem
+	path Path
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	return w.fsys.WriteFile(w.path, w.buf.Bytes(), 0644)
+}
+
+func (m MemFileSystem) Stat(path Path) (fs.FileInfo, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	key := memKey(path)
+	e := m.entries[key]
+	if e == nil {
+		return nil, memPathError("stat", path, fs.ErrNotExist)
+	}
+	return &memFileInfo{name: filepath.Base(key), entry: *e}, nil
+}
+
+func (m MemFileSystem) ReadDir(dir Path) ([]fs.DirEntry, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	key := memKey(dir)
+	if e := m.entries[key]; e == nil || !e.dir {
+		return nil, memPathError("readdir", dir, fs.ErrNotExist)
+	}
+	var result []fs.DirEntry
+	for k, e := range m.entries {
+		if k != key && filepath.Dir(k) == key {
+			result = append(result, fs.FileInfoToDirEntry(&memFileInfo{name: filepath.Base(k), entry: *e}))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
+
+func (m MemFileSystem) MkDirs(dir Path) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := memKey(dir)
+	var missing []string
+	for {
+		e := m.entries[key]
+		if e != nil {
+			if !e.dir {
+				return memPathError("mkdir", dir, Error("not a directory:", key))
+			}
+			break
+		}
+		missing = append(missing, key)
+		key = filepath.Dir(key)
+	}
+	for _, k := range missing {
+		m.entries[k] = &memEntry{dir: true, mode: fs.ModeDir | 0755, modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m MemFileSystem) Remove(path Path) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := memKey(path)
+	e := m.entries[key]
+	if e == nil {
+		return memPathError("remove", path, fs.ErrNotExist)
+	}
+	if e.dir {
+		for k := range m.entries {
+			if k != key && filepath.Dir(k) == key {
+				return memPathError("remove", path, Error("directory not empty"))
+			}
+		}
+	}
+	delete(m.entries, key)
+	return nil
+}
+
+type memFileInfo struct {
+	name  string
+	entry memEntry
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return int64(len(i.entry.content)) }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.entry.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.entry.dir }
+func (i *memFileInfo) Sys() any           { return nil }
+
+// ---------------------------------------------------------------------------------------
+// Adapter for io/fs filesystems
+// ---------------------------------------------------------------------------------------
+
+type fsAdapter struct {
+	fsys fs.FS
+}
+
+// Construct a read-only FileSystem from an io/fs filesystem (e.g. an embed.FS).  Paths are relative to
+// the filesystem's root; a leading '/' is ignored.
+func NewFSAdapter(fsys fs.FS) FileSystem {
+	return fsAdapter{fsys: fsys}
+}
+
+var readOnlyError = Error("filesystem is read-only")
+
+// Convert a Path to the (unrooted, slash-separated) form used by io/fs
+func fsName(path Path) string {
+	name := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path.AsNonEmptyString())), "/")
+	if name == "" {
+		name = "."
+	}
+	return name
+}
+
+func (a fsAdapter) ReadFile(path Path) ([]byte, error) {
+	return fs.ReadFile(a.fsys, fsName(path))
+}
+
+func (a fsAdapter) WriteFile(path Path, content []byte, perm fs.FileMode) error {
+	return memPathError("write", path, readOnlyError)
+}
+
+func (a fsAdapter) Open(path Path) (io.ReadCloser, error) {
+	return a.fsys.Open(fsName(path))
+}
+
+func (a fsAdapter) Create(path Path) (io.WriteCloser, error) {
+	return nil, memPathError("create", path, readOnlyError)
+}
+
+func (a fsAdapter) Stat(path Path) (fs.FileInfo, error) {
+	return fs.Stat(a.fsys, fsName(path))
+}
+
+func (a fsAdapter) ReadDir(dir Path) ([]fs.DirEntry, error) {
+	return fs.ReadDir(a.fsys, fsName(dir))
+}
+
+func (a fsAdapter) MkDirs(dir Path) error {
+	return memPathError("mkdir", dir, readOnlyError)
+}
+
+func (a fsAdapter) Remove(path Path) error {
+	return memPathError("remove", path, readOnlyError)
+}
+
+// ---------------------------------------------------------------------------------------
+// Operations on files within a FileSystem
+// ---------------------------------------------------------------------------------------
+
+func ReadStringFS(fsys FileSystem, file Path) (string, error) {
+	content, err := fsys.ReadFile(file)
+	return string(content), err
+}
+
+func JSMapFromFileFS(fsys FileSystem, file Path) (JSMap, error) {
+	var result JSMap
+	content, err := ReadStringFS(fsys, file)
+	if err == nil {
+		result, err = JSMapFromString(content)
+	}
+	return result, err
+}
+
+func JSMapFromFileFSM(fsys FileSystem, file Path) JSMap {
+	return CheckOkWith(JSMapFromFileFS(fsys, file))
+}
+
+// Copy a file from one filesystem to another (or the same one).  If the destination exists, its
+// contents will be replaced.
+func CopyFileFS(sourceFS FileSystem, sourcePath Path, destFS FileSystem, destPath Path) (err error) {
+	in, err := sourceFS.Open(sourcePath)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := destFS.Create(destPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	if f, ok := out.(interface{ Sync() error }); ok {
+		err = f.Sync()
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package base_test
+
+import (
+	"errors"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func relativeNames(w *DirWalk) string {
+	var names []string
+	for _, p := range w.FilesRelative() {
+		names = append(names, p.String())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMemFileSystem(t *testing.T) {
+	j := jt.New(t)
+	m := NewMemFileSystem()
+	root := NewPathM("/work")
+	j.AssertTrue(m.WriteFile(root.JoinM("a.txt"), []byte("x"), 0644) != nil)
+	CheckOk(m.MkDirs(root.JoinM("sub/deeper")))
+	CheckOk(m.WriteFile(root.JoinM("b.txt"), []byte("hello"), 0644))
+	CheckOk(m.WriteFile(root.JoinM("a.txt"), []byte("x"), 0644))
+	CheckOk(m.WriteFile(root.JoinM("sub/c.json"), []byte(`{"c":3}`), 0644))
+
+	var names []string
+	for _, e := range CheckOkWith(m.ReadDir(root)) {
+		names = append(names, e.Name()+Ternary(e.IsDir(), "/", ""))
+	}
+	j.AssertEqual(strings.Join(names, " "), "a.txt b.txt sub/")
+
+	info := CheckOkWith(m.Stat(root.JoinM("b.txt")))
+	j.AssertEqual(info.Size(), int64(5))
+	j.AssertFalse(info.IsDir())
+	j.AssertTrue(IsDirFS(m, root.JoinM("sub")))
+	j.AssertFalse(ExistsFS(m, root.JoinM("missing")))
+
+	j.AssertEqual(JSMapFromFileFSM(m, root.JoinM("sub/c.json")).GetInt("c"), 3)
+	_, err := JSMapFromFileFS(m, root.JoinM("missing.json"))
+	j.AssertTrue(err != nil)
+
+	j.AssertTrue(m.Remove(root.JoinM("sub")) != nil)
+	CheckOk(m.Remove(root.JoinM("a.txt")))
+	j.AssertFalse(ExistsFS(m, root.JoinM("a.txt")))
+}
+
+func TestDirWalkMemFileSystem(t *testing.T) {
+	j := jt.New(t)
+
+	// Copy the sample directory into memory, and verify that walking each gives the same results
+	m := NewMemFileSystem()
+	source := sampleDir(j)
+	target := NewPathM("/sample")
+	for _, rel := range NewDirWalk(source).WithRecurse().WithDirNames().FilesRelative() {
+		if source.JoinPathM(rel).IsDir() {
+			CheckOk(m.MkDirs(target.JoinPathM(rel)))
+		} else {
+			CheckOk(m.MkDirs(target.JoinPathM(rel).Parent()))
+			CheckOk(CopyFileFS(OSFileSystem, source.JoinPathM(rel), m, target.JoinPathM(rel)))
+		}
+	}
+	expected := relativeNames(NewDirWalk(source).WithRecurse().OmitNamesWithSubstrings(`^\.`))
+	j.AssertEqual(relativeNames(NewDirWalk(target).WithFileSystem(m).WithRecurse().OmitNamesWithSubstrings(`^\.`)), expected)
+}
+
+func TestFSAdapter(t *testing.T) {
+	j := jt.New(t)
+	mapFS := fstest.MapFS{
+		"docs/readme.txt":     {Data: []byte("read me")},
+		"docs/config.json":    {Data: []byte(`{"port":80}`)},
+		"docs/images/a.png":   {Data: []byte{1, 2, 3}},
+		"resources/page.html": {Data: []byte("<html/>")},
+	}
+	a := NewFSAdapter(mapFS)
+	j.AssertEqual(relativeNames(NewDirWalk(NewPathM("docs")).WithFileSystem(a).WithRecurse()),
+		"config.json readme.txt images/a.png")
+	j.AssertEqual(JSMapFromFileFSM(a, NewPathM("/docs/config.json")).GetInt("port"), 80)
+	j.AssertTrue(a.WriteFile(NewPathM("docs/new.txt"), []byte("x"), 0644) != nil)
+
+	// Copy from the adapter to memory
+	m := NewMemFileSystem()
+	CheckOk(CopyFileFS(a, NewPathM("resources/page.html"), m, NewPathM("page.html")))
+	j.AssertEqual(CheckOkWith(ReadStringFS(m, NewPathM("page.html"))), "<html/>")
+
+	// An adapter for a real directory
+	d := NewFSAdapter(os.DirFS(sampleDir(j).String()))
+	entries := CheckOkWith(d.ReadDir(NewPathM(".")))
+	j.AssertTrue(len(entries) > 0)
+	_, err := d.Stat(NewPathM("no_such_file"))
+	j.AssertTrue(errors.Is(err, fs.ErrNotExist))
+}
@@ -0,0 +1,267 @@
+package base
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watches for changes to files, by periodically walking a directory (using a DirWalk, with whatever
+// patterns and filesystem it has been configured with) and comparing each file's size and modification
+// time (and optionally, a hash of its content) with those seen previously.  This doesn't use any
+// OS-specific notification APIs.
+//
+// Changes are reported to a callback once no further changes have been seen for a debounce period,
+// so that (for example) a burst of writes from an editor is reported once.
+type FileWatcherStruct struct {
+	BaseObject
+	walk     *DirWalk
+	callback func(events []FileEvent)
+	interval time.Duration
+	debounce time.Duration
+	withHash bool
+
+	lock       sync.Mutex
+	delivering bool
+	snapshot   map[Path]fileState
+	pending    map[Path]FileEventType
+	lastChange time.Time
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type FileWatcher = *FileWatcherStruct
+
+type FileEventType int
+
+const (
+	FileCreated FileEventType = iota
+	FileModified
+	FileDeleted
+)
+
+var fileEventNames = []string{"created", "modified", "deleted"}
+
+func (t FileEventType) String() string {
+	return fileEventNames[t]
+}
+
+type FileEvent struct {
+	Type FileEventType
+	Path Path
+}
+
+func (e FileEvent) String() string {
+	return e.Type.String() + " " + e.Path.String()
+}
+
+type fileState struct {
+	size    int64
+	modTime int64
+	hash    [sha256.Size]byte
+}
+
+// Construct a watcher for the files returned by a DirWalk (which is copied, so the caller's walk is
+// unaffected, and later changes to it don't affect the watcher).  The callback is called (from the
+// watcher's goroutine) with the changes, ordered by path.
+func NewFileWatcher(walk *DirWalk, callback func(events []FileEvent)) FileWatcher {
+	w := &FileWatcherStruct{
+		walk:     walk.copy(),
+		callback: callback,
+		interval: time.Second,
+		debounce: 250 * time.Millisecond,
+	}
+	w.SetName("FileWatcher")
+	return w
+}
+
+// Set how often the files are examined (default one second)
+func (w FileWatcher) WithInterval(interval time.Duration) FileWatcher {
+	w.assertNotStarted()
+	CheckArg(interval > 0)
+	w.interval = interval
+	return w
+}
+
+// Set how long to wait after a change, for further changes, before reporting them (default 250ms)
+func (w FileWatcher) WithDebounce(debounce time.Duration) FileWatcher {
+	w.assertNotStarted()
+	w.debounce = debounce
+	return w
+}
+
+// Compare files' contents as well, to detect changes that don't affect their size or modification time
+func (w FileWatcher) WithContentHash() FileWatcher {
+	w.assertNotStarted()
+	w.withHash = true
+	return w
+}
+
+func (w FileWatcher) assertNotStarted() {
+	CheckState(w.stop == nil, "watcher already started")
+}
+
+// Take an initial snapshot of the files, and start watching them for changes; the directory must exist
+func (w FileWatcher) Start() FileWatcher {
+	w.assertNotStarted()
+	w.snapshot = w.takeSnapshot()
+	w.pending = make(map[Path]FileEventType)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run()
+	return w
+}
+
+// Stop watching; any changes not yet reported are discarded, and the callback isn't called again.
+// This waits for the watcher's goroutine to finish, unless the callback is running (in which case
+// it may be the caller, since a callback can stop its watcher), so the callback may still be
+// running when this returns.
+func (w FileWatcher) Stop() {
+	CheckState(w.stop != nil, "watcher not started")
+	w.lock.Lock()
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	delivering := w.delivering
+	w.lock.Unlock()
+	if !delivering {
+		<-w.done
+	}
+}
+
+func (w FileWatcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			if events := w.poll(now); len(events) != 0 {
+				w.deliver(events)
+			}
+		}
+	}
+}
+
+func (w FileWatcher) deliver(events []FileEvent) {
+	w.lock.Lock()
+	select {
+	case <-w.stop:
+		// Stopped while examining the files
+		w.lock.Unlock()
+		return
+	default:
+		w.delivering = true
+	}
+	w.lock.Unlock()
+	defer func() {
+		w.lock.Lock()
+		w.delivering = false
+		w.lock.Unlock()
+	}()
+	defer CatchPanic(func() {
+		Pr("Caught panic in FileWatcher callback")
+	})
+	w.callback(events)
+}
+
+// Examine the files, and return any changes that are ready to be reported
+func (w FileWatcher) poll(now time.Time) []FileEvent {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var current map[Path]fileState
+	if err := catchPanicAsError(func() { current = w.takeSnapshot() }); err != nil {
+		// The directory might be temporarily unavailable; try again later
+		w.Log("problem examining files:", err)
+		return nil
+	}
+	changes := diffSnapshots(w.snapshot, current)
+	w.snapshot = current
+	if len(changes) != 0 {
+		w.lastChange = now
+		for _, e := range changes {
+			w.addPending(e)
+		}
+	}
+	if len(w.pending) == 0 || now.Sub(w.lastChange) < w.debounce {
+		return nil
+	}
+	var events []FileEvent
+	for p, t := range w.pending {
+		events = append(events, FileEvent{Type: t, Path: p})
+	}
+	sortFileEvents(events)
+	w.pending = make(map[Path]FileEventType)
+	return events
+}
+
+// Combine a change with any earlier unreported change to the same file
+func (w FileWatcher) addPending(e FileEvent) {
+	prev, found := w.pending[e.Path]
+	if !found {
+		w.pending[e.Path] = e.Type
+		return
+	}
+	switch {
+	case prev == FileCreated && e.Type == FileDeleted:
+		// The file came and went, so there's nothing to report
+		delete(w.pending, e.Path)
+	case prev == FileCreated:
+		// Still a new file
+	case prev == FileDeleted && e.Type == FileCreated:
+		w.pending[e.Path] = FileModified
+	default:
+		w.pending[e.Path] = e.Type
+	}
+}
+
+func (w FileWatcher) takeSnapshot() map[Path]fileState {
+	w.walk.rewind()
+	fsys := w.walk.fileSystem
+	result := make(map[Path]fileState)
+	for _, p := range w.walk.Files() {
+		info, err := fsys.Stat(p)
+		if err != nil {
+			// The file was deleted since the walk
+			continue
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+		if w.withHash && !info.IsDir() {
+			if content, err := fsys.ReadFile(p); err == nil {
+				state.hash = sha256.Sum256(content)
+			}
+		}
+		result[p] = state
+	}
+	return result
+}
+
+// Determine the changes between two snapshots, ordered by path
+func diffSnapshots(before map[Path]fileState, after map[Path]fileState) []FileEvent {
+	var events []FileEvent
+	for p, s := range after {
+		prev, found := before[p]
+		if !found {
+			events = append(events, FileEvent{Type: FileCreated, Path: p})
+		} else if prev != s {
+			events = append(events, FileEvent{Type: FileModified, Path: p})
+		}
+	}
+	for p := range before {
+		if _, found := after[p]; !found {
+			events = append(events, FileEvent{Type: FileDeleted, Path: p})
+		}
+	}
+	sortFileEvents(events)
+	return events
+}
+
+func sortFileEvents(events []FileEvent) {
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+}
@@ -0,0 +1,162 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type watchFixture struct {
+	j      jt.JTest
+	fsys   MemFileSystem
+	dir    Path
+	events chan []FileEvent
+}
+
+func newWatchFixture(j jt.JTest) *watchFixture {
+	f := &watchFixture{j: j, fsys: NewMemFileSystem(), dir: NewPathM("/site"), events: make(chan []FileEvent, 10)}
+	CheckOk(f.fsys.MkDirs(f.dir.JoinM("resources")))
+	f.write("header.html", "<h1>")
+	f.write("resources/a.png", "png")
+	f.write("notes.tmp", "scratch")
+	return f
+}
+
+func (f *watchFixture) write(name string, content string) {
+	CheckOk(f.fsys.WriteFile(f.dir.JoinM(name), []byte(content), 0644))
+}
+
+func (f *watchFixture) start(configure func(w FileWatcher)) FileWatcher {
+	walk := NewDirWalk(f.dir).WithFileSystem(f.fsys).WithRecurse().OmitNames(`.*\.tmp`)
+	w := NewFileWatcher(walk, func(events []FileEvent) { f.events <- events }).
+		WithInterval(5 * time.Millisecond).WithDebounce(30 * time.Millisecond)
+	if configure != nil {
+		configure(w)
+	}
+	return w.Start()
+}
+
+// Wait for the next batch of events, and describe them
+func (f *watchFixture) next() string {
+	select {
+	case events := <-f.events:
+		var s []string
+		for _, e := range events {
+			s = append(s, e.Type.String()+" "+strings.TrimPrefix(e.Path.String(), f.dir.String()+"/"))
+		}
+		return strings.Join(s, ", ")
+	case <-time.After(2 * time.Second):
+		return "(timed out)"
+	}
+}
+
+func (f *watchFixture) assertNoEvents() {
+	select {
+	case events := <-f.events:
+		f.j.FailWithMessage("unexpected events:", events)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	j := jt.New(t)
+	f := newWatchFixture(j)
+	w := f.start(nil)
+	defer w.Stop()
+
+	f.assertNoEvents()
+
+	f.write("header.html", "<h1>changed")
+	f.write("footer.html", "<footer>")
+	CheckOk(f.fsys.Remove(f.dir.JoinM("resources/a.png")))
+	// Files omitted by the walk's patterns aren't reported
+	f.write("notes.tmp", "more scratch")
+	j.AssertEqual(f.next(), "created footer.html, modified header.html, deleted resources/a.png")
+	f.assertNoEvents()
+}
+
+func TestFileWatcherDebounce(t *testing.T) {
+	j := jt.New(t)
+	f := newWatchFixture(j)
+	w := f.start(func(w FileWatcher) { w.WithDebounce(150 * time.Millisecond) })
+	defer w.Stop()
+
+	// A burst of changes is reported once, with changes to the same file combined
+	for i := 0; i < 5; i++ {
+		f.write("header.html", "<h1>"+IntToString(i))
+		f.write("draft.html", "draft")
+		time.Sleep(10 * time.Millisecond)
+	}
+	CheckOk(f.fsys.Remove(f.dir.JoinM("draft.html")))
+	j.AssertEqual(f.next(), "modified header.html")
+	f.assertNoEvents()
+}
+
+func TestFileWatcherContentHash(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	file := dir.JoinM("header.html")
+	file.WriteStringM("<h1>")
+	info := CheckOkWith(os.Stat(file.String()))
+
+	for _, withHash := range []bool{false, true} {
+		events := make(chan []FileEvent, 10)
+		w := NewFileWatcher(NewDirWalk(dir), func(e []FileEvent) { events <- e }).
+			WithInterval(5 * time.Millisecond).WithDebounce(0)
+		if withHash {
+			w.WithContentHash()
+		}
+		w.Start()
+
+		// Change the content without changing the size or modification time
+		file.WriteStringM(Ternary(withHash, "<h3>", "<h2>"))
+		CheckOk(os.Chtimes(file.String(), info.ModTime(), info.ModTime()))
+
+		var reported bool
+		select {
+		case <-events:
+			reported = true
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Stop()
+		j.AssertEqual(reported, withHash)
+	}
+}
+
+func TestFileWatcherStopFromCallback(t *testing.T) {
+	j := jt.New(t)
+	f := newWatchFixture(j)
+	stopped := make(chan bool)
+	var w FileWatcher
+	w = NewFileWatcher(NewDirWalk(f.dir).WithFileSystem(f.fsys), func(events []FileEvent) {
+		w.Stop()
+		stopped <- true
+	}).WithInterval(5 * time.Millisecond).WithDebounce(0).Start()
+
+	f.write("header.html", "<h1>changed")
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		j.FailWithMessage("Stop didn't return")
+	}
+	// Stopping again is harmless
+	w.Stop()
+}
+
+func TestFileWatcherCopiesWalk(t *testing.T) {
+	j := jt.New(t)
+	f := newWatchFixture(j)
+	walk := NewDirWalk(f.dir).WithFileSystem(f.fsys)
+	files := len(walk.Files())
+	w := NewFileWatcher(walk, func(events []FileEvent) { f.events <- events }).
+		WithInterval(5 * time.Millisecond).WithDebounce(0).Start()
+	defer w.Stop()
+
+	f.write("footer.html", "<footer>")
+	j.AssertEqual(f.next(), "created footer.html")
+	// The caller's walk still has its original results
+	j.AssertEqual(len(walk.Files()), files)
+}
@@ -1,7 +1,6 @@
 package base
 
 import (
-	"io"
 	"os"
 )
 
@@ -102,16 +101,92 @@ func CurrentDirectory() Path {
 }
 
 func JSMapFromFile(file Path) (JSMap, error) {
+	return JSMapFromFileFS(OSFileSystem, file)
+}
+
+func JSMapFromFileM(file Path) JSMap {
+	return CheckOkWith(JSMapFromFile(file))
+}
+
+// Read a JSMap from a file using the parser's relaxed mode (see JSONParser.WithRelaxed)
+func JSMapFromFileRelaxed(file Path) (JSMap, error) {
 	var result JSMap
 	content, err := file.ReadString()
 	if err == nil {
-		result, err = JSMapFromString(content)
+		result, err = JSMapFromStringRelaxed(content)
 	}
 	return result, err
 }
 
-func JSMapFromFileM(file Path) JSMap {
-	return CheckOkWith(JSMapFromFile(file))
+func JSMapFromFileRelaxedM(file Path) JSMap {
+	return CheckOkWith(JSMapFromFileRelaxed(file))
+}
+
+func JSMapFromYamlFile(file Path) (JSMap, error) {
+	var result JSMap
+	content, err := file.ReadString()
+	if err == nil {
+		result, err = JSMapFromYaml(content)
+	}
+	return result, err
+}
+
+func JSMapFromYamlFileM(file Path) JSMap {
+	return CheckOkWith(JSMapFromYamlFile(file))
+}
+
+func WriteYamlFile(file Path, e JSEntity) error {
+	return file.WriteString(ToYaml(e))
+}
+
+func WriteYamlFileM(file Path, e JSEntity) {
+	CheckOk(WriteYamlFile(file, e))
+}
+
+func JSMapFromTomlFile(file Path) (JSMap, error) {
+	var result JSMap
+	content, err := file.ReadString()
+	if err == nil {
+		result, err = JSMapFromToml(content)
+	}
+	return result, err
+}
+
+func JSMapFromTomlFileM(file Path) JSMap {
+	return CheckOkWith(JSMapFromTomlFile(file))
+}
+
+func WriteTomlFile(file Path, m JSMap) error {
+	content, err := ToToml(m)
+	if err == nil {
+		err = file.WriteString(content)
+	}
+	return err
+}
+
+func WriteTomlFileM(file Path, m JSMap) {
+	CheckOk(WriteTomlFile(file, m))
+}
+
+func JSMapFromCborFile(file Path) (JSMap, error) {
+	var result JSMap
+	content, err := file.ReadBytes()
+	if err == nil {
+		result, err = JSMapFromCbor(content)
+	}
+	return result, err
+}
+
+func JSMapFromCborFileM(file Path) JSMap {
+	return CheckOkWith(JSMapFromCborFile(file))
+}
+
+func WriteCborFile(file Path, e JSEntity) error {
+	return file.WriteBytes(EncodeCbor(e))
+}
+
+func WriteCborFileM(file Path, e JSEntity) {
+	CheckOk(WriteCborFile(file, e))
 }
 
 func JSMapFromFileIfExists(file Path) (JSMap, error) {
@@ -124,30 +199,8 @@ func JSMapFromFileIfExistsM(file Path) JSMap {
 }
 
 // Copies file.  If destination exists, its contents will be replaced.
-func CopyFile(sourcePath Path, destPath Path) (err error) {
-	src := sourcePath.String()
-	dst := destPath.String()
-
-	in, err := os.Open(src)
-	if err != nil {
-		return
-	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return
-	}
-	defer func() {
-		cerr := out.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-	if _, err = io.Copy(out, in); err != nil {
-		return
-	}
-	err = out.Sync()
-	return
+func CopyFile(sourcePath Path, destPath Path) error {
+	return CopyFileFS(OSFileSystem, sourcePath, OSFileSystem, destPath)
 }
 
 func FindProjectDirM() Path {
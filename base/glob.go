@@ -0,0 +1,142 @@
+package base
+
+import (
+	"path"
+	"strings"
+)
+
+// Determine if a slash-separated path matches a glob pattern.  Within each segment of the pattern,
+// the syntax is that of path.Match ('*', '?', and '[...]'); a segment "**" matches zero or more
+// entire segments, e.g. "src/**/*.go" matches "src/a.go" and "src/x/y/b.go".
+func GlobMatch(pattern string, relPath string) (bool, error) {
+	if err := validateGlob(pattern); err != nil {
+		return false, err
+	}
+	return globSegmentsMatch(strings.Split(pattern, "/"), strings.Split(relPath, "/")), nil
+}
+
+func GlobMatchM(pattern string, relPath string) bool {
+	return CheckOkWith(GlobMatch(pattern, relPath))
+}
+
+func validateGlob(pattern string) error {
+	for _, seg := range strings.Split(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return Error("bad glob pattern:", Quoted(pattern))
+		}
+	}
+	return nil
+}
+
+func globSegmentsMatch(pat []string, segs []string) bool {
+	for len(pat) != 0 {
+		if pat[0] == "**" {
+			// Skip any redundant "**" segments, then try matching the rest at each position
+			for len(pat) != 0 && pat[0] == "**" {
+				pat = pat[1:]
+			}
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segs); i++ {
+				if globSegmentsMatch(pat, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pat[0], segs[0]); !matched {
+			return false
+		}
+		pat = pat[1:]
+		segs = segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// ---------------------------------------------------------------------------------------
+// Ignore files (.gitignore syntax)
+// ---------------------------------------------------------------------------------------
+
+// A rule from an ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// The rules from the ignore file within a directory, linked to those of its parent directories
+type ignoreRules struct {
+	parent *ignoreRules
+	// The directory containing the ignore file, relative to the walk's start directory ("" if it is the start directory)
+	baseDir string
+	rules   []ignoreRule
+}
+
+// Parse the contents of an ignore file, using the syntax of .gitignore files (see https://git-scm.com/docs/gitignore):
+// blank lines and lines starting with '#' are skipped; a leading '!' re-includes a path excluded by an earlier rule;
+// a trailing '/' restricts a rule to directories; a pattern containing a '/' (other than a trailing one) is
+// relative to the ignore file's directory, otherwise it matches a name at any depth.
+func parseIgnoreRules(content string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// An escaped leading '#' or '!'
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if err := validateGlob(line); err != nil {
+			return nil, err
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Determine if a path (relative to the walk's start directory) is ignored.  Rules in deeper ignore files
+// take precedence over those in shallower ones, and within a file, later rules take precedence over earlier ones.
+func (r *ignoreRules) ignores(relPath string, isDir bool) bool {
+	for ; r != nil; r = r.parent {
+		rel := relPath
+		if r.baseDir != "" {
+			rel = strings.TrimPrefix(relPath, r.baseDir+"/")
+		}
+		for i := len(r.rules) - 1; i >= 0; i-- {
+			rule := r.rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			subject := rel
+			if !rule.anchored {
+				subject = path.Base(rel)
+			}
+			if globSegmentsMatch(strings.Split(rule.pattern, "/"), strings.Split(subject, "/")) {
+				return !rule.negate
+			}
+		}
+	}
+	return false
+}
@@ -39,8 +39,13 @@ func ParseEnumFromMap(enumInfo *EnumInfo, m JSMap, key string, defaultValue int)
 	return result
 }
 
+// Parse a DataClass (applying any migrations from the shared registry), returning an error if this fails
 func ParseOrDefault(json JSEntity, defaultValue DataClass) (DataClass, error) {
 	var err error
+	json, err = SharedMigrationRegistry().Migrate(defaultValue, json)
+	if err != nil {
+		return nil, err
+	}
 	result := attemptParse(json, defaultValue)
 	if result == nil {
 		err = DataClassParseError
@@ -58,3 +63,81 @@ func attemptParse(json JSEntity, parser DataClass) DataClass {
 	}()
 	return parser.Parse(json)
 }
+
+// ---------------------------------------------------------------------------------------
+// Support for generated data classes
+// ---------------------------------------------------------------------------------------
+
+// The types of the elements of lists and maps within data classes that aren't themselves data classes
+type DataPrimitive interface {
+	bool | byte | int | int64 | float32 | float64 | string
+}
+
+func primitiveFrom[T DataPrimitive](e JSEntity) T {
+	var result T
+	switch p := any(&result).(type) {
+	case *bool:
+		*p = e.AsBool()
+	case *byte:
+		*p = byte(e.AsInteger())
+	case *int:
+		*p = int(e.AsInteger())
+	case *int64:
+		*p = e.AsInteger()
+	case *float32:
+		*p = float32(e.AsFloat())
+	case *float64:
+		*p = e.AsFloat()
+	case *string:
+		*p = e.AsString()
+	}
+	return result
+}
+
+// Parse a list of primitive values; returns nil if the list is nil
+func ParseListOf[T DataPrimitive](list JSList) []T {
+	if list == nil {
+		return nil
+	}
+	result := make([]T, 0, list.Length())
+	for _, e := range list.wrappedList {
+		result = append(result, primitiveFrom[T](e))
+	}
+	return result
+}
+
+// Parse a map of primitive values; returns nil if the map is nil
+func ParseMapOf[T DataPrimitive](m JSMap) map[string]T {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]T, len(m.wrappedMap))
+	for k, e := range m.wrappedMap {
+		result[k] = primitiveFrom[T](e)
+	}
+	return result
+}
+
+// Parse a list of data classes; returns nil if the list is nil
+func ParseDataClassList[T DataClass](list JSList, parser DataClass) []T {
+	if list == nil {
+		return nil
+	}
+	result := make([]T, 0, list.Length())
+	for _, e := range list.wrappedList {
+		result = append(result, parser.Parse(e).(T))
+	}
+	return result
+}
+
+// Parse a map of data classes; returns nil if the map is nil
+func ParseDataClassMap[T DataClass](m JSMap, parser DataClass) map[string]T {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]T, len(m.wrappedMap))
+	for k, e := range m.wrappedMap {
+		result[k] = parser.Parse(e).(T)
+	}
+	return result
+}
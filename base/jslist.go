@@ -76,6 +76,7 @@ func NewJSList() JSList {
 }
 
 func (p *JSONParser) ParseList() (JSList, error) {
+	p.enterContainer()
 	p.adjustNest(1)
 	var result []JSEntity
 	p.ReadExpectedByte('[')
@@ -84,15 +85,23 @@ func (p *JSONParser) ParseList() (JSList, error) {
 		if p.readIf(']') {
 			break
 		}
+		start := p.cursor
 		if commaExpected {
 			p.ReadExpectedByte(',')
 			if p.readIf(']') {
 				break
 			}
 			commaExpected = false
+			if !p.hasProblem() {
+				start = p.cursor
+			}
 		}
+		p.segment = IntToString(len(result))
 		elem := p.readValue()
 		if p.hasProblem() {
+			if ok, closed := p.recoverFrom(start); ok && !closed {
+				continue
+			}
 			break
 		}
 		result = append(result, elem)
@@ -100,6 +109,7 @@ func (p *JSONParser) ParseList() (JSList, error) {
 	}
 	p.skipWhitespace()
 	p.adjustNest(-1)
+	p.exitContainer()
 	var jsList JSList
 	if p.Error == nil {
 		jsList = new(JSListStruct)
@@ -120,6 +130,33 @@ func (js JSList) Get(index int) JSEntity {
 	return js.wrappedList[index]
 }
 
+// Replace the element at an index
+func (js JSList) Set(index int, value any) JSList {
+	if value == nil {
+		BadArg("value is nil")
+	}
+	js.wrappedList[index] = ToJSEntity(value)
+	return js
+}
+
+// Insert an element at an index, moving any following elements up by one
+func (js JSList) Insert(index int, value any) JSList {
+	if value == nil {
+		BadArg("value is nil")
+	}
+	w := append(js.wrappedList, nil)
+	copy(w[index+1:], w[index:])
+	w[index] = ToJSEntity(value)
+	js.wrappedList = w
+	return js
+}
+
+// Remove the element at an index, moving any following elements down by one
+func (js JSList) Remove(index int) JSList {
+	js.wrappedList = DeleteSliceElements(js.wrappedList, index, 1)
+	return js
+}
+
 func (js JSList) AsMaps() []JSMap {
 	var x []JSMap
 	for _, y := range js.wrappedList {
@@ -140,3 +177,13 @@ func (js JSList) AsByteArray() []byte {
 	}
 	return res
 }
+
+func JSListFromString(content string) (JSList, error) {
+	var p JSONParser
+	p.WithText(content)
+	return p.ParseList()
+}
+
+func JSListFromStringM(content string) JSList {
+	return CheckOkWith(JSListFromString(content))
+}
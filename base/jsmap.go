@@ -40,7 +40,20 @@ func (m JSMap) AsJSList() JSList {
 
 func (m JSMap) PrintTo(context *JSONPrinter) {
 	var s = context.StringBuilder
-	if context.Pretty {
+	if context.Canonical {
+		s.WriteByte('{')
+		keys, _ := GetMapKeysAndValues(m.wrappedMap)
+		canonicalKeyOrder(keys)
+		for index, key := range keys {
+			if index != 0 {
+				s.WriteByte(',')
+			}
+			writeCanonicalString(s, key)
+			s.WriteByte(':')
+			m.wrappedMap[key].PrintTo(context)
+		}
+		s.WriteByte('}')
+	} else if context.Pretty {
 		m.prettyPrintWithIndent(context)
 	} else {
 		entries := m.Entries()
@@ -123,6 +136,15 @@ func NewJSMap() JSMap {
 	return m.Clear()
 }
 
+// Construct a JSMap from a Go map, converting each value with ToJSEntity
+func JSMapWith[T any](values map[string]T) JSMap {
+	var m = NewJSMap()
+	for k, v := range values {
+		m.wrappedMap[k] = ToJSEntity(v)
+	}
+	return m
+}
+
 // Implements the fmt.Stringer interface.  By default, we perform
 // a pretty print of the JSMapStruct.  This simplifies a lot of things.
 func (m JSMap) String() string {
@@ -170,6 +192,7 @@ func (m JSMap) PutNumberedKey(key string, value any) *JSMapStruct {
 }
 
 func (p *JSONParser) ParseMap() (*JSMapStruct, error) {
+	p.enterContainer()
 	p.adjustNest(1)
 	var ourMap = make(map[string]JSEntity)
 	p.ReadExpectedByte('{')
@@ -178,19 +201,32 @@ func (p *JSONParser) ParseMap() (*JSMapStruct, error) {
 		if p.readIf('}') {
 			break
 		}
+		start := p.cursor
 		if commaExpected {
 			p.ReadExpectedByte(',')
 			if p.readIf('}') {
 				break
 			}
 			commaExpected = false
+			if !p.hasProblem() {
+				start = p.cursor
+			}
 		}
-		key := p.readString()
+		key := p.readKey()
 		p.ReadExpectedByte(':')
-		ourMap[key] = p.readValue()
+		p.segment = key
+		value := p.readValue()
+		if p.hasProblem() {
+			if ok, closed := p.recoverFrom(start); ok && !closed {
+				continue
+			}
+			break
+		}
+		ourMap[key] = value
 		commaExpected = true
 	}
 	p.adjustNest(-1)
+	p.exitContainer()
 	var jsMap *JSMapStruct
 	if p.Error == nil {
 		jsMap = NewJSMap()
@@ -356,6 +392,17 @@ func JSMapFromStringM(content string) JSMap {
 	return CheckOkWith(JSMapFromString(content))
 }
 
+// Parse a JSMap using the parser's relaxed mode (see JSONParser.WithRelaxed)
+func JSMapFromStringRelaxed(content string) (JSMap, error) {
+	var p JSONParser
+	p.WithRelaxed(true).WithText(content)
+	return p.ParseMap()
+}
+
+func JSMapFromStringRelaxedM(content string) JSMap {
+	return CheckOkWith(JSMapFromStringRelaxed(content))
+}
+
 func (m JSMap) WrappedMap() map[string]JSEntity {
 	return m.wrappedMap
 }
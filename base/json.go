@@ -3,7 +3,11 @@ package base
 import (
 	"encoding/base64"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // ---------------------------------------------------------------------------------------
@@ -53,6 +57,10 @@ func MakeJString(value string) JSEntity {
 }
 
 func (v JString) PrintTo(context *JSONPrinter) {
+	if context.Canonical {
+		writeCanonicalString(context.StringBuilder, string(v))
+		return
+	}
 	context.WriteString(EscapedAndQuoted(string(v)))
 }
 
@@ -124,6 +132,10 @@ func MakeJFloat(value float64) JSEntity {
 }
 
 func (v JFloat) PrintTo(context *JSONPrinter) {
+	if context.Canonical {
+		context.WriteString(canonicalFloat(float64(v)))
+		return
+	}
 	// We could print fewer fractional digits by e.g. %.3f
 	var text = fmt.Sprintf("%f", float64(v))
 	context.WriteString(text)
@@ -237,6 +249,42 @@ func (v JNull) AsJSList() JSList {
 	panic("Not supported")
 }
 
+// -------------------------------------------------------------------------------
+// Json type: byte array
+//
+// JSON has no byte array type, so these are printed as strings (using EncodeBase64), and
+// behave as such strings in most other respects; binary encodings (e.g. CBOR) store them natively.
+
+type JBytes []byte
+
+func (v JBytes) PrintTo(context *JSONPrinter) {
+	JString(EncodeBase64(v)).PrintTo(context)
+}
+
+func (v JBytes) AsInteger() int64 {
+	panic("Not supported")
+}
+
+func (v JBytes) AsFloat() float64 {
+	panic("Not supported")
+}
+
+func (v JBytes) AsString() string {
+	return EncodeBase64(v)
+}
+
+func (v JBytes) AsBool() bool {
+	panic("Not supported")
+}
+
+func (v JBytes) AsJSMap() JSMap {
+	panic("Not supported")
+}
+
+func (v JBytes) AsJSList() JSList {
+	panic("Not supported")
+}
+
 // -------------------------------------------------------------------------------
 
 func EscapedAndQuoted(str string) string {
@@ -262,6 +310,12 @@ func Escaped(str string) string {
 			out, c = append(out, ESCAPE), 't'
 		default:
 			if c < ' ' || c > 126 {
+				// Characters outside the basic multilingual plane are written as a surrogate pair
+				if r1, r2 := utf16.EncodeRune(c); r1 != utf8.RuneError {
+					out = append(out, ESCAPE, 'u')
+					out = toHex(out, int(r1), 4)
+					c = r2
+				}
 				out = append(out, ESCAPE)
 				out = append(out, 'u')
 				out, c = toHex(out, int(c), 4), 0
@@ -311,11 +365,19 @@ func ToJSEntity(value any) JSEntity {
 	case int64:
 		val = MakeJInteger(v)
 	case uint64:
-		val = MakeJInteger(int64(v))
+		if v > math.MaxInt64 {
+			val = JNumber(strconv.FormatUint(v, 10))
+		} else {
+			val = MakeJInteger(int64(v))
+		}
 	case float32:
 		val = MakeJFloat(float64(v))
 	case float64:
 		val = MakeJFloat(v)
+	case *big.Int:
+		val = JNumber(v.String())
+	case *big.Float:
+		val = JNumber(v.Text('g', -1))
 	case string:
 		val = MakeJString(v)
 	case bool:
@@ -325,7 +387,12 @@ func ToJSEntity(value any) JSEntity {
 	case DataClass:
 		val = v.ToJson().(JSEntity)
 	default:
-		Die("Unsupported:", Info(value))
+		// Fall back on reflection for structs, slices, maps, and so on
+		var err error
+		val, err = Marshal(value)
+		if err != nil {
+			Die("Unsupported:", Info(value), err)
+		}
 	}
 	return val
 }
@@ -377,6 +444,9 @@ func ParseBase64(s string) []byte {
  * stored in a space-saving base64 form.
  */
 func DecodeBase64Maybe(ent JSEntity) []byte {
+	if arr, ok := ent.(JBytes); ok {
+		return arr
+	}
 	if arr, ok := ent.(JString); ok {
 		return ParseBase64(arr.AsString())
 	}
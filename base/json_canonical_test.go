@@ -0,0 +1,56 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+func TestCanonicalKeyOrder(t *testing.T) {
+	j := jt.New(t)
+	// The example from RFC 8785, section 3.2.3
+	m := NewJSMap()
+	for i, k := range []string{"\u20ac", "\r", "\ufb33", "1", "\U0001F600", "\u0080", "\u00f6"} {
+		m.Put(k, i)
+	}
+	j.AssertEqual(CanonicalJSON(m), "{\"\\r\":1,\"1\":3,\"\u0080\":5,\"\u00f6\":6,\"\u20ac\":0,\"\U0001F600\":4,\"\ufb33\":2}")
+}
+
+func TestCanonicalNumbers(t *testing.T) {
+	j := jt.New(t)
+	cases := []any{
+		0.0, "0",
+		-0.0, "0",
+		4.50, "4.5",
+		2e-3, "0.002",
+		100.0, "100",
+		0.000001, "0.000001",
+		1e-7, "1e-7",
+		1e21, "1e+21",
+		1e20, "100000000000000000000",
+		333333333.33333329, "333333333.3333333",
+		-1.5e-300, "-1.5e-300",
+		42, "42",
+	}
+	for i := 0; i < len(cases); i += 2 {
+		j.AssertEqual(CanonicalJSON(ToJSEntity(cases[i])), cases[i+1])
+	}
+}
+
+func TestCanonicalStrings(t *testing.T) {
+	j := jt.New(t)
+	j.AssertEqual(CanonicalJSON(JString("a\"b\\c\n\u0001\u007f/é€")), "\"a\\\"b\\\\c\\n\\u0001\u007f/é€\"")
+}
+
+func TestCanonicalIgnoresInsertionOrder(t *testing.T) {
+	j := jt.New(t)
+	a := JSMapFromStringM(`{"b":[1,2.0,{"y":true,"x":null}],"a":"hello"}`)
+	b := JSMapFromStringM(`{"a":"hello","b":[1,2,{"x":null,"y":true}]}`)
+	j.AssertEqual(CanonicalJSON(a), `{"a":"hello","b":[1,2,{"x":null,"y":true}]}`)
+	j.AssertEqual(ContentHash64(a), ContentHash64(b))
+	j.AssertEqual(ContentHashSHA256(a), ContentHashSHA256(b))
+	j.AssertEqual(len(ContentHashSHA256(a)), 64)
+
+	b.Put("c", 1)
+	j.AssertTrue(ContentHash64(a) != ContentHash64(b))
+}
@@ -0,0 +1,599 @@
+package base
+
+import (
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reflection-based conversion between Go values (structs, slices, maps, pointers, ...) and JSEntities.
+//
+// Struct fields are converted if they are exported.  A field's tags can modify this:
+//
+//	`json:"name"`            use "name" as the key, instead of the field name
+//	`json:"name,omitempty"`  omit the field when marshalling if it has its zero value
+//	`json:"-"`               ignore the field
+//	`default:"value"`        value to use when unmarshalling a map that is missing the key
+//
+// Embedded structs without a json tag have their fields merged into the parent's map.
+// Byte slices are stored as JBytes (which print using EncodeBase64), and time.Time values as RFC 3339 strings.  The
+// arbitrary-precision types big.Int and big.Float are stored as JNumbers.  DataClasses are stored using their
+// ToJson methods, with their schema versions (see ToVersionedJson); unmarshalling applies any migrations they need.
+
+type JsonMarshalError struct {
+	// Location of the problem, e.g. "Owner.Pets[2].Name"
+	Path    string
+	Problem string
+}
+
+func (e *JsonMarshalError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Problem
+}
+
+// Convert a Go value to a JSEntity
+func Marshal(value any) (JSEntity, error) {
+	if value == nil {
+		return JNullValue, nil
+	}
+	m := marshaller{visiting: make(map[marshalRef]bool)}
+	return m.marshalValue(reflect.ValueOf(value), "")
+}
+
+func MarshalM(value any) JSEntity {
+	return CheckOkWith(Marshal(value))
+}
+
+// Convert a Go value (typically a struct, or pointer to one) to a JSMap
+func MarshalJSMap(value any) (JSMap, error) {
+	ent, err := Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := ent.(JSMap)
+	if !ok {
+		return nil, &JsonMarshalError{Problem: "value doesn't marshal to a map: " + TypeOf(value)}
+	}
+	return m, nil
+}
+
+func MarshalJSMapM(value any) JSMap {
+	return CheckOkWith(MarshalJSMap(value))
+}
+
+// Store the contents of a JSEntity in a Go value; target must be a non-nil pointer
+func Unmarshal(source JSEntity, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &JsonMarshalError{Problem: "target must be a non-nil pointer: " + TypeOf(target)}
+	}
+	return unmarshalValue(source, v.Elem(), "")
+}
+
+func UnmarshalM(source JSEntity, target any) {
+	CheckOk(Unmarshal(source, target))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var jsEntityType = reflect.TypeOf((*JSEntity)(nil)).Elem()
+var bigIntType = reflect.TypeOf(big.Int{})
+var bigFloatType = reflect.TypeOf(big.Float{})
+var dataClassType = reflect.TypeOf((*DataClass)(nil)).Elem()
+
+func marshalFail(path string, problem ...any) error {
+	return &JsonMarshalError{Path: path, Problem: ToString(problem...)}
+}
+
+func marshalFieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func marshalIndexPath(path string, index int) string {
+	return path + "[" + IntToString(index) + "]"
+}
+
+// ---------------------------------------------------------------------------------------
+// Struct field information
+// ---------------------------------------------------------------------------------------
+
+type marshalField struct {
+	index        []int
+	name         string
+	key          string
+	omitEmpty    bool
+	defaultValue string
+	hasDefault   bool
+}
+
+var marshalFieldCache = NewConcurrentMap[reflect.Type, []marshalField]()
+
+func marshalFields(t reflect.Type) []marshalField {
+	fields, ok := marshalFieldCache.OptValue(t, nil)
+	if !ok {
+		fields = auxMarshalFields(t, nil)
+		marshalFieldCache.Put(t, fields)
+	}
+	return fields
+}
+
+func auxMarshalFields(t reflect.Type, parentIndex []int) []marshalField {
+	var result []marshalField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+		if tag == "-" {
+			continue
+		}
+		index := append(parentIndex[:len(parentIndex):len(parentIndex)], i)
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			result = append(result, auxMarshalFields(f.Type, index)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		mf := marshalField{index: index, name: f.Name, key: f.Name}
+		if hasTag {
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				mf.key = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					mf.omitEmpty = true
+				}
+			}
+		}
+		mf.defaultValue, mf.hasDefault = f.Tag.Lookup("default")
+		result = append(result, mf)
+	}
+	return result
+}
+
+// ---------------------------------------------------------------------------------------
+// Marshalling
+// ---------------------------------------------------------------------------------------
+
+// Identifies a pointer, map, or slice being marshalled, to detect cycles
+type marshalRef struct {
+	ptr    uintptr
+	length int
+	t      reflect.Type
+}
+
+type marshaller struct {
+	// The references that enclose the value being marshalled
+	visiting map[marshalRef]bool
+}
+
+// Note that a reference is being visited; returns false if it is already being visited (i.e., there is a cycle)
+func (m *marshaller) enter(v reflect.Value) (marshalRef, bool) {
+	ref := marshalRef{ptr: v.Pointer(), t: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.length = v.Len()
+	}
+	if m.visiting[ref] {
+		return ref, false
+	}
+	m.visiting[ref] = true
+	return ref, true
+}
+
+func (m *marshaller) marshalValue(v reflect.Value, path string) (JSEntity, error) {
+	if !v.IsValid() {
+		return JNullValue, nil
+	}
+	t := v.Type()
+
+	// Values that are already JSEntities, or that know how to convert themselves, come first
+	if t.Implements(jsEntityType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return JNullValue, nil
+		}
+		return v.Interface().(JSEntity), nil
+	}
+	if t.Implements(dataClassType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return JNullValue, nil
+		}
+		return SharedMigrationRegistry().ToVersionedJson(v.Interface().(DataClass)), nil
+	}
+	if t == timeType {
+		return JString(v.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	}
+	if t == bigIntType || t == bigFloatType {
+		// Work with a pointer, since the methods have pointer receivers
+		ptr := reflect.New(t)
+		ptr.Elem().Set(v)
+		return ToJSEntity(ptr.Interface()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return MakeJBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return JInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ToJSEntity(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return JFloat(v.Float()), nil
+	case reflect.String:
+		return JString(v.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return JNullValue, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			ref, ok := m.enter(v)
+			if !ok {
+				return nil, marshalFail(path, "cycle detected:", t)
+			}
+			defer delete(m.visiting, ref)
+		}
+		return m.marshalValue(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return JNullValue, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return JBytes(b), nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() != 0 {
+			ref, ok := m.enter(v)
+			if !ok {
+				return nil, marshalFail(path, "cycle detected:", t)
+			}
+			defer delete(m.visiting, ref)
+		}
+		list := NewJSList()
+		for i := 0; i < v.Len(); i++ {
+			elem, err := m.marshalValue(v.Index(i), marshalIndexPath(path, i))
+			if err != nil {
+				return nil, err
+			}
+			list.wrappedList = append(list.wrappedList, elem)
+		}
+		return list, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return JNullValue, nil
+		}
+		if t.Key().Kind() != reflect.String {
+			return nil, marshalFail(path, "map keys must be strings:", t)
+		}
+		ref, ok := m.enter(v)
+		if !ok {
+			return nil, marshalFail(path, "cycle detected:", t)
+		}
+		defer delete(m.visiting, ref)
+		result := NewJSMap()
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			elem, err := m.marshalValue(iter.Value(), marshalFieldPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result.wrappedMap[key] = elem
+		}
+		return result, nil
+	case reflect.Struct:
+		result := NewJSMap()
+		for _, f := range marshalFields(t) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			elem, err := m.marshalValue(fv, marshalFieldPath(path, f.name))
+			if err != nil {
+				return nil, err
+			}
+			result.wrappedMap[f.key] = elem
+		}
+		return result, nil
+	}
+	return nil, marshalFail(path, "unsupported type:", t)
+}
+
+// ---------------------------------------------------------------------------------------
+// Unmarshalling
+// ---------------------------------------------------------------------------------------
+
+func unmarshalValue(source JSEntity, v reflect.Value, path string) error {
+	t := v.Type()
+
+	if t.Implements(jsEntityType) {
+		if source == JNullValue && t.Kind() != reflect.Interface {
+			v.SetZero()
+			return nil
+		}
+		sv := reflect.ValueOf(source)
+		if !sv.Type().AssignableTo(t) {
+			return marshalFail(path, "expected", t, "but found", TypeOf(source))
+		}
+		v.Set(sv)
+		return nil
+	}
+
+	if source == JNullValue {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.SetZero()
+			return nil
+		}
+		return marshalFail(path, "null is not allowed for type", t)
+	}
+
+	if t.Implements(dataClassType) {
+		return unmarshalDataClass(source, v, path)
+	}
+	if t == timeType {
+		s, ok := source.(JString)
+		if !ok {
+			return marshalFail(path, "expected a time string, found", TypeOf(source))
+		}
+		tm, err := time.Parse(time.RFC3339Nano, string(s))
+		if err != nil {
+			return marshalFail(path, "can't parse time:", err)
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	}
+	if t == bigIntType {
+		b, err := JSEntityBigInt(source)
+		if err != nil {
+			return marshalFail(path, err)
+		}
+		v.Set(reflect.ValueOf(b).Elem())
+		return nil
+	}
+	if t == bigFloatType {
+		f, err := JSEntityBigFloat(source)
+		if err != nil {
+			return marshalFail(path, err)
+		}
+		v.Set(reflect.ValueOf(f).Elem())
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := source.(JBool)
+		if !ok {
+			return marshalFail(path, "expected a bool, found", TypeOf(source))
+		}
+		v.SetBool(bool(b))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := unmarshalInteger(source, path)
+		if err != nil {
+			return err
+		}
+		if !i.IsInt64() || v.OverflowInt(i.Int64()) {
+			return marshalFail(path, "integer", i.String(), "overflows", t)
+		}
+		v.SetInt(i.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := unmarshalInteger(source, path)
+		if err != nil {
+			return err
+		}
+		if !i.IsUint64() || v.OverflowUint(i.Uint64()) {
+			return marshalFail(path, "integer", i.String(), "out of range for", t)
+		}
+		v.SetUint(i.Uint64())
+	case reflect.Float32, reflect.Float64:
+		switch n := source.(type) {
+		case JFloat, JInteger, JNumber:
+			v.SetFloat(n.AsFloat())
+		default:
+			return marshalFail(path, "expected a number, found", TypeOf(source))
+		}
+	case reflect.String:
+		s, ok := source.(JString)
+		if !ok {
+			return marshalFail(path, "expected a string, found", TypeOf(source))
+		}
+		v.SetString(string(s))
+	case reflect.Pointer:
+		target := reflect.New(t.Elem())
+		if err := unmarshalValue(source, target.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(target)
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return marshalFail(path, "can't unmarshal into interface", t)
+		}
+		// An 'any' field receives the JSEntity itself
+		v.Set(reflect.ValueOf(source))
+	case reflect.Slice, reflect.Array:
+		return unmarshalList(source, v, path)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return marshalFail(path, "map keys must be strings:", t)
+		}
+		m, ok := source.(JSMap)
+		if !ok {
+			return marshalFail(path, "expected a map, found", TypeOf(source))
+		}
+		result := reflect.MakeMapWithSize(t, m.Size())
+		for _, k := range m.OrderedKeys() {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := unmarshalValue(m.wrappedMap[k], elem, marshalFieldPath(path, k)); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+		}
+		v.Set(result)
+	case reflect.Struct:
+		return unmarshalStruct(source, v, path)
+	default:
+		return marshalFail(path, "unsupported type:", t)
+	}
+	return nil
+}
+
+// Get the value of a JInteger, or of a JNumber that is an integer
+func unmarshalInteger(source JSEntity, path string) (*big.Int, error) {
+	switch n := source.(type) {
+	case JInteger:
+		return big.NewInt(int64(n)), nil
+	case JNumber:
+		if b, err := n.BigInt(); err == nil {
+			return b, nil
+		}
+	}
+	return nil, marshalFail(path, "expected an integer, found", TypeOf(source))
+}
+
+func unmarshalList(source JSEntity, v reflect.Value, path string) error {
+	t := v.Type()
+	if t.Elem().Kind() == reflect.Uint8 {
+		if _, ok := jsonStringValue(source); ok {
+			var b []byte
+			err := catchPanicAsError(func() { b = DecodeBase64Maybe(source) })
+			if err != nil {
+				return marshalFail(path, "can't decode bytes:", err)
+			}
+			if v.Kind() == reflect.Array {
+				if len(b) != v.Len() {
+					return marshalFail(path, "expected", IntToString(v.Len()), "bytes, found", IntToString(len(b)))
+				}
+				reflect.Copy(v, reflect.ValueOf(b))
+			} else {
+				v.SetBytes(b)
+			}
+			return nil
+		}
+	}
+	list, ok := source.(JSList)
+	if !ok {
+		return marshalFail(path, "expected a list, found", TypeOf(source))
+	}
+	n := list.Length()
+	var result reflect.Value
+	if v.Kind() == reflect.Array {
+		if n != v.Len() {
+			return marshalFail(path, "expected", IntToString(v.Len()), "elements, found", IntToString(n))
+		}
+		result = v
+	} else {
+		result = reflect.MakeSlice(t, n, n)
+	}
+	for i, elem := range list.wrappedList {
+		if err := unmarshalValue(elem, result.Index(i), marshalIndexPath(path, i)); err != nil {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(result)
+	}
+	return nil
+}
+
+func unmarshalStruct(source JSEntity, v reflect.Value, path string) error {
+	m, ok := source.(JSMap)
+	if !ok {
+		return marshalFail(path, "expected a map, found", TypeOf(source))
+	}
+	for _, f := range marshalFields(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		fp := marshalFieldPath(path, f.name)
+		value := m.wrappedMap[f.key]
+		if value == nil {
+			if f.hasDefault {
+				if err := setDefaultValue(fv, f.defaultValue, fp); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := unmarshalValue(value, fv, fp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalDataClass(source JSEntity, v reflect.Value, path string) error {
+	// Use the existing value's Parse method, if there is one; otherwise, a nil pointer of the
+	// appropriate type (generated Parse methods don't refer to their receiver)
+	var parser DataClass
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		if v.Kind() == reflect.Interface {
+			return marshalFail(path, "can't unmarshal into nil", v.Type())
+		}
+		parser = reflect.Zero(v.Type()).Interface().(DataClass)
+	} else {
+		parser = v.Interface().(DataClass)
+	}
+	// Apply any migrations from the shared registry
+	result, err := SharedMigrationRegistry().ParseVersioned(parser, source)
+	if err != nil {
+		return marshalFail(path, "can't parse", v.Type(), ";", err)
+	}
+	rv := reflect.ValueOf(result)
+	if !rv.Type().AssignableTo(v.Type()) {
+		return marshalFail(path, "parsed", rv.Type(), "is not assignable to", v.Type())
+	}
+	v.Set(rv)
+	return nil
+}
+
+// Parse a `default` tag's value according to the type of the field
+func setDefaultValue(v reflect.Value, text string, path string) error {
+	var source JSEntity
+	switch v.Kind() {
+	case reflect.String:
+		source = JString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return marshalFail(path, "bad default value:", Quoted(text))
+		}
+		source = MakeJBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return marshalFail(path, "bad default value:", Quoted(text))
+		}
+		source = JInteger(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return marshalFail(path, "bad default value:", Quoted(text))
+		}
+		source = JFloat(f)
+	default:
+		// Other types have their defaults expressed as JSON
+		var p JSONParser
+		p.WithText(text)
+		source = p.readValue()
+		if p.Error != nil {
+			return marshalFail(path, "bad default value:", Quoted(text))
+		}
+	}
+	return unmarshalValue(source, v, path)
+}
+
+// Call a function, converting any panic to an error
+func catchPanicAsError(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error(r)
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,121 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+	"time"
+)
+
+type mOwner struct {
+	First string `json:"first"`
+	Last  string `json:"last,omitempty"`
+}
+
+type mAudit struct {
+	Created time.Time `json:"created"`
+}
+
+type mAnimal struct {
+	mAudit
+	Name     string         `json:"name"`
+	Age      int            `json:"age" default:"1"`
+	Weight   float64        `json:"weight,omitempty"`
+	Tags     []string       `json:"tags"`
+	Owner    *mOwner        `json:"owner,omitempty"`
+	Photo    []byte         `json:"photo"`
+	Scores   map[string]int `json:"scores"`
+	Friends  []mOwner       `json:"friends"`
+	Extra    JSMap          `json:"extra"`
+	Kind     string         `default:"dog"`
+	Ignored  int            `json:"-"`
+	hidden   int
+	Optional map[string]string `json:"optional,omitempty"`
+}
+
+func sampleAnimal() mAnimal {
+	return mAnimal{
+		mAudit:  mAudit{Created: time.Date(2023, 7, 4, 10, 30, 0, 0, time.UTC)},
+		Name:    "fido",
+		Age:     3,
+		Tags:    []string{"a", "b"},
+		Owner:   &mOwner{First: "jeff"},
+		Photo:   []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		Scores:  map[string]int{"x": 5, "y": 7},
+		Friends: []mOwner{{First: "a", Last: "b"}},
+		Extra:   NewJSMap().Put("q", true),
+		Kind:    "cat",
+		Ignored: 42,
+		hidden:  17,
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	j := jt.New(t)
+	m := MarshalJSMapM(sampleAnimal())
+	j.AssertMessage(m)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	a := sampleAnimal()
+	m := MarshalJSMapM(&a)
+	var b mAnimal
+	UnmarshalM(m, &b)
+	a.Ignored = 0
+	a.hidden = 0
+	j.AssertEqual(MarshalJSMapM(a).CompactString(), MarshalJSMapM(b).CompactString())
+	j.AssertEqual(a.Created, b.Created)
+	j.AssertEqual(a.Photo, b.Photo)
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	j := jt.New(t)
+	var b mAnimal
+	UnmarshalM(JSMapFromStringM(`{"name":"rex"}`), &b)
+	j.AssertEqual(1, b.Age)
+	j.AssertEqual("dog", b.Kind)
+	j.AssertEqual("rex", b.Name)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	j := jt.New(t)
+	results := NewJSMap()
+	for _, s := range []string{
+		`{"age":"three"}`,
+		`{"friends":[{"first":"a"},{"first":7}]}`,
+		`{"scores":{"x":1.5}}`,
+		`{"tags":"x"}`,
+		`{"created":"yesterday"}`,
+	} {
+		var b mAnimal
+		err := Unmarshal(JSMapFromStringM(s), &b)
+		results.Put(s, err.Error())
+	}
+	j.AssertMessage(results)
+}
+
+type mNode struct {
+	Name string   `json:"name"`
+	Next *mNode   `json:"next,omitempty"`
+	Refs []*mNode `json:"refs,omitempty"`
+}
+
+func TestMarshalCycle(t *testing.T) {
+	j := jt.New(t)
+	b := &mNode{Name: "b"}
+	a := &mNode{Name: "a", Next: b}
+	// A value referenced more than once, but without a cycle, is fine
+	a.Refs = []*mNode{b, b}
+	_, err := Marshal(a)
+	j.AssertEqual(err, nil)
+
+	b.Next = a
+	_, err = Marshal(a)
+	j.AssertEqual(err.Error(), "Next.Next: cycle detected: *base_test.mNode")
+
+	m := map[string]any{}
+	m["self"] = m
+	_, err = Marshal(m)
+	j.AssertEqual(err.Error(), "self: cycle detected: map[string]interface {}")
+}
@@ -0,0 +1,269 @@
+package base
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// -------------------------------------------------------------------------------
+// Json type: number (arbitrary precision)
+//
+// A number stored as its original decimal text, so that values that can't be represented
+// exactly as a JInteger or JFloat (e.g. large IDs, or currency amounts) are not lost.  The parsers
+// produce these only in lossless mode (see JSONParser.WithLosslessNumbers), and they are printed
+// exactly as they were read; except in canonical mode, where they are normalized so that numbers
+// that are equal (see JSEntitiesEqual) are printed the same, e.g. 1.0, 1e0 and the JInteger 1 as "1".
+
+type JNumber string
+
+// The number grammar of RFC 8259
+var jsonNumberExpr = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// The largest exponent (in magnitude) a JNumber may have; exact arithmetic on numbers with larger
+// exponents (e.g. 1e1000000000) would take unreasonable amounts of memory and time
+const JNumberMaxExponent = 10000
+
+// Construct a JNumber from decimal text, e.g. "123456789012345678901234567890" or "-1.25e-40"
+func ParseJNumber(text string) (JNumber, error) {
+	if !jsonNumberExpr.MatchString(text) {
+		return "", Error("not a valid number:", Quoted(text))
+	}
+	if !jnumberExponentOk(text) {
+		return "", Error("number's exponent is too large:", Quoted(text))
+	}
+	return JNumber(text), nil
+}
+
+// Determine if a number's exponent (if it has one) is no larger in magnitude than JNumberMaxExponent
+func jnumberExponentOk(text string) bool {
+	i := strings.IndexAny(text, "eE")
+	if i < 0 {
+		return true
+	}
+	e, err := strconv.Atoi(text[i+1:])
+	return err == nil && e >= -JNumberMaxExponent && e <= JNumberMaxExponent
+}
+
+func MakeJNumber(text string) JSEntity {
+	return CheckOkWith(ParseJNumber(text))
+}
+
+func (v JNumber) PrintTo(context *JSONPrinter) {
+	if context.Canonical {
+		context.WriteString(v.canonicalText())
+		return
+	}
+	context.WriteString(string(v))
+}
+
+// Get the text of the number in canonical form: as a JInteger or JFloat with the same value would
+// print it, or (if there is none) its exact digits, in the notation RFC 8785 uses for floats
+func (v JNumber) canonicalText() string {
+	r, err := v.BigRat()
+	if err != nil {
+		// It can only equal a JNumber with the same text
+		return string(v)
+	}
+	if r.IsInt() && r.Num().IsInt64() {
+		return r.Num().String()
+	}
+	if f, exact := r.Float64(); exact {
+		return canonicalFloat(f)
+	}
+
+	// Express the value as digits x 10^exp, with no leading or trailing zeros in the digits
+	text := string(v)
+	var sign string
+	if strings.HasPrefix(text, "-") {
+		sign = "-"
+		text = text[1:]
+	}
+	exp := 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		exp, _ = strconv.Atoi(text[i+1:])
+		text = text[:i]
+	}
+	digits := text
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		digits = text[:i] + text[i+1:]
+		exp -= len(text) - i - 1
+	}
+	digits = strings.TrimLeft(digits, "0")
+	n := len(digits)
+	digits = strings.TrimRight(digits, "0")
+	exp += n - len(digits)
+
+	// Place the decimal point as ECMAScript's Number.prototype.toString() would
+	k := len(digits)
+	point := k + exp
+	switch {
+	case k <= point && point <= 21:
+		return sign + digits + strings.Repeat("0", point-k)
+	case 0 < point && point <= 21:
+		return sign + digits[:point] + "." + digits[point:]
+	case -6 < point && point <= 0:
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	}
+	mantissa := digits[:1]
+	if k > 1 {
+		mantissa += "." + digits[1:]
+	}
+	e := point - 1
+	return sign + mantissa + "e" + Ternary(e < 0, "", "+") + strconv.Itoa(e)
+}
+
+// Determine if the number's text has no fractional part or exponent
+func (v JNumber) IsIntegral() bool {
+	return !strings.ContainsAny(string(v), ".eE")
+}
+
+// Get the value as an integer, truncating any fractional part; values outside
+// the range of an int64 are clamped
+func (v JNumber) AsInteger() int64 {
+	if b, err := v.BigInt(); err == nil {
+		if b.IsInt64() {
+			return b.Int64()
+		}
+		return Ternary(b.Sign() < 0, int64(math.MinInt64), int64(math.MaxInt64))
+	}
+	result, _ := v.BigFloat().Int64()
+	return result
+}
+
+// Get the value as the nearest float64 (which may be infinite)
+func (v JNumber) AsFloat() float64 {
+	f, _ := strconv.ParseFloat(string(v), 64)
+	return f
+}
+
+func (v JNumber) AsString() string {
+	panic("Not supported")
+}
+
+func (v JNumber) AsBool() bool {
+	panic("Not supported")
+}
+
+func (v JNumber) AsJSMap() JSMap {
+	panic("Not supported")
+}
+
+func (v JNumber) AsJSList() JSList {
+	panic("Not supported")
+}
+
+// Get the value as a big.Int; it is an error if the number isn't integral
+func (v JNumber) BigInt() (*big.Int, error) {
+	b, ok := new(big.Int).SetString(string(v), 10)
+	if !ok {
+		return nil, Error("not an integer:", Quoted(string(v)))
+	}
+	return b, nil
+}
+
+// Get the value as a big.Float, with enough precision to represent all of its digits
+func (v JNumber) BigFloat() *big.Float {
+	prec := MaxInt(64, len(v)*4)
+	f, _, err := big.ParseFloat(string(v), 10, uint(prec), big.ToNearestEven)
+	CheckOk(err)
+	return f
+}
+
+// Get the exact value as a big.Rat; it is an error if the number's exponent is too large (see JNumberMaxExponent)
+func (v JNumber) BigRat() (*big.Rat, error) {
+	if !jnumberExponentOk(string(v)) {
+		return nil, Error("number's exponent is too large:", Quoted(string(v)))
+	}
+	r, ok := new(big.Rat).SetString(string(v))
+	if !ok {
+		return nil, Error("can't convert to big.Rat:", Quoted(string(v)))
+	}
+	return r, nil
+}
+
+// ---------------------------------------------------------------------------------------
+// Conversions for any numeric JSEntity (JInteger, JFloat, or JNumber)
+// ---------------------------------------------------------------------------------------
+
+// Get the exact value of a numeric JSEntity as a big.Rat, or nil if it isn't numeric
+// (or is a float that is infinite or NaN, or a JNumber whose exponent is too large)
+func jsonNumberRat(e JSEntity) *big.Rat {
+	switch x := e.(type) {
+	case JInteger:
+		return new(big.Rat).SetInt64(int64(x))
+	case JFloat:
+		return new(big.Rat).SetFloat64(float64(x))
+	case JNumber:
+		r, _ := x.BigRat()
+		return r
+	}
+	return nil
+}
+
+// Get the value of a numeric JSEntity as a big.Int; it is an error if it isn't an integer
+func JSEntityBigInt(e JSEntity) (*big.Int, error) {
+	if e == nil {
+		return nil, Error("missing value")
+	}
+	r := jsonNumberRat(e)
+	if r == nil || !r.IsInt() {
+		return nil, Error("not an integer:", Truncated(PrintJSEntity(e, false)))
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// Get the value of a numeric JSEntity as a big.Float
+func JSEntityBigFloat(e JSEntity) (*big.Float, error) {
+	if e == nil {
+		return nil, Error("missing value")
+	}
+	switch x := e.(type) {
+	case JInteger:
+		return new(big.Float).SetInt64(int64(x)), nil
+	case JFloat:
+		return new(big.Float).SetFloat64(float64(x)), nil
+	case JNumber:
+		return x.BigFloat(), nil
+	}
+	return nil, Error("not a number:", Truncated(PrintJSEntity(e, false)))
+}
+
+// ---------------------------------------------------------------------------------------
+// JSMap accessors
+// ---------------------------------------------------------------------------------------
+
+func (m JSMap) GetBigInt(key string) *big.Int {
+	return CheckOkWith(JSEntityBigInt(m.wrappedMap[key]))
+}
+
+func (m JSMap) OptBigInt(key string, defaultValue *big.Int) *big.Int {
+	var val = m.wrappedMap[key]
+	if val == nil {
+		return defaultValue
+	}
+	return CheckOkWith(JSEntityBigInt(val))
+}
+
+func (m JSMap) GetBigFloat(key string) *big.Float {
+	return CheckOkWith(JSEntityBigFloat(m.wrappedMap[key]))
+}
+
+func (m JSMap) OptBigFloat(key string, defaultValue *big.Float) *big.Float {
+	var val = m.wrappedMap[key]
+	if val == nil {
+		return defaultValue
+	}
+	return CheckOkWith(JSEntityBigFloat(val))
+}
+
+// Get the text of a number exactly as it would be printed
+func (m JSMap) GetNumberText(key string) string {
+	var val = m.wrappedMap[key]
+	if jsonNumberRat(val) == nil {
+		BadArg("not a number:", key)
+	}
+	return PrintJSEntity(val, false)
+}
@@ -0,0 +1,148 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var bigNumbersText = `{"id":123456789012345678901234567890,"small":42,"amount":19.99,"tiny":-1.25e-400,"neg":-9223372036854775809}`
+
+func parseLossless(text string) (JSMap, error) {
+	var p JSONParser
+	p.WithLosslessNumbers(true).WithText(text)
+	return p.ParseMap()
+}
+
+func TestLosslessDefaultUnchanged(t *testing.T) {
+	j := jt.New(t)
+	_, err := JSMapFromString(bigNumbersText)
+	j.AssertTrue(err != nil)
+	m := JSMapFromStringM(`{"a":19.99,"b":42}`)
+	j.AssertEqual(m.OptAny("a"), JFloat(19.99))
+	j.AssertEqual(m.OptAny("b"), JInteger(42))
+}
+
+func TestLosslessRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	m, err := parseLossless(bigNumbersText)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m.OptAny("small"), JInteger(42))
+	j.AssertEqual(m.OptAny("amount"), JNumber("19.99"))
+	for _, key := range []string{"id", "amount", "tiny", "neg"} {
+		j.AssertTrue(strings.Contains(bigNumbersText, `"`+key+`":`+m.GetNumberText(key)), key)
+	}
+	// Printing reproduces the original text, with keys sorted
+	m2, err := parseLossless(m.CompactString())
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+	j.AssertMessage(m)
+}
+
+func TestLosslessAccessors(t *testing.T) {
+	j := jt.New(t)
+	m, _ := parseLossless(bigNumbersText)
+	expected, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	j.AssertEqual(m.GetBigInt("id").Cmp(expected), 0)
+	j.AssertEqual(m.GetBigInt("small").Int64(), int64(42))
+	j.AssertEqual(m.OptBigInt("missing", big.NewInt(7)).Int64(), int64(7))
+	j.AssertEqual(m.GetBigFloat("amount").Text('f', 2), "19.99")
+	j.AssertEqual(m.GetBigFloat("tiny").Sign(), -1)
+	j.AssertEqual(m.GetInt64("neg"), int64(-9223372036854775808))
+	j.AssertEqual(m.GetInt64("amount"), int64(19))
+
+	_, err := JSEntityBigInt(m.OptAny("amount"))
+	j.AssertTrue(err != nil)
+}
+
+func TestLosslessEquality(t *testing.T) {
+	j := jt.New(t)
+	j.AssertTrue(JSEntitiesEqual(JNumber("19.990"), JNumber("1999e-2")))
+	j.AssertTrue(JSEntitiesEqual(JNumber("42"), JInteger(42)))
+	j.AssertTrue(JSEntitiesEqual(JNumber("0.5"), JFloat(0.5)))
+	j.AssertFalse(JSEntitiesEqual(JNumber("0.1"), JFloat(0.1)))
+	j.AssertFalse(JSEntitiesEqual(JNumber("1"), JString("1")))
+}
+
+func TestLosslessStream(t *testing.T) {
+	j := jt.New(t)
+	m, _ := parseLossless(bigNumbersText)
+	v, err := NewJSONStreamParser(strings.NewReader(bigNumbersText)).WithLosslessNumbers(true).ReadValue()
+	j.AssertEqual(err, nil)
+	j.AssertEqual(PrintJSEntity(v, false), m.CompactString())
+}
+
+type bigHolder struct {
+	Id      *big.Int
+	Amount  big.Float
+	Counter uint64
+	Small   int8
+}
+
+func TestLosslessMarshal(t *testing.T) {
+	j := jt.New(t)
+	m, _ := parseLossless(`{"Id":123456789012345678901234567890,"Amount":19.99,"Counter":18446744073709551615,"Small":12}`)
+	var h bigHolder
+	j.AssertEqual(Unmarshal(m, &h), nil)
+	j.AssertEqual(h.Id.String(), "123456789012345678901234567890")
+	j.AssertEqual(h.Counter, uint64(18446744073709551615))
+	j.AssertTrue(JSEntitiesEqual(MarshalM(h), m))
+
+	m.Put("Small", JNumber("300"))
+	j.AssertTrue(Unmarshal(m, &h) != nil)
+}
+
+func TestParseJNumberGrammar(t *testing.T) {
+	j := jt.New(t)
+	for _, good := range []string{"0", "-0", "12", "1.5", "-0.25e-3", "1E+9", "1e10000"} {
+		_, err := ParseJNumber(good)
+		j.AssertTrue(err == nil, good)
+	}
+	for _, bad := range []string{"+1", ".5", "1.", "01", "-", "1e", "0x10", "1e10001", "1e99999999999999999999"} {
+		_, err := ParseJNumber(bad)
+		j.AssertTrue(err != nil, bad)
+	}
+	_, err := JNumber("1e1000000000").BigRat()
+	j.AssertTrue(err != nil)
+	_, err = parseLossless(`{"a":1e1000000000}`)
+	j.AssertTrue(err != nil)
+}
+
+func TestJNumberCanonical(t *testing.T) {
+	j := jt.New(t)
+	// Numbers that are equal have the same canonical form, and hence the same hash
+	cases := []any{
+		JNumber("1.0"), 1,
+		JNumber("1e2"), 100,
+		JNumber("-0.0"), 0,
+		JNumber("0.5"), 0.5,
+		JNumber("1e21"), 1e21,
+		JNumber("25e-2"), 0.25,
+		JNumber("12.50"), JNumber("1.25e1"),
+		JNumber("123456789012345678901234567890.0"), JNumber("123456789012345678901234567890"),
+		JNumber("0.10"), JNumber("1e-1"),
+	}
+	for i := 0; i < len(cases); i += 2 {
+		a := NewJSMap().Put("x", cases[i])
+		b := NewJSMap().Put("x", cases[i+1])
+		j.AssertTrue(JSEntitiesEqual(a, b), a, b)
+		j.AssertEqual(CanonicalJSON(a), CanonicalJSON(b))
+		j.AssertEqual(ContentHash64(a), ContentHash64(b))
+	}
+
+	// Numbers without an equal JInteger or JFloat are written in the same notation as floats
+	cases = []any{
+		"0.1", "0.1",
+		"-19.990", "-19.99",
+		"123456789012345678901234567890", "1.2345678901234567890123456789e+29",
+		"-1.25e-400", "-1.25e-400",
+		"0.0000001000000000000000001", "1.000000000000000001e-7",
+	}
+	for i := 0; i < len(cases); i += 2 {
+		j.AssertEqual(CanonicalJSON(MakeJNumber(cases[i].(string))), cases[i+1])
+	}
+	// The normal form is unchanged
+	j.AssertEqual(PrintJSEntity(JNumber("1.0"), false), "1.0")
+}
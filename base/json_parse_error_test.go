@@ -0,0 +1,93 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"strings"
+	"testing"
+)
+
+var badConfig = `{
+  "name": "fido",
+  "owner": {
+    "first": "jeff",
+    "pets": [1, 2, tru, 4]
+  },
+  "age": 3
+}`
+
+func TestParseErrorLocation(t *testing.T) {
+	j := jt.New(t)
+	_, err := JSMapFromString(badConfig)
+	e := err.(*JsonParseError)
+	j.AssertEqual(e.Line, 5)
+	j.AssertEqual(e.Column, 20)
+	j.AssertEqual(e.Path, "/owner/pets")
+	j.AssertMessage(err)
+}
+
+func TestParseErrorUnicodeColumn(t *testing.T) {
+	j := jt.New(t)
+	_, err := JSMapFromString("{\"a\":\"’’\",\n\t\"b\" 5}")
+	e := err.(*JsonParseError)
+	j.AssertEqual(e.Line, 2)
+	j.AssertEqual(e.Column, 6)
+	j.AssertMessage(err)
+}
+
+func TestParseErrorStreamAgrees(t *testing.T) {
+	j := jt.New(t)
+	_, err := JSMapFromString(badConfig)
+	_, err2 := NewJSONStreamParser(strings.NewReader(badConfig)).ReadValue()
+	j.AssertEqual(err.Error(), err2.Error())
+}
+
+func TestParseKeepGoing(t *testing.T) {
+	j := jt.New(t)
+	text := `{
+  "a": 1,
+  "b": [1, 2x, 3,, 5],
+  "c": {"x": nul, "y": 2},
+  "d": "ok"
+  "e": 7,
+}`
+	var p JSONParser
+	p.WithKeepGoing(true).WithText(text)
+	_, err := p.ParseMap()
+	j.AssertEqual(len(p.Problems()), 4)
+	_, ok := err.(JsonParseErrors)
+	j.AssertTrue(ok)
+	j.AssertMessage(err)
+}
+
+func TestParseKeepGoingUnrecoverable(t *testing.T) {
+	j := jt.New(t)
+	var p JSONParser
+	p.WithKeepGoing(true).WithText(`{"a": 1x, "b": "unterminated}`)
+	_, err := p.ParseMap()
+	j.AssertEqual(len(p.Problems()), 2)
+	j.AssertTrue(err != nil)
+}
+
+func TestParseKeepGoingSucceeds(t *testing.T) {
+	j := jt.New(t)
+	var p JSONParser
+	p.WithKeepGoing(true).WithText(badConfig[:strings.Index(badConfig, "tru")] + "true" + badConfig[strings.Index(badConfig, "tru")+3:])
+	m, err := p.ParseMap()
+	j.AssertEqual(err, nil)
+	j.AssertEqual(len(p.Problems()), 0)
+	j.AssertEqual(m.GetPathM("/owner/pets/2"), JBoolTrue)
+}
+
+func TestParseKeepGoingSkipsComments(t *testing.T) {
+	j := jt.New(t)
+	var p JSONParser
+	p.WithRelaxed(true).WithKeepGoing(true).WithText(`{
+  a: 1x /* first, second */,
+  b: 2,
+}`)
+	_, err := p.ParseMap()
+	// The comment's contents shouldn't produce further problems
+	j.AssertEqual(len(p.Problems()), 1)
+	j.AssertMessage(err)
+}
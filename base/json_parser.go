@@ -1,9 +1,11 @@
 package base
 
 import (
-	"fmt"
+	"bytes"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type JSONParser struct {
@@ -11,16 +13,60 @@ type JSONParser struct {
 	cursor    int
 	Error     error
 	nestLevel int
+	relaxed   bool
+	lossless  bool
+	keepGoing bool
+	problems  []*JsonParseError
+	// Path to the innermost container being parsed, and the key (or index) of its current element
+	path    []string
+	segment string
+	depth   int
 }
 
 type JsonParseError struct {
-	prob    string
+	Problem string
+	// The bytes to either side of the cursor, separated by '!'
 	Context string
 	Cursor  int
+	// Line and column (both starting at 1) of the cursor; the column counts characters, not bytes
+	Line   int
+	Column int
+	// JSON pointer to the innermost map or list containing the problem
+	Path string
+	// The text surrounding the cursor on its line, and a second line with a caret marking the cursor
+	Excerpt string
 }
 
+// The first line of the message has the same form as it always has (cursor, context, and problem);
+// the line, column, path and excerpt follow on subsequent lines
 func (e *JsonParseError) Error() string {
-	return e.prob
+	sb := strings.Builder{}
+	sb.WriteString(ToString("Problem parsing json, cursor: "+IntToString(e.Cursor)+",", "context:", e.Context))
+	if e.Problem != "" {
+		sb.WriteString(" " + e.Problem)
+	}
+	sb.WriteString("\n    at line " + IntToString(e.Line) + ", column " + IntToString(e.Column))
+	if e.Path != "" {
+		sb.WriteString(", within " + e.Path)
+	}
+	for _, line := range strings.Split(e.Excerpt, "\n") {
+		sb.WriteString("\n    ")
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// The problems found by a JSONParser in keep-going mode, if there was more than one
+type JsonParseErrors []*JsonParseError
+
+func (e JsonParseErrors) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(IntToString(len(e)) + " problems parsing json:")
+	for _, x := range e {
+		sb.WriteString("\n")
+		sb.WriteString(x.Error())
+	}
+	return sb.String()
 }
 
 type JSKeyword struct {
@@ -41,6 +87,40 @@ var JSNull = buildKeyword("null", JNullValue)
 var JSTrue = buildKeyword("true", MakeJBool(true))
 var JSFalse = buildKeyword("false", MakeJBool(false))
 
+// Enable (or disable) the relaxed parsing mode, intended for hand-edited files.  In addition to
+// the standard grammar, it accepts /* ... */ comments, unquoted identifier keys, and single-quoted strings.
+// (Both modes accept // comments and trailing commas.)  This must be called before WithText().
+func (p *JSONParser) WithRelaxed(relaxed bool) *JSONParser {
+	p.relaxed = relaxed
+	return p
+}
+
+// Enable (or disable) lossless number parsing.  In this mode, numbers that aren't integers that fit in
+// an int64 are stored as JNumbers (rather than JFloats), which preserve their original text.
+// Numbers that are too large for an int64, which are normally an error, are accepted.
+func (p *JSONParser) WithLosslessNumbers(lossless bool) *JSONParser {
+	p.lossless = lossless
+	return p
+}
+
+// Enable (or disable) keep-going mode.  In this mode, if a problem is found within a map or list element,
+// it is recorded and the parser skips to the next element, so that several problems can be reported at once.
+// The resulting error is a JsonParseErrors if more than one problem was found.
+func (p *JSONParser) WithKeepGoing(keepGoing bool) *JSONParser {
+	p.keepGoing = keepGoing
+	return p
+}
+
+// Get the problems found so far, including any that were recovered from in keep-going mode
+func (p *JSONParser) Problems() []*JsonParseError {
+	var result []*JsonParseError
+	result = append(result, p.problems...)
+	if e, ok := p.Error.(*JsonParseError); ok && (len(result) == 0 || result[len(result)-1] != e) {
+		result = append(result, e)
+	}
+	return result
+}
+
 func (p *JSONParser) WithText(text string) *JSONParser {
 	p.textBytes = []byte(text)
 	p.cursor = 0
@@ -59,32 +139,68 @@ func (p *JSONParser) fail(message ...any) {
 		return
 	}
 
-	var txt = "problem parsing json"
-	if len(message) != 0 {
-		txt += "; " + ToString(message...)
+	text := p.textBytes
+	// The cursor may have advanced past the end of the text
+	cursor := MinInt(p.cursor, len(text))
+	lineStart := bytes.LastIndexByte(text[:cursor], '\n') + 1
+	lineEnd := len(text)
+	if i := bytes.IndexByte(text[cursor:], '\n'); i >= 0 {
+		lineEnd = cursor + i
+	}
+	loc := jsonErrorLocation{
+		cursor: p.cursor,
+		line:   bytes.Count(text[:lineStart], []byte{'\n'}) + 1,
+		column: utf8.RuneCount(text[lineStart:cursor]) + 1,
+		path:   JsonPointer(p.path...),
 	}
+	before := text[MaxInt(lineStart, cursor-jsonErrorExcerptLength):cursor]
+	after := text[cursor:MinInt(lineEnd, cursor+jsonErrorExcerptLength)]
+	p.Error = newJsonParseError(loc, before, after, message...)
+}
+
+// Number of bytes to either side of the cursor to include in a JsonParseError's context
+const jsonErrorContextLength = 15
+
+// Maximum number of bytes to either side of the cursor to include in a JsonParseError's excerpt
+const jsonErrorExcerptLength = 60
 
+type jsonErrorLocation struct {
+	cursor int
+	line   int
+	column int
+	path   string
+}
+
+// Construct a JsonParseError, given the bytes immediately before and after the cursor on its line
+func newJsonParseError(loc jsonErrorLocation, before []byte, after []byte, message ...any) *JsonParseError {
 	sb := strings.Builder{}
 	sb.WriteString("...")
-	{
-		i := MaxInt(p.cursor-15, 0)
-		if i < p.cursor {
-			sb.WriteString(string(p.textBytes[i:p.cursor]))
-		}
-	}
+	sb.Write(before[MaxInt(len(before)-jsonErrorContextLength, 0):])
 	sb.WriteString("!")
+	sb.Write(after[:MinInt(len(after), jsonErrorContextLength)])
 
-	{
-		i := MinInt(p.cursor+15, len(p.textBytes))
-		if i > p.cursor {
-		}
-		sb.WriteString(string(p.textBytes[p.cursor:i]))
+	// Replace tabs and other control characters so the caret lines up
+	excerptText := func(b []byte) string {
+		return strings.Map(func(r rune) rune {
+			if r < ' ' {
+				return ' '
+			}
+			return r
+		}, string(b))
+	}
+	prefix := excerptText(before)
+	excerpt := prefix + strings.TrimRight(excerptText(after), " ") + "\n" +
+		strings.Repeat(" ", utf8.RuneCountInString(prefix)) + "^"
+
+	return &JsonParseError{
+		Problem: strings.TrimSpace(ToString(message...)),
+		Context: sb.String(),
+		Cursor:  loc.cursor,
+		Line:    loc.line,
+		Column:  loc.column,
+		Path:    loc.path,
+		Excerpt: excerpt,
 	}
-	var context = sb.String()
-	var msg = ToString(JoinLists([]any{
-		fmt.Sprintf("Problem parsing json, cursor: %v,", p.cursor), "context:",
-		context}, message)...)
-	p.Error = &JsonParseError{prob: msg, Context: context, Cursor: p.cursor}
 }
 
 func (p *JSONParser) skipWhitespace() bool {
@@ -96,6 +212,15 @@ func (p *JSONParser) skipWhitespace() bool {
 		var c = mSourceChars[j]
 		if c == '/' {
 			j++
+			if p.relaxed && j < length && mSourceChars[j] == '*' {
+				end := bytes.Index(mSourceChars[j+1:], []byte("*/"))
+				if end < 0 {
+					p.fail("unterminated comment")
+					return false
+				}
+				j += 1 + end + 2
+				continue
+			}
 			if j == length || mSourceChars[j] != '/' {
 				p.fail("problem skipping whitespace, expected '/'")
 				return false
@@ -126,9 +251,11 @@ func (p *JSONParser) ReadExpectedByte(expected byte) {
 
 // Read an expected character
 func (p *JSONParser) ReadExpectedByteWithoutSkipWsAfter(expected byte) {
-	if p.read() != expected {
+	if p.peek() != expected {
 		p.fail("expected '" + string(expected) + "'")
+		return
 	}
+	p.cursor++
 }
 
 // If next character matches a value, read it and any following whitespace, and return true.
@@ -143,45 +270,32 @@ func (p *JSONParser) readIf(expected byte) bool {
 
 // Read a quoted, escaped string and any following whitespace.
 func (p *JSONParser) readString() string {
+	var result string
+	if p.relaxed && p.peek() == '\'' {
+		result = readJsonQuotedString(p, '\'')
+	} else {
+		result = readJsonString(p)
+	}
+	p.skipWhitespace()
+	return result
+}
 
-	// Todo("probably doesn't deal with utf-8 properly?")
-	var w strings.Builder
-
-	p.ReadExpectedByteWithoutSkipWsAfter('"')
-
-	for !p.hasProblem() {
-		var c = p.read()
-		if c == '"' {
-			break
-		}
-		if c != '\\' {
-			w.WriteByte(c)
-			continue
-		}
-		c = p.read()
-		switch c {
-		case '\\', '"', '/':
-			w.WriteByte(c)
-		case 'b':
-			w.WriteByte('\b')
-		case 'f':
-			w.WriteByte('\f')
-		case 'n':
-			w.WriteByte('\n')
-		case 'r':
-			w.WriteByte('\r')
-		case 't':
-			w.WriteByte('\t')
-		case 'u':
-			// p.readHexInt() will help, once I figure out how to convert the result to utf8
-			p.fail("Unicode not yet supported")
-			//     w.append((char) ((readHex() << 12) | (readHex() << 8) | (readHex() << 4) | readHex()));
-		default:
-			p.fail("unsupported escape sequence:", c)
+// Read a map key and any following whitespace.  In relaxed mode, this can be an unquoted identifier.
+func (p *JSONParser) readKey() string {
+	if p.relaxed && isIdentifierByte(p.peek(), true) {
+		start := p.cursor
+		for p.cursor < len(p.textBytes) && isIdentifierByte(p.textBytes[p.cursor], false) {
+			p.cursor++
 		}
+		key := string(p.textBytes[start:p.cursor])
+		p.skipWhitespace()
+		return key
 	}
-	p.skipWhitespace()
-	return w.String()
+	return p.readString()
+}
+
+func isIdentifierByte(c byte, first bool) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
 }
 
 func (p *JSONParser) assertCompleted() {
@@ -204,27 +318,6 @@ func (p *JSONParser) read() byte {
 	return result
 }
 
-func (p *JSONParser) readHexint() int {
-	result := 0
-	var c = int(p.read())
-	if c >= 'a' {
-		c -= ('a' - 'A')
-	}
-	if c >= 'A' {
-		result = c - ('A') + 10
-		if result >= 16 {
-			p.fail()
-		}
-		return result
-	} else {
-		result = c - '0'
-		if result < 0 || result >= 10 {
-			p.fail()
-		}
-	}
-	return result
-}
-
 func (p *JSONParser) readNumber() JSEntity {
 
 	var start = p.cursor
@@ -233,7 +326,7 @@ func (p *JSONParser) readNumber() JSEntity {
 	var text, length = p.textBytes, len(p.textBytes)
 	for p.cursor < length {
 		var c = p.peek()
-		if c <= ' ' || c == ',' || c == ']' || c == '}' {
+		if jsonNumberTerminator(c) {
 			break
 		}
 		if c == 'e' || c == 'E' || c == '.' {
@@ -245,19 +338,7 @@ func (p *JSONParser) readNumber() JSEntity {
 
 	p.skipWhitespace()
 
-	var value JSEntity
-
-	if isFloat {
-		v, err := strconv.ParseFloat(expr, 64)
-		if err == nil {
-			value = MakeJFloat(v)
-		}
-	} else {
-		v, err := strconv.Atoi(expr)
-		if err == nil {
-			value = MakeJInteger(int64(v))
-		}
-	}
+	var value = parseJsonNumber(expr, isFloat, p.lossless)
 	if value == nil {
 		p.fail("problem parsing number", expr)
 		value = JInteger(0)
@@ -268,11 +349,12 @@ func (p *JSONParser) readNumber() JSEntity {
 func (p *JSONParser) ReadExpectedBytes(s []byte) {
 	if len(s)+p.cursor > len(p.textBytes) {
 		p.fail("end of data reading expected bytes")
+		return
 	}
 
 	for i, c := range s {
 		if c != p.textBytes[p.cursor+i] {
-			p.fail()
+			p.fail("expected '" + string(s) + "'")
 		}
 	}
 	p.cursor += len(s)
@@ -291,12 +373,17 @@ func (p *JSONParser) readValue() JSEntity {
 			result, _ = p.ParseMap()
 		case '"':
 			result = MakeJString(p.readString())
+		case '\'':
+			if p.relaxed {
+				result = MakeJString(p.readString())
+			} else {
+				result = p.readNumber()
+			}
 		case 't':
 			result = MakeJBool(p.readTrue())
 		case 'f':
 			result = MakeJBool(p.readFalse())
 		case 'n':
-			p.read()
 			p.ReadExpectedBytes(JSNull.bytes)
 			result = JNullValue
 		default:
@@ -327,6 +414,95 @@ func (p *JSONParser) readKeywordValue(kword *JSKeyword) any {
 	return kword.value
 }
 
+// Update the path when starting to parse a map or list
+func (p *JSONParser) enterContainer() {
+	if p.depth > 0 {
+		p.path = append(p.path, p.segment)
+	}
+	p.depth++
+}
+
+// Update the path when finished parsing a map or list.  If it was the outermost one,
+// and problems were recovered from in keep-going mode, report them.
+func (p *JSONParser) exitContainer() {
+	p.depth--
+	if p.depth > 0 {
+		p.path = p.path[:len(p.path)-1]
+		return
+	}
+	if len(p.problems) != 0 {
+		p.problems = p.Problems()
+		if len(p.problems) == 1 {
+			p.Error = p.problems[0]
+		} else {
+			p.Error = JsonParseErrors(p.problems)
+		}
+	}
+}
+
+// In keep-going mode, record the current problem and skip past the map or list element (starting at
+// a particular position) in which it occurred.  Returns false if parsing can't continue; otherwise,
+// closed is true if the container's closing bracket was skipped as well.
+func (p *JSONParser) recoverFrom(start int) (ok bool, closed bool) {
+	e, isParseError := p.Error.(*JsonParseError)
+	if !p.keepGoing || !isParseError {
+		return
+	}
+	text := p.textBytes
+	depth := 0
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '"', '\'':
+			if c == '\'' && !p.relaxed {
+				continue
+			}
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			continue
+		case '/':
+			if i+1 < len(text) && text[i+1] == '/' {
+				for i < len(text) && text[i] != '\n' {
+					i++
+				}
+			} else if p.relaxed && i+1 < len(text) && text[i+1] == '*' {
+				end := bytes.Index(text[i+2:], []byte("*/"))
+				if end < 0 {
+					// An unterminated comment; there's no way to continue
+					return
+				}
+				i += 2 + end + 1
+			}
+			continue
+		case '{', '[':
+			depth++
+			continue
+		case '}', ']':
+			if depth > 0 {
+				depth--
+				continue
+			}
+		case ',':
+			if depth > 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		// We've reached the end of the element
+		p.problems = append(p.problems, e)
+		p.Error = nil
+		p.cursor = i + 1
+		p.skipWhitespace()
+		return true, c != ','
+	}
+	// The element doesn't end before the text does, so there's no way to continue
+	return
+}
+
 func (p *JSONParser) adjustNest(amount int) {
 	if p.nestLevel == 100 {
 		p.fail("too many levels of nesting")
@@ -334,3 +510,127 @@ func (p *JSONParser) adjustNest(amount int) {
 		p.nestLevel += amount
 	}
 }
+
+// ---------------------------------------------------------------------------------------
+// Grammar elements shared by JSONParser and JSONStreamParser
+// ---------------------------------------------------------------------------------------
+
+// A source of bytes for the shared grammar functions
+type jsonByteSource interface {
+	read() byte
+	hasProblem() bool
+	fail(message ...any)
+}
+
+// Read a quoted, escaped string (but not any following whitespace)
+func readJsonString(src jsonByteSource) string {
+	return readJsonQuotedString(src, '"')
+}
+
+// Read an escaped string delimited by a particular quote character
+func readJsonQuotedString(src jsonByteSource, quote byte) string {
+	var w strings.Builder
+
+	if src.read() != quote {
+		src.fail("expected '" + string(quote) + "'")
+	}
+
+	for !src.hasProblem() {
+		var c = src.read()
+		if c == quote {
+			break
+		}
+		if c != '\\' {
+			w.WriteByte(c)
+			continue
+		}
+		c = src.read()
+		switch c {
+		case '\\', '"', '/', quote:
+			w.WriteByte(c)
+		case 'b':
+			w.WriteByte('\b')
+		case 'f':
+			w.WriteByte('\f')
+		case 'n':
+			w.WriteByte('\n')
+		case 'r':
+			w.WriteByte('\r')
+		case 't':
+			w.WriteByte('\t')
+		case 'u':
+			w.WriteRune(readJsonUnicodeEscape(src))
+		default:
+			src.fail("unsupported escape sequence:", c)
+		}
+	}
+	return w.String()
+}
+
+// Read the four hex digits following a '\u', plus a second escape sequence
+// if they denote the first half of a UTF-16 surrogate pair
+func readJsonUnicodeEscape(src jsonByteSource) rune {
+	r := readJsonHex4(src)
+	if utf16.IsSurrogate(r) {
+		if src.read() != '\\' || src.read() != 'u' {
+			src.fail("expected second half of surrogate pair")
+			return utf8.RuneError
+		}
+		r = utf16.DecodeRune(r, readJsonHex4(src))
+	}
+	return r
+}
+
+func readJsonHex4(src jsonByteSource) rune {
+	var result rune
+	for i := 0; i < 4; i++ {
+		result = (result << 4) | rune(readJsonHexDigit(src))
+	}
+	return result
+}
+
+func readJsonHexDigit(src jsonByteSource) int {
+	c := int(src.read())
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	src.fail("expected hex digit")
+	return 0
+}
+
+// Determine if a byte marks the end of a number's text
+func jsonNumberTerminator(c byte) bool {
+	return c <= ' ' || c == ',' || c == ']' || c == '}'
+}
+
+// Convert a number's text to a JInteger or JFloat (or, if lossless is true, a JInteger or JNumber);
+// returns nil if it is malformed
+func parseJsonNumber(expr string, isFloat bool, lossless bool) JSEntity {
+	var value JSEntity
+	if lossless {
+		if !isFloat {
+			if v, err := strconv.ParseInt(expr, 10, 64); err == nil {
+				return MakeJInteger(v)
+			}
+		}
+		if n, err := ParseJNumber(expr); err == nil {
+			value = n
+		}
+	} else if isFloat {
+		v, err := strconv.ParseFloat(expr, 64)
+		if err == nil {
+			value = MakeJFloat(v)
+		}
+	} else {
+		v, err := strconv.Atoi(expr)
+		if err == nil {
+			value = MakeJInteger(int64(v))
+		}
+	}
+	return value
+}
@@ -0,0 +1,352 @@
+package base
+
+// Support for RFC 6902 JSON Patch documents (lists of add/remove/replace/move/copy/test
+// operations), and RFC 7386 JSON Merge Patch documents.
+
+// Determine if two JSEntities are structurally equal.  Numbers (JIntegers, JFloats, and JNumbers)
+// are compared by numeric value, and JBytes are equal to the strings they would be printed as.
+func JSEntitiesEqual(a JSEntity, b JSEntity) bool {
+	_, aNum := a.(JNumber)
+	_, bNum := b.(JNumber)
+	if aNum || bNum {
+		if aNum && bNum && a == b {
+			return true
+		}
+		x, y := jsonNumberRat(a), jsonNumberRat(b)
+		return x != nil && y != nil && x.Cmp(y) == 0
+	}
+	_, aBytes := a.(JBytes)
+	_, bBytes := b.(JBytes)
+	if aBytes || bBytes {
+		x, xOk := jsonStringValue(a)
+		y, yOk := jsonStringValue(b)
+		return xOk && yOk && x == y
+	}
+	switch x := a.(type) {
+	case JSMap:
+		y, ok := b.(JSMap)
+		if !ok || x.Size() != y.Size() {
+			return false
+		}
+		for k, v := range x.wrappedMap {
+			v2 := y.wrappedMap[k]
+			if v2 == nil || !JSEntitiesEqual(v, v2) {
+				return false
+			}
+		}
+		return true
+	case JSList:
+		y, ok := b.(JSList)
+		if !ok || x.Length() != y.Length() {
+			return false
+		}
+		for i, v := range x.wrappedList {
+			if !JSEntitiesEqual(v, y.wrappedList[i]) {
+				return false
+			}
+		}
+		return true
+	case JInteger:
+		switch y := b.(type) {
+		case JInteger:
+			return x == y
+		case JFloat:
+			return float64(x) == float64(y)
+		}
+		return false
+	case JFloat:
+		switch y := b.(type) {
+		case JInteger:
+			return float64(x) == float64(y)
+		case JFloat:
+			return x == y
+		}
+		return false
+	}
+	return a == b
+}
+
+// Get the value of a JString or JBytes as a string
+func jsonStringValue(e JSEntity) (string, bool) {
+	switch e.(type) {
+	case JString, JBytes:
+		return e.AsString(), true
+	}
+	return "", false
+}
+
+// Construct a deep copy of a JSEntity.  Copies of maps are unlocked.
+func DeepCopyJSEntity(e JSEntity) JSEntity {
+	switch x := e.(type) {
+	case JSMap:
+		m := NewJSMap()
+		for k, v := range x.wrappedMap {
+			m.wrappedMap[k] = DeepCopyJSEntity(v)
+		}
+		return m
+	case JSList:
+		list := NewJSList()
+		for _, v := range x.wrappedList {
+			list.wrappedList = append(list.wrappedList, DeepCopyJSEntity(v))
+		}
+		return list
+	case JBytes:
+		return JBytes(append([]byte(nil), x...))
+	}
+	return e
+}
+
+// ---------------------------------------------------------------------------------------
+// Diff
+// ---------------------------------------------------------------------------------------
+
+// Construct an RFC 6902 patch that transforms source into target.  Map keys are
+// processed in the order given by OrderedKeys.
+func JsonDiff(source JSEntity, target JSEntity) JSList {
+	patch := NewJSList()
+	auxJsonDiff(patch, nil, source, target)
+	return patch
+}
+
+func patchOp(op string, path []string) JSMap {
+	return NewJSMap().Put("op", op).Put("path", JsonPointer(path...))
+}
+
+func auxJsonDiff(patch JSList, path []string, source JSEntity, target JSEntity) {
+	if JSEntitiesEqual(source, target) {
+		return
+	}
+	switch s := source.(type) {
+	case JSMap:
+		if t, ok := target.(JSMap); ok {
+			for _, k := range s.OrderedKeys() {
+				childPath := append(path[:len(path):len(path)], k)
+				tv := t.wrappedMap[k]
+				if tv == nil {
+					patch.Add(patchOp("remove", childPath))
+				} else {
+					auxJsonDiff(patch, childPath, s.wrappedMap[k], tv)
+				}
+			}
+			for _, k := range t.OrderedKeys() {
+				if !s.HasKey(k) {
+					childPath := append(path[:len(path):len(path)], k)
+					patch.Add(patchOp("add", childPath).Put("value", DeepCopyJSEntity(t.wrappedMap[k])))
+				}
+			}
+			return
+		}
+	case JSList:
+		if t, ok := target.(JSList); ok {
+			common := MinInt(s.Length(), t.Length())
+			for i := 0; i < common; i++ {
+				auxJsonDiff(patch, append(path[:len(path):len(path)], IntToString(i)), s.Get(i), t.Get(i))
+			}
+			// Remove excess elements from the end, so the indices of the earlier ones don't change
+			for i := s.Length() - 1; i >= common; i-- {
+				patch.Add(patchOp("remove", append(path[:len(path):len(path)], IntToString(i))))
+			}
+			for i := common; i < t.Length(); i++ {
+				patch.Add(patchOp("add", append(path[:len(path):len(path)], "-")).Put("value", DeepCopyJSEntity(t.Get(i))))
+			}
+			return
+		}
+	}
+	patch.Add(patchOp("replace", path).Put("value", DeepCopyJSEntity(target)))
+}
+
+// ---------------------------------------------------------------------------------------
+// Applying RFC 6902 patches
+// ---------------------------------------------------------------------------------------
+
+// Apply an RFC 6902 patch to a document, returning the modified document.  The original is not
+// modified; if any operation fails, an error is returned and no result.
+func ApplyJsonPatch(document JSEntity, patch JSList) (JSEntity, error) {
+	doc := DeepCopyJSEntity(document)
+	for i, ent := range patch.wrappedList {
+		opMap, ok := ent.(JSMap)
+		if !ok {
+			return nil, Error("patch operation", IntToString(i), "is not a map")
+		}
+		var err error
+		doc, err = applyPatchOperation(doc, opMap)
+		if err != nil {
+			return nil, Error("patch operation", IntToString(i), "("+opMap.OptString("op", "?")+") failed:", err)
+		}
+	}
+	return doc, nil
+}
+
+func ApplyJsonPatchM(document JSEntity, patch JSList) JSEntity {
+	return CheckOkWith(ApplyJsonPatch(document, patch))
+}
+
+func applyPatchOperation(doc JSEntity, op JSMap) (JSEntity, error) {
+	path, err := patchStringField(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	opName, err := patchStringField(op, "op")
+	if err != nil {
+		return nil, err
+	}
+	switch opName {
+	case "add", "replace", "test":
+		value := op.OptAny("value")
+		if value == nil {
+			return nil, Error("missing 'value'")
+		}
+		switch opName {
+		case "add":
+			return patchAdd(doc, path, DeepCopyJSEntity(value))
+		case "replace":
+			if _, err = jsonPointerGet(doc, path); err != nil {
+				return nil, err
+			}
+			if doc, err = patchRemove(doc, path); err != nil {
+				return nil, err
+			}
+			return patchAdd(doc, path, DeepCopyJSEntity(value))
+		default:
+			existing, err := jsonPointerGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !JSEntitiesEqual(existing, value) {
+				return nil, Error("test failed for path:", Quoted(path))
+			}
+			return doc, nil
+		}
+	case "remove":
+		return patchRemove(doc, path)
+	case "move", "copy":
+		from, err := patchStringField(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonPointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if opName == "move" {
+			if from == path {
+				return doc, nil
+			}
+			if len(path) > len(from) && path[:len(from)+1] == from+"/" {
+				return nil, Error("can't move a value into one of its children:", Quoted(path))
+			}
+			if doc, err = patchRemove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = DeepCopyJSEntity(value)
+		}
+		return patchAdd(doc, path, value)
+	}
+	return nil, Error("unsupported operation:", Quoted(opName))
+}
+
+func patchStringField(op JSMap, key string) (string, error) {
+	value, ok := op.OptAny(key).(JString)
+	if !ok {
+		return "", Error("missing or non-string", Quoted(key))
+	}
+	return value.AsString(), nil
+}
+
+// Perform an 'add' operation; returns the (possibly replaced) document
+func patchAdd(doc JSEntity, pointer string, value JSEntity) (JSEntity, error) {
+	r, err := newJsonPointerResolver(pointer)
+	if err != nil {
+		return nil, err
+	}
+	n := len(r.segments)
+	if n == 0 {
+		return value, nil
+	}
+	parent, err := r.walk(doc, n-1, false)
+	if err != nil {
+		return nil, err
+	}
+	switch c := parent.(type) {
+	case JSMap:
+		if c.locked {
+			return nil, r.fail(n-1, "map is locked")
+		}
+		c.wrappedMap[r.segments[n-1]] = value
+	case JSList:
+		index, err := r.listIndex(n-1, c.Length(), true)
+		if err != nil {
+			return nil, err
+		}
+		c.Insert(index, value)
+	default:
+		return nil, r.fail(n-1, "parent is not a map or list:", TypeOf(parent))
+	}
+	return doc, nil
+}
+
+// Perform a 'remove' operation; returns the (possibly replaced) document
+func patchRemove(doc JSEntity, pointer string) (JSEntity, error) {
+	if pointer == "" {
+		return JNullValue, nil
+	}
+	return doc, jsonPointerDelete(doc, pointer)
+}
+
+// ---------------------------------------------------------------------------------------
+// RFC 7386 merge patches
+// ---------------------------------------------------------------------------------------
+
+// Apply an RFC 7386 merge patch to a document, returning the result.  The original is not modified.
+func ApplyMergePatch(document JSEntity, patch JSEntity) JSEntity {
+	patchMap, ok := patch.(JSMap)
+	if !ok {
+		return DeepCopyJSEntity(patch)
+	}
+	var result JSMap
+	if m, ok := document.(JSMap); ok {
+		result = DeepCopyJSEntity(m).AsJSMap()
+	} else {
+		result = NewJSMap()
+	}
+	for _, k := range patchMap.OrderedKeys() {
+		v := patchMap.wrappedMap[k]
+		if v == JNullValue {
+			delete(result.wrappedMap, k)
+			continue
+		}
+		existing := result.wrappedMap[k]
+		if existing == nil {
+			existing = JNullValue
+		}
+		result.wrappedMap[k] = ApplyMergePatch(existing, v)
+	}
+	return result
+}
+
+// Construct an RFC 7386 merge patch that transforms source into target.  Since merge patches
+// can't express changes within lists, or values that are null, such values are replaced entirely.
+func MergePatchDiff(source JSMap, target JSMap) JSMap {
+	patch := NewJSMap()
+	for _, k := range source.OrderedKeys() {
+		if !target.HasKey(k) {
+			patch.wrappedMap[k] = JNullValue
+		}
+	}
+	for _, k := range target.OrderedKeys() {
+		tv := target.wrappedMap[k]
+		sv := source.wrappedMap[k]
+		if sv != nil && JSEntitiesEqual(sv, tv) {
+			continue
+		}
+		sm, ok1 := sv.(JSMap)
+		tm, ok2 := tv.(JSMap)
+		if ok1 && ok2 {
+			patch.wrappedMap[k] = MergePatchDiff(sm, tm)
+		} else {
+			patch.wrappedMap[k] = DeepCopyJSEntity(tv)
+		}
+	}
+	return patch
+}
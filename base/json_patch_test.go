@@ -0,0 +1,63 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+var patchSource = `{"name":"fido","age":3,"tags":["a","b","c"],"owner":{"first":"jeff","last":"s"},"gone":true}`
+var patchTarget = `{"name":"rex","age":3,"tags":["a","z"],"owner":{"first":"jeff","city":"toronto"},"new":[1,2]}`
+
+func TestJsonDiff(t *testing.T) {
+	j := jt.New(t)
+	a := JSMapFromStringM(patchSource)
+	b := JSMapFromStringM(patchTarget)
+	patch := JsonDiff(a, b)
+	result := ApplyJsonPatchM(a, patch)
+	j.AssertTrue(JSEntitiesEqual(result, b))
+	// The source should be unchanged
+	j.AssertTrue(JSEntitiesEqual(a, JSMapFromStringM(patchSource)))
+	j.AssertMessage(patch)
+}
+
+func TestJsonDiffRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	samples := []string{`{}`, patchSource, patchTarget, `{"tags":[]}`, `{"tags":{"a":1}}`, `{"owner":[{"x":1},2]}`}
+	for _, s1 := range samples {
+		for _, s2 := range samples {
+			a := JSMapFromStringM(s1)
+			b := JSMapFromStringM(s2)
+			result := ApplyJsonPatchM(a, JsonDiff(a, b))
+			j.AssertTrue(JSEntitiesEqual(result, b), s1, "=>", s2)
+
+			merged := ApplyMergePatch(a, MergePatchDiff(a, b))
+			j.AssertTrue(JSEntitiesEqual(merged, b), s1, "=>", s2)
+		}
+	}
+}
+
+func TestJsonPatchOperations(t *testing.T) {
+	j := jt.New(t)
+	doc := JSMapFromStringM(`{"a":{"b":[1,2,3]},"c":"x"}`)
+	patch := JSListFromStringM(`[
+	  {"op":"add","path":"/a/b/1","value":9},
+	  {"op":"move","from":"/c","path":"/d"},
+	  {"op":"copy","from":"/a/b","path":"/e"},
+	  {"op":"test","path":"/e/1","value":9},
+	  {"op":"remove","path":"/a/b/0"},
+	  {"op":"replace","path":"/a/b/0","value":"q"},
+	]`)
+	result := ApplyJsonPatchM(doc, patch)
+
+	bad := JSListFromStringM(`[{"op":"test","path":"/c","value":"y"}]`)
+	_, err := ApplyJsonPatch(doc, bad)
+	j.AssertMessage(result, CR, err)
+}
+
+func TestMergePatch(t *testing.T) {
+	j := jt.New(t)
+	doc := JSMapFromStringM(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	patch := JSMapFromStringM(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`)
+	j.AssertMessage(ApplyMergePatch(doc, patch))
+}
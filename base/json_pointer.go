@@ -0,0 +1,291 @@
+package base
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Support for addressing values within a JSMap or JSList by RFC 6901 JSON Pointers,
+// e.g. "/animals/3/name".
+
+type JsonPointerError struct {
+	Pointer string
+	// The (unescaped) segment that could not be resolved
+	Segment string
+	// The index of that segment within the pointer
+	SegmentIndex int
+	Problem      string
+}
+
+func (e *JsonPointerError) Error() string {
+	if e.SegmentIndex < 0 {
+		return ToString("JSON pointer", Quoted(e.Pointer)+";", e.Problem)
+	}
+	return ToString("JSON pointer", Quoted(e.Pointer)+", segment", Quoted(e.Segment)+";", e.Problem)
+}
+
+// Split a JSON pointer into its unescaped segments
+func ParseJsonPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &JsonPointerError{Pointer: pointer, SegmentIndex: -1, Problem: "must be empty or start with '/'"}
+	}
+	segments := strings.Split(pointer[1:], "/")
+	for i, s := range segments {
+		if strings.Contains(s, "~") {
+			for j := 0; j < len(s); j++ {
+				if s[j] == '~' && (j+1 == len(s) || (s[j+1] != '0' && s[j+1] != '1')) {
+					return nil, &JsonPointerError{Pointer: pointer, Segment: s, SegmentIndex: i, Problem: "bad escape sequence"}
+				}
+			}
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+		}
+	}
+	return segments, nil
+}
+
+// Construct a JSON pointer from a list of (unescaped) segments
+func JsonPointer(segments ...string) string {
+	sb := strings.Builder{}
+	for _, s := range segments {
+		sb.WriteByte('/')
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
+
+type jsonPointerResolver struct {
+	pointer  string
+	segments []string
+}
+
+func newJsonPointerResolver(pointer string) (*jsonPointerResolver, error) {
+	segments, err := ParseJsonPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPointerResolver{pointer: pointer, segments: segments}, nil
+}
+
+func (r *jsonPointerResolver) fail(segmentIndex int, problem ...any) error {
+	return &JsonPointerError{Pointer: r.pointer, Segment: r.segments[segmentIndex], SegmentIndex: segmentIndex, Problem: ToString(problem...)}
+}
+
+// Parse a segment as an index into a list of a particular length.  If allowEnd is true,
+// the index can equal the length (or be "-"), to refer to a position past the last element.
+func (r *jsonPointerResolver) listIndex(segmentIndex int, length int, allowEnd bool) (int, error) {
+	s := r.segments[segmentIndex]
+	if s == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, r.fail(segmentIndex, "refers to nonexistent element")
+	}
+	if s == "" || (len(s) > 1 && s[0] == '0') || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, r.fail(segmentIndex, "not a valid list index")
+	}
+	index, err := strconv.Atoi(s)
+	limit := length
+	if allowEnd {
+		limit++
+	}
+	if err != nil || index >= limit {
+		return 0, r.fail(segmentIndex, "index out of range; list length:", IntToString(length))
+	}
+	return index, nil
+}
+
+// Follow the first n segments of the pointer, starting from a container.  If create is true,
+// missing map values are created as (empty) maps.
+func (r *jsonPointerResolver) walk(root JSEntity, n int, create bool) (JSEntity, error) {
+	current := root
+	for i := 0; i < n; i++ {
+		seg := r.segments[i]
+		switch c := current.(type) {
+		case JSMap:
+			child := c.wrappedMap[seg]
+			if child == nil {
+				if !create {
+					return nil, r.fail(i, "no such key")
+				}
+				if c.locked {
+					return nil, r.fail(i, "map is locked")
+				}
+				child = NewJSMap()
+				c.wrappedMap[seg] = child
+			}
+			current = child
+		case JSList:
+			index, err := r.listIndex(i, c.Length(), false)
+			if err != nil {
+				return nil, err
+			}
+			current = c.wrappedList[index]
+		default:
+			return nil, r.fail(i, "parent is not a map or list:", TypeOf(current))
+		}
+	}
+	return current, nil
+}
+
+func jsonPointerGet(root JSEntity, pointer string) (JSEntity, error) {
+	r, err := newJsonPointerResolver(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return r.walk(root, len(r.segments), false)
+}
+
+func jsonPointerSet(root JSEntity, pointer string, value any) error {
+	r, err := newJsonPointerResolver(pointer)
+	if err != nil {
+		return err
+	}
+	n := len(r.segments)
+	if n == 0 {
+		return &JsonPointerError{Pointer: pointer, SegmentIndex: -1, Problem: "can't replace the root"}
+	}
+	parent, err := r.walk(root, n-1, true)
+	if err != nil {
+		return err
+	}
+	last := n - 1
+	switch c := parent.(type) {
+	case JSMap:
+		if c.locked {
+			return r.fail(last, "map is locked")
+		}
+		c.wrappedMap[r.segments[last]] = ToJSEntity(value)
+	case JSList:
+		index, err := r.listIndex(last, c.Length(), true)
+		if err != nil {
+			return err
+		}
+		if index == c.Length() {
+			c.Add(value)
+		} else {
+			c.Set(index, value)
+		}
+	default:
+		return r.fail(last, "parent is not a map or list:", TypeOf(parent))
+	}
+	return nil
+}
+
+func jsonPointerDelete(root JSEntity, pointer string) error {
+	r, err := newJsonPointerResolver(pointer)
+	if err != nil {
+		return err
+	}
+	n := len(r.segments)
+	if n == 0 {
+		return &JsonPointerError{Pointer: pointer, SegmentIndex: -1, Problem: "can't delete the root"}
+	}
+	parent, err := r.walk(root, n-1, false)
+	if err != nil {
+		return err
+	}
+	last := n - 1
+	switch c := parent.(type) {
+	case JSMap:
+		key := r.segments[last]
+		if !c.HasKey(key) {
+			return r.fail(last, "no such key")
+		}
+		if c.locked {
+			return r.fail(last, "map is locked")
+		}
+		delete(c.wrappedMap, key)
+	case JSList:
+		index, err := r.listIndex(last, c.Length(), false)
+		if err != nil {
+			return err
+		}
+		c.Remove(index)
+	default:
+		return r.fail(last, "parent is not a map or list:", TypeOf(parent))
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------
+// JSMap methods
+// ---------------------------------------------------------------------------------------
+
+// Get the value addressed by a JSON pointer
+func (m JSMap) GetPath(pointer string) (JSEntity, error) {
+	return jsonPointerGet(m, pointer)
+}
+
+func (m JSMap) GetPathM(pointer string) JSEntity {
+	return CheckOkWith(m.GetPath(pointer))
+}
+
+// Determine if a JSON pointer addresses an existing value
+func (m JSMap) HasPath(pointer string) bool {
+	_, err := m.GetPath(pointer)
+	return err == nil
+}
+
+// Store a value at the location addressed by a JSON pointer, creating intermediate maps as necessary.
+// If the final segment refers to a list element, it is replaced; if it is one past the last element
+// (or "-"), the value is appended.
+func (m JSMap) SetPath(pointer string, value any) error {
+	return jsonPointerSet(m, pointer, value)
+}
+
+func (m JSMap) SetPathM(pointer string, value any) JSMap {
+	CheckOk(m.SetPath(pointer, value))
+	return m
+}
+
+// Delete the value addressed by a JSON pointer; it is an error if it doesn't exist
+func (m JSMap) DeletePath(pointer string) error {
+	return jsonPointerDelete(m, pointer)
+}
+
+func (m JSMap) DeletePathM(pointer string) JSMap {
+	CheckOk(m.DeletePath(pointer))
+	return m
+}
+
+// ---------------------------------------------------------------------------------------
+// JSList methods
+// ---------------------------------------------------------------------------------------
+
+// Get the value addressed by a JSON pointer
+func (js JSList) GetPath(pointer string) (JSEntity, error) {
+	return jsonPointerGet(js, pointer)
+}
+
+func (js JSList) GetPathM(pointer string) JSEntity {
+	return CheckOkWith(js.GetPath(pointer))
+}
+
+// Determine if a JSON pointer addresses an existing value
+func (js JSList) HasPath(pointer string) bool {
+	_, err := js.GetPath(pointer)
+	return err == nil
+}
+
+// Store a value at the location addressed by a JSON pointer; see JSMap.SetPath
+func (js JSList) SetPath(pointer string, value any) error {
+	return jsonPointerSet(js, pointer, value)
+}
+
+func (js JSList) SetPathM(pointer string, value any) JSList {
+	CheckOk(js.SetPath(pointer, value))
+	return js
+}
+
+// Delete the value addressed by a JSON pointer; it is an error if it doesn't exist
+func (js JSList) DeletePath(pointer string) error {
+	return jsonPointerDelete(js, pointer)
+}
+
+func (js JSList) DeletePathM(pointer string) JSList {
+	CheckOk(js.DeletePath(pointer))
+	return js
+}
@@ -0,0 +1,47 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+const pointerSample = `{"animals":[{"name":"fido"},{"name":"rex","tags":["a","b"]}],"a/b":{"m~n":7}}`
+
+func TestJsonPointerGet(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromStringM(pointerSample)
+	results := NewJSMap()
+	for _, ptr := range []string{"", "/animals/1/name", "/animals/1/tags/0", "/a~1b/m~0n",
+		"/animals/2/name", "/animals/01", "/animals/-", "/animals/0/age", "/animals/0/name/x", "animals", "/a~2b"} {
+		value, err := m.GetPath(ptr)
+		if err != nil {
+			results.Put(ptr, err.Error())
+		} else {
+			results.Put(ptr, value)
+		}
+	}
+	j.AssertMessage(results)
+}
+
+func TestJsonPointerSetAndDelete(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromStringM(pointerSample)
+	m.SetPathM("/animals/0/age", 3)
+	m.SetPathM("/animals/-", NewJSMap().Put("name", "spot"))
+	m.SetPathM("/animals/1/tags/0", "z")
+	m.SetPathM("/new/nested/value", true)
+	m.DeletePathM("/animals/1/tags/1")
+	m.DeletePathM("/a~1b")
+	j.AssertTrue(m.HasPath("/new/nested"))
+	j.AssertFalse(m.HasPath("/a~1b"))
+	j.AssertTrue(m.DeletePath("/animals/7") != nil)
+
+	locked := NewJSMap().Lock()
+	m.Put("locked", locked)
+	err := m.SetPath("/locked/x", 1)
+	j.AssertTrue(err != nil)
+	j.AssertEqual(JsonPointer("animals", "a/b", "~"), "/animals/a~1b/~0")
+
+	j.AssertMessage(m, CR, err)
+}
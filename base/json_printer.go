@@ -1,11 +1,22 @@
 package base
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf16"
 )
 
 type JSONPrinter struct {
-	Pretty        bool
+	Pretty bool
+	// If true, produce the canonical form described by RFC 8785 (JSON Canonicalization Scheme):
+	// no whitespace, map keys sorted by their UTF-16 code units, minimal string escaping, and
+	// numbers formatted as ECMAScript does.  Takes precedence over Pretty.
+	Canonical     bool
 	StringBuilder *strings.Builder
 	indent        int
 	indentStack   []int
@@ -51,3 +62,95 @@ func PrintJSEntity(jsEntity JSEntity, pretty bool) string {
 	jsEntity.PrintTo(printer)
 	return printer.GetPrintResult()
 }
+
+// Print a JSEntity in the canonical form described by RFC 8785.  Two entities that are
+// logically equal (regardless of key insertion order or number representation) produce the same text.
+func CanonicalJSON(jsEntity JSEntity) string {
+	var printer = NewJSONPrinter(false)
+	printer.Canonical = true
+	jsEntity.PrintTo(printer)
+	return printer.GetPrintResult()
+}
+
+// Calculate a 64-bit FNV-1a hash of a JSEntity's canonical form
+func ContentHash64(jsEntity JSEntity) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(CanonicalJSON(jsEntity)))
+	return h.Sum64()
+}
+
+// Calculate the SHA-256 hash of a JSEntity's canonical form, as a hex string
+func ContentHashSHA256(jsEntity JSEntity) string {
+	sum := sha256.Sum256([]byte(CanonicalJSON(jsEntity)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Write a string in canonical form: only quotes, backslashes and control characters are escaped
+func writeCanonicalString(sb *strings.Builder, str string) {
+	sb.WriteByte('"')
+	for _, c := range str {
+		switch c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(c)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < ' ' {
+				sb.WriteString(`\u`)
+				sb.Write(toHex(nil, int(c), 4))
+			} else {
+				sb.WriteRune(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// Format a float as ECMAScript's Number.prototype.toString() would
+func canonicalFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		BadArg("Can't represent value in canonical JSON:", f)
+	}
+	if f == 0 {
+		return "0"
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, 64)
+	if format == 'e' {
+		// Go writes "1e-07"; ECMAScript writes "1e-7"
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return string(b)
+}
+
+// Sort keys by their UTF-16 code units, as RFC 8785 requires
+func canonicalKeyOrder(keys []string) []string {
+	sort.Slice(keys, func(i, j int) bool {
+		a := utf16.Encode([]rune(keys[i]))
+		b := utf16.Encode([]rune(keys[j]))
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return keys
+}
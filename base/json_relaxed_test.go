@@ -0,0 +1,33 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+const relaxedSample = `
+/* A hand-edited
+   configuration file */
+{
+  name: 'fido',            // unquoted key, single-quoted value
+  "age": 3,
+  $tags: ['a', "b", 'it\'s',],
+  nested_1: { x: /* inline */ true, },
+}
+`
+
+func TestRelaxedParse(t *testing.T) {
+	j := jt.New(t)
+	j.AssertMessage(JSMapFromStringRelaxedM(relaxedSample))
+}
+
+func TestStrictRejectsRelaxed(t *testing.T) {
+	j := jt.New(t)
+	for _, s := range []string{relaxedSample, `{name:1}`, `{"a":'b'}`, `{"a":1 /* x */}`} {
+		_, err := JSMapFromString(s)
+		j.AssertTrue(err != nil, "strict mode accepted:", s)
+	}
+	_, err := JSMapFromStringRelaxed(`{"a":1 /* unterminated }`)
+	j.AssertTrue(err != nil)
+}
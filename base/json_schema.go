@@ -0,0 +1,356 @@
+package base
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Validates JSEntities against a practical subset of JSON Schema:
+//
+//	type, required, properties, additionalProperties, enum, minimum, maximum,
+//	exclusiveMinimum, exclusiveMaximum, minLength, maxLength, minItems, maxItems,
+//	pattern, items, and $ref (to locations within the same schema document, e.g. "#/$defs/animal")
+type JsonSchemaStruct struct {
+	root JSMap
+}
+
+type JsonSchema = *JsonSchemaStruct
+
+// A single problem found while validating against a JsonSchema
+type SchemaViolation struct {
+	// JSON pointer to the offending value
+	Path    string
+	Problem string
+}
+
+func (v *SchemaViolation) Error() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + v.Problem
+}
+
+func NewJsonSchema(schema JSMap) JsonSchema {
+	return &JsonSchemaStruct{root: schema}
+}
+
+func JsonSchemaFromFile(file Path) (JsonSchema, error) {
+	var result JsonSchema
+	m, err := JSMapFromFile(file)
+	if err == nil {
+		result = NewJsonSchema(m)
+	}
+	return result, err
+}
+
+func JsonSchemaFromFileM(file Path) JsonSchema {
+	return CheckOkWith(JsonSchemaFromFile(file))
+}
+
+// Validate a value, returning all violations found (or an empty list)
+func (s JsonSchema) Validate(value JSEntity) []*SchemaViolation {
+	v := schemaValidator{root: s.root}
+	v.validate(s.root, value, nil, 0)
+	return v.violations
+}
+
+// Validate a value, returning an error that describes all of the violations (or nil if there were none)
+func (s JsonSchema) Check(value JSEntity) error {
+	return SchemaViolationsError(s.Validate(value))
+}
+
+// Validate the values within a value, ignoring which keys its maps contain (i.e., the "required" and
+// "additionalProperties" keywords).  This is for values that have been merged with defaults, where
+// a missing key has been filled in, and unknown keys have already been reported
+func (s JsonSchema) ValidateValues(value JSEntity) []*SchemaViolation {
+	v := schemaValidator{root: s.root, valuesOnly: true}
+	v.validate(s.root, value, nil, 0)
+	return v.violations
+}
+
+// Validate the values within a value (see ValidateValues), returning an error that describes all of
+// the violations (or nil if there were none)
+func (s JsonSchema) CheckValues(value JSEntity) error {
+	return SchemaViolationsError(s.ValidateValues(value))
+}
+
+// Construct an error describing a list of violations; nil if the list is empty
+func SchemaViolationsError(violations []*SchemaViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	sb := strings.Builder{}
+	sb.WriteString("schema validation failed:")
+	for _, x := range violations {
+		sb.WriteString("\n  ")
+		sb.WriteString(x.Error())
+	}
+	return Error(sb.String())
+}
+
+type schemaValidator struct {
+	root       JSMap
+	valuesOnly bool
+	violations []*SchemaViolation
+}
+
+func (v *schemaValidator) fail(path []string, problem ...any) {
+	v.violations = append(v.violations, &SchemaViolation{Path: JsonPointer(path...), Problem: ToString(problem...)})
+}
+
+// Report a problem with the schema itself, rather than with the value being validated
+func (v *schemaValidator) schemaFail(path []string, problem ...any) {
+	v.fail(path, append([]any{"schema error:"}, problem...)...)
+}
+
+// Get a keyword's value from a schema, if it has the expected type; otherwise, report a schema error
+// (if the keyword is present with some other type) and return false
+func schemaKeyword[T JSEntity](v *schemaValidator, s JSMap, key string, expected string, path []string) (T, bool) {
+	var result T
+	x := s.OptAny(key)
+	if x == nil {
+		return result, false
+	}
+	result, ok := x.(T)
+	if !ok {
+		v.schemaFail(path, Quoted(key), "should be", expected+", found:", Truncated(x))
+	}
+	return result, ok
+}
+
+func (v *schemaValidator) stringKeyword(s JSMap, key string, path []string) (string, bool) {
+	x, ok := schemaKeyword[JString](v, s, key, "a string", path)
+	return string(x), ok
+}
+
+func (v *schemaValidator) numberKeyword(s JSMap, key string, path []string) (float64, bool) {
+	x := s.OptAny(key)
+	switch x.(type) {
+	case nil:
+		return 0, false
+	case JInteger, JFloat, JNumber:
+		return x.AsFloat(), true
+	}
+	v.schemaFail(path, Quoted(key), "should be a number, found:", Truncated(x))
+	return 0, false
+}
+
+func (v *schemaValidator) listKeyword(s JSMap, key string, path []string) (JSList, bool) {
+	return schemaKeyword[JSList](v, s, key, "a list", path)
+}
+
+func schemaChildPath(path []string, segment string) []string {
+	return append(path[:len(path):len(path)], segment)
+}
+
+func (v *schemaValidator) validate(schema JSEntity, value JSEntity, path []string, depth int) {
+	// A schema of true accepts anything; false accepts nothing
+	if b, ok := schema.(JBool); ok {
+		if !b {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	}
+	s, ok := schema.(JSMap)
+	if !ok {
+		v.schemaFail(path, "schema is not a map:", Truncated(schema))
+		return
+	}
+	if depth > 100 {
+		v.fail(path, "schema references are too deeply nested")
+		return
+	}
+
+	if ref, ok := v.stringKeyword(s, "$ref", path); ok {
+		target, err := v.resolveRef(ref)
+		if err != nil {
+			v.schemaFail(path, err)
+			return
+		}
+		v.validate(target, value, path, depth+1)
+	}
+
+	if t := s.OptAny("type"); t != nil {
+		names, ok := schemaTypeNames(t)
+		if !ok {
+			v.schemaFail(path, "\"type\" should be a string or a list of strings, found:", Truncated(t))
+		} else if !typeMatches(names, value) {
+			v.fail(path, "expected type", Truncated(t)+", found:", jsonTypeName(value))
+			// Further checks would only produce noise
+			return
+		}
+	}
+
+	if e, ok := v.listKeyword(s, "enum", path); ok {
+		found := false
+		for _, x := range e.wrappedList {
+			if JSEntitiesEqual(x, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value", Truncated(PrintJSEntity(value, false)), "is not one of", e.CompactString())
+		}
+	}
+
+	switch x := value.(type) {
+	case JInteger, JFloat, JNumber:
+		v.checkNumber(s, x.AsFloat(), path)
+	case JString, JBytes:
+		v.checkString(s, x.AsString(), path)
+	case JSList:
+		v.checkList(s, x, path, depth)
+	case JSMap:
+		v.checkMap(s, x, path, depth)
+	}
+}
+
+func (v *schemaValidator) resolveRef(ref string) (JSEntity, error) {
+	pointer, found := strings.CutPrefix(ref, "#")
+	if !found {
+		return nil, Error("only references within the schema are supported:", Quoted(ref))
+	}
+	return v.root.GetPath(pointer)
+}
+
+func jsonTypeName(value JSEntity) string {
+	switch value.(type) {
+	case JInteger:
+		return "integer"
+	case JFloat:
+		return "number"
+	case JNumber:
+		return Ternary(value.(JNumber).IsIntegral(), "integer", "number")
+	case JString, JBytes:
+		return "string"
+	case JBool:
+		return "boolean"
+	case JSMap:
+		return "object"
+	case JSList:
+		return "array"
+	}
+	return "null"
+}
+
+// Get the type names from a schema's "type" keyword, which is a string or a list of strings; false if it is neither
+func schemaTypeNames(t JSEntity) ([]string, bool) {
+	var names []string
+	if list, ok := t.(JSList); ok {
+		for _, x := range list.wrappedList {
+			n, ok := x.(JString)
+			if !ok {
+				return nil, false
+			}
+			names = append(names, string(n))
+		}
+		return names, true
+	}
+	n, ok := t.(JString)
+	return []string{string(n)}, ok
+}
+
+func typeMatches(names []string, value JSEntity) bool {
+	actual := jsonTypeName(value)
+	for _, n := range names {
+		if n == actual || (n == "number" && actual == "integer") {
+			return true
+		}
+		// A float with no fractional part is considered an integer
+		if n == "integer" && actual == "number" {
+			f := value.AsFloat()
+			if f == float64(int64(f)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *schemaValidator) checkNumber(s JSMap, f float64, path []string) {
+	if x, ok := v.numberKeyword(s, "minimum", path); ok && f < x {
+		v.fail(path, "value", schemaNumber(f), "is less than minimum", schemaNumber(x))
+	}
+	if x, ok := v.numberKeyword(s, "maximum", path); ok && f > x {
+		v.fail(path, "value", schemaNumber(f), "is greater than maximum", schemaNumber(x))
+	}
+	if x, ok := v.numberKeyword(s, "exclusiveMinimum", path); ok && f <= x {
+		v.fail(path, "value", schemaNumber(f), "is not greater than exclusive minimum", schemaNumber(x))
+	}
+	if x, ok := v.numberKeyword(s, "exclusiveMaximum", path); ok && f >= x {
+		v.fail(path, "value", schemaNumber(f), "is not less than exclusive maximum", schemaNumber(x))
+	}
+}
+
+func schemaNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (v *schemaValidator) checkString(s JSMap, str string, path []string) {
+	length := float64(len([]rune(str)))
+	if x, ok := v.numberKeyword(s, "minLength", path); ok && length < x {
+		v.fail(path, "length", schemaNumber(length), "is less than minLength", schemaNumber(x))
+	}
+	if x, ok := v.numberKeyword(s, "maxLength", path); ok && length > x {
+		v.fail(path, "length", schemaNumber(length), "is greater than maxLength", schemaNumber(x))
+	}
+	if pat, ok := v.stringKeyword(s, "pattern", path); ok {
+		// Note that JSON Schema patterns are not implicitly anchored
+		re, err := CompileRegexp(pat)
+		if err != nil {
+			v.schemaFail(path, "bad pattern", Quoted(pat)+":", err)
+		} else if !re.MatchString(str) {
+			v.fail(path, "value", Quoted(str), "doesn't match pattern", Quoted(pat))
+		}
+	}
+}
+
+func (v *schemaValidator) checkList(s JSMap, list JSList, path []string, depth int) {
+	length := float64(list.Length())
+	if x, ok := v.numberKeyword(s, "minItems", path); ok && length < x {
+		v.fail(path, "list has fewer than", schemaNumber(x), "items")
+	}
+	if x, ok := v.numberKeyword(s, "maxItems", path); ok && length > x {
+		v.fail(path, "list has more than", schemaNumber(x), "items")
+	}
+	if items := s.OptAny("items"); items != nil {
+		for i, elem := range list.wrappedList {
+			v.validate(items, elem, schemaChildPath(path, IntToString(i)), depth+1)
+		}
+	}
+}
+
+func (v *schemaValidator) checkMap(s JSMap, m JSMap, path []string, depth int) {
+	if req, ok := v.listKeyword(s, "required", path); ok {
+		for _, k := range req.wrappedList {
+			key, ok := k.(JString)
+			if !ok {
+				v.schemaFail(path, "\"required\" should contain strings, found:", Truncated(k))
+			} else if !m.HasKey(string(key)) && !v.valuesOnly {
+				v.fail(schemaChildPath(path, string(key)), "required key is missing")
+			}
+		}
+	}
+	props, _ := schemaKeyword[JSMap](v, s, "properties", "a map", path)
+	additional := s.OptAny("additionalProperties")
+	for _, k := range m.OrderedKeys() {
+		p := schemaChildPath(path, k)
+		var propSchema JSEntity
+		if props != nil {
+			propSchema = props.OptAny(k)
+		}
+		if propSchema != nil {
+			v.validate(propSchema, m.wrappedMap[k], p, depth+1)
+		} else if additional != nil {
+			if b, ok := additional.(JBool); ok && !bool(b) {
+				if !v.valuesOnly {
+					v.fail(p, "key is not allowed")
+				}
+			} else {
+				v.validate(additional, m.wrappedMap[k], p, depth+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+const schemaSample = `
+{"type":"object",
+ "required":["name","age"],
+ "additionalProperties":false,
+ "properties": {
+   "name":{"type":"string","minLength":2,"pattern":"^[a-z]+$"},
+   "age":{"type":"integer","minimum":0,"maximum":30},
+   "kind":{"enum":["dog","cat"]},
+   "weight":{"type":"number","exclusiveMinimum":0},
+   "friends":{"type":"array","maxItems":2,"items":{"$ref":"#/$defs/friend"}},
+ },
+ "$defs": {
+   "friend":{"type":"object","required":["name"],"properties":{"name":{"type":"string"}}},
+ },
+}
+`
+
+func TestSchemaValid(t *testing.T) {
+	j := jt.New(t)
+	schema := NewJsonSchema(JSMapFromStringM(schemaSample))
+	doc := JSMapFromStringM(`{"name":"fido","age":3,"kind":"dog","weight":2.5,"friends":[{"name":"rex"}]}`)
+	j.AssertEqual(0, len(schema.Validate(doc)))
+	j.AssertEqual(nil, schema.Check(doc))
+}
+
+func TestSchemaViolations(t *testing.T) {
+	j := jt.New(t)
+	schema := NewJsonSchema(JSMapFromStringM(schemaSample))
+	doc := JSMapFromStringM(`{"name":"F","age":31.5,"kind":"cow","weight":0,"color":"red",
+      "friends":[{"name":7},{},{"name":"x"}]}`)
+	j.AssertMessage(schema.Check(doc))
+}
+
+func TestSchemaErrors(t *testing.T) {
+	j := jt.New(t)
+	schema := NewJsonSchema(JSMapFromStringM(`
+{"type":"object",
+ "properties": {
+   "name":{"type":7,"pattern":"[a-"},
+   "age":{"type":["integer",false],"minimum":"zero"},
+   "tags":{"required":"name","enum":{}},
+ },
+}`))
+	doc := JSMapFromStringM(`{"name":"fido","age":3,"tags":{}}`)
+	j.AssertMessage(schema.Check(doc))
+}
+
+func TestSchemaCheckValues(t *testing.T) {
+	j := jt.New(t)
+	schema := NewJsonSchema(JSMapFromStringM(schemaSample))
+	// Missing and unknown keys are ignored, but values are still checked
+	j.AssertEqual(nil, schema.CheckValues(JSMapFromStringM(`{"name":"fido","color":"red"}`)))
+	j.AssertEqual(2, len(schema.ValidateValues(JSMapFromStringM(`{"name":"F","color":"red"}`))))
+	j.AssertEqual(4, len(schema.Validate(JSMapFromStringM(`{"name":"F","color":"red"}`))))
+}
@@ -0,0 +1,437 @@
+package base
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// A JSON parser that reads from an io.Reader, producing a sequence of tokens
+// rather than building the entire JSMap or JSList in memory.  It accepts the same
+// grammar as JSONParser, and reports errors as JsonParseErrors with the same cursor
+// and location information.
+type JSONStreamParser struct {
+	reader   *bufio.Reader
+	cursor   int
+	Error    error
+	lossless bool
+	// Number of newlines, and characters read since the last one
+	line      int
+	column    int
+	history   []byte
+	stack     []jsonStreamFrame
+	started   bool
+	listState int
+}
+
+type JSONTokenType int
+
+const (
+	JSONTokenBeginMap JSONTokenType = iota
+	JSONTokenEndMap
+	JSONTokenBeginList
+	JSONTokenEndList
+	JSONTokenKey
+	JSONTokenValue
+)
+
+var jsonTokenTypeNames = []string{"BeginMap", "EndMap", "BeginList", "EndList", "Key", "Value"}
+
+func (t JSONTokenType) String() string {
+	return jsonTokenTypeNames[t]
+}
+
+// A token produced by a JSONStreamParser.  Key is set for JSONTokenKey, and Value for JSONTokenValue.
+type JSONToken struct {
+	Type   JSONTokenType
+	Key    string
+	Value  JSEntity
+	Cursor int
+}
+
+type jsonStreamFrame struct {
+	isMap     bool
+	count     int
+	keyParsed bool
+	key       string
+}
+
+const (
+	streamListState_new = iota
+	streamListState_open
+	streamListState_closed
+)
+
+func NewJSONStreamParser(reader io.Reader) *JSONStreamParser {
+	p := &JSONStreamParser{
+		reader: bufio.NewReader(reader),
+	}
+	return p
+}
+
+// Enable (or disable) lossless number parsing; see JSONParser.WithLosslessNumbers
+func (p *JSONStreamParser) WithLosslessNumbers(lossless bool) *JSONStreamParser {
+	p.lossless = lossless
+	return p
+}
+
+// Read the next token.  Returns io.EOF if there are no more tokens.
+func (p *JSONStreamParser) NextToken() (JSONToken, error) {
+	var token JSONToken
+	if p.hasProblem() {
+		return token, p.Error
+	}
+	p.skipWhitespace()
+
+	if len(p.stack) == 0 {
+		if p.started {
+			if _, err := p.reader.Peek(1); err == nil {
+				p.fail("excess characters")
+				return token, p.Error
+			}
+			return token, io.EOF
+		}
+		p.started = true
+		return p.readValueStart()
+	}
+
+	frame := &p.stack[len(p.stack)-1]
+	if frame.isMap && frame.keyParsed {
+		frame.keyParsed = false
+		return p.readValueStart()
+	}
+
+	closer := byte(']')
+	closeType := JSONTokenEndList
+	if frame.isMap {
+		closer = '}'
+		closeType = JSONTokenEndMap
+	}
+	if !p.readIf(closer) {
+		if frame.count != 0 {
+			p.readExpectedByte(',')
+			if p.hasProblem() {
+				return token, p.Error
+			}
+			// A trailing comma is allowed before the closing bracket
+			if !p.readIf(closer) {
+				return p.readElementStart(frame)
+			}
+		} else {
+			return p.readElementStart(frame)
+		}
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+	token.Type = closeType
+	token.Cursor = p.cursor
+	return token, nil
+}
+
+func (p *JSONStreamParser) readElementStart(frame *jsonStreamFrame) (JSONToken, error) {
+	if !frame.isMap {
+		return p.readValueStart()
+	}
+	var token JSONToken
+	token.Cursor = p.cursor
+	token.Key = readJsonString(p)
+	p.skipWhitespace()
+	p.readExpectedByte(':')
+	if p.hasProblem() {
+		return token, p.Error
+	}
+	frame.count++
+	frame.keyParsed = true
+	frame.key = token.Key
+	token.Type = JSONTokenKey
+	return token, nil
+}
+
+// Read the start of a value: either the start of a map or list, or a complete scalar value
+func (p *JSONStreamParser) readValueStart() (JSONToken, error) {
+	var token JSONToken
+	token.Cursor = p.cursor
+	if n := len(p.stack); n != 0 && !p.stack[n-1].isMap {
+		p.stack[n-1].count++
+	}
+	switch ch := p.peek(); ch {
+	case '{', '[':
+		if len(p.stack) == 100 {
+			p.fail("too many levels of nesting")
+			break
+		}
+		p.read()
+		p.stack = append(p.stack, jsonStreamFrame{isMap: ch == '{'})
+		token.Type = Ternary(ch == '{', JSONTokenBeginMap, JSONTokenBeginList)
+	case '"':
+		token.Type = JSONTokenValue
+		token.Value = MakeJString(readJsonString(p))
+	case 't':
+		p.readExpectedBytes(JSTrue)
+		token.Type, token.Value = JSONTokenValue, JSTrue.value
+	case 'f':
+		p.readExpectedBytes(JSFalse)
+		token.Type, token.Value = JSONTokenValue, JSFalse.value
+	case 'n':
+		p.readExpectedBytes(JSNull)
+		token.Type, token.Value = JSONTokenValue, JSNull.value
+	default:
+		token.Type = JSONTokenValue
+		token.Value = p.readNumber()
+	}
+	return token, p.Error
+}
+
+// Read the next complete value (map, list, or scalar).
+func (p *JSONStreamParser) ReadValue() (JSEntity, error) {
+	token, err := p.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	return p.readValueFrom(token)
+}
+
+func (p *JSONStreamParser) readValueFrom(token JSONToken) (JSEntity, error) {
+	switch token.Type {
+	case JSONTokenValue:
+		return token.Value, nil
+	case JSONTokenBeginMap:
+		m := NewJSMap()
+		for {
+			t, err := p.NextToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == JSONTokenEndMap {
+				return m, nil
+			}
+			value, err := p.ReadValue()
+			if err != nil {
+				return nil, err
+			}
+			m.wrappedMap[t.Key] = value
+		}
+	case JSONTokenBeginList:
+		list := NewJSList()
+		for {
+			t, err := p.NextToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == JSONTokenEndList {
+				return list, nil
+			}
+			value, err := p.readValueFrom(t)
+			if err != nil {
+				return nil, err
+			}
+			list.wrappedList = append(list.wrappedList, value)
+		}
+	}
+	p.fail("unexpected token:", token.Type)
+	return nil, p.Error
+}
+
+// Read the next element of a top-level list.  The first call reads the list's opening bracket.
+// Returns io.EOF once the closing bracket (and the end of the input) has been reached.
+func (p *JSONStreamParser) NextElement() (JSEntity, error) {
+	if p.hasProblem() {
+		return nil, p.Error
+	}
+	if p.listState == streamListState_new {
+		CheckState(!p.started, "NextElement called after other tokens were read")
+		token, err := p.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		if token.Type != JSONTokenBeginList {
+			p.fail("expected '['")
+			return nil, p.Error
+		}
+		p.listState = streamListState_open
+	}
+	if p.listState == streamListState_closed {
+		return nil, io.EOF
+	}
+	token, err := p.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	if token.Type == JSONTokenEndList {
+		p.listState = streamListState_closed
+		// Verify there is nothing following the list
+		if _, err = p.NextToken(); err != io.EOF {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return p.readValueFrom(token)
+}
+
+// Number of bytes consumed so far
+func (p *JSONStreamParser) Cursor() int {
+	return p.cursor
+}
+
+// ---------------------------------------------------------------------------------------
+// Low-level reading
+// ---------------------------------------------------------------------------------------
+
+func (p *JSONStreamParser) hasProblem() bool {
+	return p.Error != nil
+}
+
+func (p *JSONStreamParser) fail(message ...any) {
+	if p.Error != nil {
+		return
+	}
+	before := p.history[bytes.LastIndexByte(p.history, '\n')+1:]
+	after, _ := p.reader.Peek(jsonErrorExcerptLength)
+	if i := bytes.IndexByte(after, '\n'); i >= 0 {
+		after = after[:i]
+	}
+	loc := jsonErrorLocation{
+		cursor: p.cursor,
+		line:   p.line + 1,
+		column: p.column + 1,
+		path:   p.containerPath(),
+	}
+	p.Error = newJsonParseError(loc, before, after, message...)
+}
+
+// Construct a JSON pointer to the innermost container being parsed
+func (p *JSONStreamParser) containerPath() string {
+	var segments []string
+	for _, f := range p.stack[:MaxInt(len(p.stack)-1, 0)] {
+		if f.isMap {
+			segments = append(segments, f.key)
+		} else {
+			segments = append(segments, IntToString(f.count-1))
+		}
+	}
+	return JsonPointer(segments...)
+}
+
+func (p *JSONStreamParser) peek() byte {
+	if p.hasProblem() {
+		return 0
+	}
+	b, err := p.reader.Peek(1)
+	if err != nil {
+		if err == io.EOF {
+			p.fail("reached end of input")
+		} else {
+			p.fail("read error:", err)
+		}
+		return 0
+	}
+	return b[0]
+}
+
+func (p *JSONStreamParser) read() byte {
+	var result = p.peek()
+	if !p.hasProblem() {
+		p.reader.ReadByte()
+		// Keep the most recently read bytes, for error reporting
+		if len(p.history) == jsonErrorExcerptLength {
+			copy(p.history, p.history[1:])
+			p.history = p.history[:jsonErrorExcerptLength-1]
+		}
+		p.history = append(p.history, result)
+		if result == '\n' {
+			p.line++
+			p.column = 0
+		} else if result&0xc0 != 0x80 {
+			// Don't count UTF-8 continuation bytes
+			p.column++
+		}
+	}
+	p.cursor++
+	return result
+}
+
+func (p *JSONStreamParser) skipWhitespace() {
+	for !p.hasProblem() {
+		b, err := p.reader.Peek(1)
+		if err != nil {
+			return
+		}
+		c := b[0]
+		if c == '/' {
+			p.read()
+			if p.peek() != '/' {
+				p.fail("problem skipping whitespace, expected '/'")
+				return
+			}
+			for {
+				b, err = p.reader.Peek(1)
+				if err != nil || b[0] == '\n' {
+					break
+				}
+				p.read()
+			}
+			continue
+		}
+		if c > ' ' {
+			return
+		}
+		p.read()
+	}
+}
+
+// If next character (after any whitespace) matches a value, read it and return true
+func (p *JSONStreamParser) readIf(expected byte) bool {
+	p.skipWhitespace()
+	if b, err := p.reader.Peek(1); err == nil && b[0] == expected {
+		p.read()
+		return true
+	}
+	return false
+}
+
+func (p *JSONStreamParser) readExpectedByte(expected byte) {
+	p.skipWhitespace()
+	if p.peek() != expected {
+		p.fail("expected '" + string(expected) + "'")
+		return
+	}
+	p.read()
+}
+
+func (p *JSONStreamParser) readExpectedBytes(kword JSKeyword) {
+	b, _ := p.reader.Peek(len(kword.bytes))
+	if len(b) < len(kword.bytes) {
+		p.fail("end of data reading expected bytes")
+		return
+	}
+	if !bytes.Equal(b, kword.bytes) {
+		p.fail("expected '" + kword.text + "'")
+		return
+	}
+	for range kword.bytes {
+		p.read()
+	}
+}
+
+func (p *JSONStreamParser) readNumber() JSEntity {
+	var expr []byte
+	var isFloat = false
+	for {
+		b, err := p.reader.Peek(1)
+		if err != nil || jsonNumberTerminator(b[0]) {
+			break
+		}
+		c := p.read()
+		if c == 'e' || c == 'E' || c == '.' {
+			isFloat = true
+		}
+		expr = append(expr, c)
+	}
+	// Skip whitespace before reporting any problem, so the cursor agrees with JSONParser's
+	p.skipWhitespace()
+	var value = parseJsonNumber(string(expr), isFloat, p.lossless)
+	if value == nil {
+		p.fail("problem parsing number", string(expr))
+		value = JInteger(0)
+	}
+	return value
+}
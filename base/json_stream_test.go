@@ -0,0 +1,88 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamMatchesParser(t *testing.T) {
+	j := jt.New(t)
+	samples := []string{
+		text1,
+		`{"a":null,"b":true,"c":false,"d":-12,"e":3.5e2,"f":[[],{},[1,2,],"x"]}`,
+		`{"":"^(\\w|-|\\.|\\x20|'|\\(|\\)|,)+$"}`,
+		`{"u":"Ñio 😀 ’"}`,
+	}
+	for _, s := range samples {
+		expected := JSMapFromStringM(s)
+		p := NewJSONStreamParser(strings.NewReader(s))
+		value, err := p.ReadValue()
+		CheckOk(err)
+		j.AssertEqual(expected.CompactString(), value.AsJSMap().CompactString())
+		_, err = p.NextToken()
+		j.AssertEqual(io.EOF, err)
+	}
+}
+
+func TestStreamTokens(t *testing.T) {
+	j := jt.New(t)
+	p := NewJSONStreamParser(strings.NewReader(`{"a":[1,{"b":"c"}],"d":null}`))
+	sb := strings.Builder{}
+	for {
+		token, err := p.NextToken()
+		if err == io.EOF {
+			break
+		}
+		CheckOk(err)
+		value := ""
+		if token.Value != nil {
+			value = PrintJSEntity(token.Value, false)
+		}
+		sb.WriteString(ToString(token.Cursor, token.Type, token.Key, value) + "\n")
+	}
+	j.AssertMessage(sb.String())
+}
+
+func TestStreamNextElement(t *testing.T) {
+	j := jt.New(t)
+	p := NewJSONStreamParser(strings.NewReader(` [ {"a":1}, "two", [3] , ] `))
+	results := NewJSList()
+	for {
+		elem, err := p.NextElement()
+		if err == io.EOF {
+			break
+		}
+		CheckOk(err)
+		results.Add(elem)
+	}
+	j.AssertMessage(results)
+}
+
+func TestStreamBadInput(t *testing.T) {
+	j := jt.New(t)
+	var badtext = `{"nm":"J","ag":30, "hs": ["sw","co"], "si":"al be ch" }`
+
+	for i := 0; i < 100; i++ {
+		s := corrupt(j, badtext)
+		// JSONParser.ParseMap expects a map, whereas the stream parser accepts any value
+		if s[0] != '{' {
+			continue
+		}
+		var p JSONParser
+		p.WithText(s)
+		p.ParseMap()
+
+		sp := NewJSONStreamParser(strings.NewReader(s))
+		_, err := sp.ReadValue()
+
+		if p.Error == nil {
+			j.AssertEqual(nil, err)
+			continue
+		}
+		j.AssertTrue(err != nil, "stream parser accepted:", s)
+		j.AssertEqual(p.Error.(*JsonParseError).Cursor, err.(*JsonParseError).Cursor)
+	}
+}
@@ -0,0 +1,202 @@
+package base
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Schema versioning for DataClass json.
+//
+// When a data class changes (fields added, renamed, or reinterpreted), register a Migration that converts
+// json written by the previous version to the new version.  A class's current version is the number of
+// migrations registered for it; json written with ToVersionedJson (which Marshal, and App's --gen-args, use)
+// records this version under DataVersionKey.  Parsing with ParseVersioned (which ParseOrDefault, Unmarshal,
+// and App's arguments use) applies whatever migrations are needed before calling the DataClass's Parse method.
+//
+// Json without a version is version zero: it was written before the class's first migration was registered.
+// So once a class has migrations, json for it that is to be read back must be written with ToVersionedJson
+// rather than the class's ToJson method.
+
+// The key holding the schema version within a DataClass's json
+const DataVersionKey = "_version"
+
+// Converts a DataClass's json from one version to the next; it may modify the map and return it
+type Migration func(m JSMap) (JSMap, error)
+
+type MigrationRegistryStruct struct {
+	lock       sync.RWMutex
+	migrations map[reflect.Type][]Migration
+}
+
+type MigrationRegistry = *MigrationRegistryStruct
+
+var sharedMigrationRegistry = NewMigrationRegistry()
+
+// Get the registry used by ParseOrDefault, the json marshaller and unmarshaller, and App's arguments
+func SharedMigrationRegistry() MigrationRegistry {
+	return sharedMigrationRegistry
+}
+
+// Replace the shared registry (e.g. with an empty one, for a test), returning the previous one so it can be restored
+func SetSharedMigrationRegistry(r MigrationRegistry) MigrationRegistry {
+	CheckArg(r != nil)
+	previous := sharedMigrationRegistry
+	sharedMigrationRegistry = r
+	return previous
+}
+
+func NewMigrationRegistry() MigrationRegistry {
+	return &MigrationRegistryStruct{migrations: make(map[reflect.Type][]Migration)}
+}
+
+// Register a migration from fromVersion to fromVersion+1 for a DataClass (e.g. DefaultAnimal);
+// migrations must be registered in order, starting with version zero
+func (r MigrationRegistry) Register(class DataClass, fromVersion int, migration Migration) MigrationRegistry {
+	key := dataClassKey(class)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	steps := r.migrations[key]
+	CheckArg(fromVersion == len(steps), "expected migration from version", len(steps), "for", key, "but got", fromVersion)
+	r.migrations[key] = append(steps, migration)
+	return r
+}
+
+// Get the current schema version of a DataClass
+func (r MigrationRegistry) CurrentVersion(class DataClass) int {
+	return len(r.steps(class))
+}
+
+func (r MigrationRegistry) steps(class DataClass) []Migration {
+	key := dataClassKey(class)
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.migrations[key]
+}
+
+// Determine the version of a DataClass's json
+func DataVersion(m JSMap) int {
+	return m.OptInt(DataVersionKey, 0)
+}
+
+// Convert a DataClass to json, recording its current schema version
+func (r MigrationRegistry) ToVersionedJson(d DataClass) JSEntity {
+	result := d.ToJson()
+	if m, ok := result.(JSMap); ok {
+		if version := r.CurrentVersion(d); version > 0 {
+			m.Put(DataVersionKey, version)
+		}
+	}
+	return result
+}
+
+// Bring a DataClass's json up to the current version.  The result doesn't include the version key,
+// and the source map is not modified.  Json that isn't a map is returned unchanged.
+func (r MigrationRegistry) Migrate(class DataClass, source JSEntity) (JSEntity, error) {
+	m, ok := source.(JSMap)
+	if !ok {
+		return source, nil
+	}
+	steps := r.steps(class)
+	version := DataVersion(m)
+	if version < 0 || version > len(steps) {
+		return nil, Error("unsupported version", version, "for", dataClassKey(class), "; current version is", len(steps))
+	}
+	m = DeepCopyJSEntity(m).(JSMap).Delete(DataVersionKey)
+	for ; version < len(steps); version++ {
+		var err error
+		m, err = steps[version](m)
+		if err != nil {
+			return nil, Error("problem migrating", dataClassKey(class), "from version", version, INDENT, err)
+		}
+		m.Delete(DataVersionKey)
+	}
+	return m, nil
+}
+
+func (r MigrationRegistry) MigrateM(class DataClass, source JSEntity) JSEntity {
+	return CheckOkWith(r.Migrate(class, source))
+}
+
+// Parse a DataClass from json, applying any migrations it needs first
+func (r MigrationRegistry) ParseVersioned(parser DataClass, source JSEntity) (DataClass, error) {
+	migrated, err := r.Migrate(parser, source)
+	if err != nil {
+		return nil, err
+	}
+	var result DataClass
+	err = catchPanicAsError(func() { result = parser.Parse(migrated) })
+	return result, err
+}
+
+func (r MigrationRegistry) ParseVersionedM(parser DataClass, source JSEntity) DataClass {
+	return CheckOkWith(r.ParseVersioned(parser, source))
+}
+
+// Upgrade the files within a directory (and its subdirectories) whose names match a glob (e.g. "*.user.json");
+// see UpgradeFiles.  The glob identifies the files holding the DataClass; it must be specific enough not to
+// match other json files, since DataClasses parse leniently, so other json would be 'upgraded' too.
+func (r MigrationRegistry) UpgradeDirectory(parser DataClass, dir Path, glob string, dryRun bool) ([]Path, error) {
+	if !dir.IsDir() {
+		return nil, Error("no such directory:", dir)
+	}
+	var files []Path
+	if err := catchPanicAsError(func() {
+		files = NewDirWalk(dir).WithRecurse().ForFiles().IncludeGlobs(glob).Files()
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].String() < files[j].String() })
+	return r.UpgradeFiles(parser, files, dryRun)
+}
+
+// Upgrade json files holding versions of a DataClass older than its current one, rewriting them in place
+// (atomically); returns the files that were (or, if dryRun is true, would have been) upgraded.  Each
+// upgraded file is verified by parsing it before it is written, and records the version it was upgraded to.
+func (r MigrationRegistry) UpgradeFiles(parser DataClass, files []Path, dryRun bool) ([]Path, error) {
+	current := r.CurrentVersion(parser)
+	var upgraded []Path
+	for _, file := range files {
+		m, err := JSMapFromFile(file)
+		if err == nil && DataVersion(m) < current {
+			var migrated JSEntity
+			migrated, err = r.Migrate(parser, m)
+			if err == nil {
+				err = catchPanicAsError(func() { parser.Parse(migrated) })
+			}
+			if err == nil {
+				upgraded = append(upgraded, file)
+				if !dryRun {
+					migrated.AsJSMap().Put(DataVersionKey, current)
+					err = file.WriteStringAtomic(migrated.AsJSMap().String())
+				}
+			}
+		}
+		if err != nil {
+			return upgraded, Error("problem upgrading:", file, INDENT, err)
+		}
+	}
+	return upgraded, nil
+}
+
+var dataClassKeys sync.Map
+
+// Determine the key identifying a DataClass within the registry.  Generated builders and their built
+// versions have different types, so if the DataClass has a Build method, use the type of what it returns.
+func dataClassKey(class DataClass) reflect.Type {
+	t := reflect.TypeOf(class)
+	if k, ok := dataClassKeys.Load(t); ok {
+		return k.(reflect.Type)
+	}
+	key := t
+	if method := reflect.ValueOf(class).MethodByName("Build"); method.IsValid() && method.Type().NumIn() == 0 &&
+		method.Type().NumOut() == 1 {
+		_ = catchPanicAsError(func() {
+			if built := method.Call(nil)[0]; built.Kind() != reflect.Interface || !built.IsNil() {
+				key = reflect.TypeOf(built.Interface())
+			}
+		})
+	}
+	dataClassKeys.Store(t, key)
+	return key
+}
@@ -0,0 +1,148 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	. "github.com/jpsember/golang-base/gen/sample"
+	"github.com/jpsember/golang-base/jt"
+	"testing"
+)
+
+// Version 0 called the name field "title"; version 1 stored the target in tens
+func demoMigrations() MigrationRegistry {
+	return NewMigrationRegistry().
+		Register(DefaultDemoConfig, 0, func(m JSMap) (JSMap, error) {
+			if title := m.OptAny("title"); title != nil {
+				m.Put("name", title).Delete("title")
+			}
+			return m, nil
+		}).
+		// Builders are equivalent to their built versions
+		Register(NewDemoConfig(), 1, func(m JSMap) (JSMap, error) {
+			if !m.HasKey("target") {
+				return m, nil
+			}
+			target, err := m.GetInt("target"), error(nil)
+			if target < 0 {
+				err = Error("negative target:", target)
+			}
+			return m.Put("target", target*10), err
+		})
+}
+
+func TestMigrationVersions(t *testing.T) {
+	j := jt.New(t)
+	r := demoMigrations()
+	j.AssertEqual(r.CurrentVersion(DefaultDemoConfig), 2)
+	j.AssertEqual(r.CurrentVersion(NewDemoConfig().SetName("x")), 2)
+	j.AssertEqual(NewMigrationRegistry().CurrentVersion(DefaultDemoConfig), 0)
+
+	js := r.ToVersionedJson(NewDemoConfig().SetTarget(7).Build()).AsJSMap()
+	j.AssertEqual(DataVersion(js), 2)
+	// Versioned json is still parseable without the registry
+	j.AssertEqual(DefaultDemoConfig.Parse(js).(DemoConfig).Target(), 7)
+}
+
+func TestMigrationParse(t *testing.T) {
+	j := jt.New(t)
+	r := demoMigrations()
+
+	source := JSMapFromStringM(`{"title":"old","target":5}`)
+	d := r.ParseVersionedM(DefaultDemoConfig, source).(DemoConfig)
+	j.AssertEqual(d.Name(), "old")
+	j.AssertEqual(d.Target(), 50)
+	// The source is left unchanged
+	j.AssertEqual(source.CompactString(), `{"target":5,"title":"old"}`)
+
+	d = r.ParseVersionedM(DefaultDemoConfig, JSMapFromStringM(`{"_version":1,"name":"mid","target":5}`)).(DemoConfig)
+	j.AssertEqual(d.Name(), "mid")
+	j.AssertEqual(d.Target(), 50)
+
+	d = r.ParseVersionedM(DefaultDemoConfig, JSMapFromStringM(`{"_version":2,"name":"new","target":5}`)).(DemoConfig)
+	j.AssertEqual(d.Target(), 5)
+
+	j.AssertMessage(r.MigrateM(DefaultDemoConfig, source))
+}
+
+func TestMigrationErrors(t *testing.T) {
+	j := jt.New(t)
+	r := demoMigrations()
+	_, err := r.ParseVersioned(DefaultDemoConfig, JSMapFromStringM(`{"_version":3}`))
+	j.AssertTrue(err != nil)
+	_, err = r.ParseVersioned(DefaultDemoConfig, JSMapFromStringM(`{"target":-1}`))
+	j.AssertTrue(err != nil)
+	_, err = r.ParseVersioned(DefaultDemoConfig, JSMapFromStringM(`{"_version":2,"target":"seven"}`))
+	j.AssertTrue(err != nil)
+}
+
+func TestMigrationUpgradeDirectory(t *testing.T) {
+	j := jt.New(t)
+	r := demoMigrations()
+	dir := j.GetTestResultsDir()
+	dir.JoinM("a.demo.json").WriteStringM(`{"title":"alpha","target":1}`)
+	dir.JoinM("b.demo.json").WriteStringM(`{"_version":2,"name":"beta","target":2}`)
+	dir.JoinM("sub").MkDirsM()
+	dir.JoinM("sub/c.demo.json").WriteStringM(`{"_version":1,"name":"gamma","target":3}`)
+	dir.JoinM("notes.txt").WriteStringM(`not json`)
+	// Json for some other class, which DemoConfig would parse
+	other := `{"title":"other","target":4}`
+	dir.JoinM("other.json").WriteStringM(other)
+
+	files, err := r.UpgradeDirectory(DefaultDemoConfig, dir, "*.demo.json", true)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(len(files), 2)
+	j.AssertEqual(JSMapFromFileM(dir.JoinM("a.demo.json")).GetString("title"), "alpha")
+
+	files, err = r.UpgradeDirectory(DefaultDemoConfig, dir, "*.demo.json", false)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(len(files), 2)
+	upgraded := JSMapFromFileM(dir.JoinM("a.demo.json"))
+	j.AssertEqual(DataVersion(upgraded), 2)
+	j.AssertEqual(upgraded.GetString("name"), "alpha")
+	j.AssertEqual(upgraded.GetInt("target"), 10)
+	j.AssertEqual(dir.JoinM("other.json").ReadStringM(), other)
+
+	// Upgrading again does nothing, nor does upgrading a file written at the current version
+	dir.JoinM("d.demo.json").WriteStringM(r.ToVersionedJson(NewDemoConfig().SetTarget(5)).AsJSMap().String())
+	files, err = r.UpgradeDirectory(DefaultDemoConfig, dir, "*.demo.json", false)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(len(files), 0)
+	j.AssertEqual(r.ParseVersionedM(DefaultDemoConfig, JSMapFromFileM(dir.JoinM("d.demo.json"))).(DemoConfig).Target(), 5)
+
+	dir.JoinM("bad.demo.json").WriteStringM(`{"target":-4}`)
+	_, err = r.UpgradeDirectory(DefaultDemoConfig, dir, "*.demo.json", false)
+	j.AssertTrue(err != nil)
+	j.AssertEqual(JSMapFromFileM(dir.JoinM("bad.demo.json")).GetInt("target"), -4)
+}
+
+func TestMigrationSharedRegistry(t *testing.T) {
+	j := jt.New(t)
+	// Use a fresh registry, so this test can be repeated, and doesn't affect others
+	previous := SetSharedMigrationRegistry(demoMigrations())
+	defer SetSharedMigrationRegistry(previous)
+
+	// Json with a version is migrated
+	d, err := ParseOrDefault(JSMapFromStringM(`{"_version":1,"name":"x","target":3}`), DefaultDemoConfig)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(d.(DemoConfig).Target(), 30)
+
+	// As is json without one, which is version zero
+	d, err = ParseOrDefault(JSMapFromStringM(`{"title":"x","target":3}`), DefaultDemoConfig)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(d.(DemoConfig).Name(), "x")
+	j.AssertEqual(d.(DemoConfig).Target(), 30)
+
+	type holder struct {
+		Config DemoConfig
+	}
+	h := holder{Config: DefaultDemoConfig}
+	j.AssertEqual(Unmarshal(JSMapFromStringM(`{"Config":{"_version":0,"title":"y","target":3}}`), &h), nil)
+	j.AssertEqual(h.Config.Name(), "y")
+	j.AssertEqual(h.Config.Target(), 30)
+
+	// Marshalling records the version, so a round trip doesn't migrate the data again
+	js := MarshalM(h)
+	j.AssertEqual(js.AsJSMap().GetMap("Config").GetInt(DataVersionKey), 2)
+	h2 := holder{Config: DefaultDemoConfig}
+	j.AssertEqual(Unmarshal(js, &h2), nil)
+	j.AssertEqual(h2.Config.Target(), 30)
+}
@@ -0,0 +1,253 @@
+package base
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Determines when a background task is to run
+type TaskSchedule interface {
+	// Get the first time, strictly after a particular time, that the task is to run
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// ---------------------------------------------------------------------------------------
+// Fixed periods
+// ---------------------------------------------------------------------------------------
+
+type periodSchedule struct {
+	period time.Duration
+}
+
+// A schedule that runs a task repeatedly, with a fixed period between runs
+func EveryPeriod(period time.Duration) TaskSchedule {
+	CheckArg(period > 0, "period must be positive:", period)
+	return periodSchedule{period: period}
+}
+
+func (s periodSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.period)
+}
+
+func (s periodSchedule) String() string {
+	return "every " + s.period.String()
+}
+
+// ---------------------------------------------------------------------------------------
+// Times of day
+// ---------------------------------------------------------------------------------------
+
+type timeOfDaySchedule struct {
+	// Minutes since midnight, sorted
+	minutes []int
+	text    string
+}
+
+// A schedule that runs a task daily at particular (local) times, each expressed as "HH:MM" (24 hour)
+func DailyAt(times ...string) (TaskSchedule, error) {
+	if len(times) == 0 {
+		return nil, Error("no times given")
+	}
+	var minutes []int
+	for _, t := range times {
+		h, m, found := strings.Cut(strings.TrimSpace(t), ":")
+		hour, err1 := strconv.Atoi(h)
+		minute, err2 := strconv.Atoi(m)
+		if !found || err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+			return nil, Error("bad time of day:", Quoted(t))
+		}
+		minutes = append(minutes, hour*60+minute)
+	}
+	sort.Ints(minutes)
+	return timeOfDaySchedule{minutes: minutes, text: "daily at " + strings.Join(times, ", ")}, nil
+}
+
+func DailyAtM(times ...string) TaskSchedule {
+	return CheckOkWith(DailyAt(times...))
+}
+
+func (s timeOfDaySchedule) Next(after time.Time) time.Time {
+	y, mo, d := after.Date()
+	for day := 0; ; day++ {
+		for _, m := range s.minutes {
+			t := time.Date(y, mo, d+day, m/60, m%60, 0, 0, after.Location())
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+}
+
+func (s timeOfDaySchedule) String() string {
+	return s.text
+}
+
+// ---------------------------------------------------------------------------------------
+// Cron expressions
+// ---------------------------------------------------------------------------------------
+
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// True if the day of month (or week) field is restricted, i.e. doesn't start with '*'
+	domRestricted, dowRestricted bool
+	text                         string
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Parse a cron expression, in local time.  It has five fields: minute (0-59), hour (0-23), day of month (1-31),
+// month (1-12 or JAN-DEC), and day of week (0-7 or SUN-SAT; 0 and 7 are both Sunday).  Each field is '*', or
+// a comma-separated list of values or ranges (e.g. "1-5"), each optionally with a step (e.g. "*/15" or "0-30/10").
+// As with standard cron, if both the day of month and day of week are restricted, a day matching either will do.
+// The macros @yearly, @monthly, @weekly, @daily, and @hourly are also supported.
+func ParseCron(expr string) (TaskSchedule, error) {
+	text := strings.TrimSpace(expr)
+	if macro, found := cronMacros[strings.ToLower(text)]; found {
+		text = macro
+	}
+	fields := strings.Fields(text)
+	if len(fields) != 5 {
+		return nil, Error("cron expression must have five fields:", Quoted(expr))
+	}
+	var s cronSchedule
+	var err error
+	parse := func(field string, min, max int, names []string, nameBase int) uint64 {
+		var bits uint64
+		if err == nil {
+			bits, err = parseCronField(field, min, max, names, nameBase)
+			if err != nil {
+				err = Error("problem with cron expression", Quoted(expr), INDENT, err)
+			}
+		}
+		return bits
+	}
+	s.minute = parse(fields[0], 0, 59, nil, 0)
+	s.hour = parse(fields[1], 0, 23, nil, 0)
+	s.dayOfMonth = parse(fields[2], 1, 31, nil, 0)
+	s.month = parse(fields[3], 1, 12, cronMonthNames, 1)
+	s.dayOfWeek = parse(fields[4], 0, 7, cronDayNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Sunday can be either 0 or 7
+	if s.dayOfWeek&(1<<7) != 0 {
+		s.dayOfWeek |= 1
+	}
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	s.text = expr
+	if _, ok := s.next(time.Now()); !ok {
+		return nil, Error("cron expression never matches:", Quoted(expr))
+	}
+	return s, nil
+}
+
+func ParseCronM(expr string) TaskSchedule {
+	return CheckOkWith(ParseCron(expr))
+}
+
+func parseCronField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return 0, Error("bad step:", Quoted(part))
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseCronValue(a, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseCronValue(b, min, max, names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// e.g. "5/15" means from 5 to the maximum, in steps of 15
+				hi = max
+			}
+			if lo > hi {
+				return 0, Error("bad range:", Quoted(rng))
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(text string, min, max int, names []string, nameBase int) (int, error) {
+	for i, n := range names {
+		if strings.EqualFold(text, n) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < min || v > max {
+		return 0, Error("bad value:", Quoted(text), "; expected", min, "...", max)
+	}
+	return v, nil
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dayOfMonth&(1<<t.Day()) != 0
+	dow := s.dayOfWeek&(1<<int(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (s cronSchedule) Next(after time.Time) time.Time {
+	t, ok := s.next(after)
+	CheckState(ok, "cron expression never matches:", Quoted(s.text))
+	return t
+}
+
+func (s cronSchedule) next(after time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Give up after a few years (e.g. "0 0 31 2 *" never matches)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return t, false
+}
+
+func (s cronSchedule) String() string {
+	return s.text
+}
@@ -0,0 +1,707 @@
+package base
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Support for reading and writing TOML documents as JSMaps.
+//
+// The reader handles TOML 1.0: key/value pairs (with bare, quoted and dotted keys), tables, arrays
+// of tables, inline tables, arrays, and all forms of strings, integers, floats, and booleans.
+// Dates and times are stored as strings.
+//
+// Since JSMaps don't retain the order of their keys, the writer emits them in the order given by
+// OrderedKeys (except that, as TOML requires, each table's plain values precede its subtables).
+
+type TomlError struct {
+	Line    int
+	Problem string
+}
+
+func (e *TomlError) Error() string {
+	return "Problem parsing toml at line " + IntToString(e.Line) + ": " + e.Problem
+}
+
+func JSMapFromToml(text string) (result JSMap, err error) {
+	p := &tomlParser{text: strings.ReplaceAll(text, "\r\n", "\n"), line: 1, root: NewJSMap()}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*TomlError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e
+		}
+	}()
+	p.parse()
+	return p.root, nil
+}
+
+func JSMapFromTomlM(text string) JSMap {
+	return CheckOkWith(JSMapFromToml(text))
+}
+
+// ---------------------------------------------------------------------------------------
+// Reading
+// ---------------------------------------------------------------------------------------
+
+type tomlParser struct {
+	text   string
+	cursor int
+	line   int
+	root   JSMap
+	// The table that key/value pairs are currently being added to
+	current JSMap
+	// Tables that have been defined explicitly (by [header]) or implicitly (by a key/value pair),
+	// and which therefore can't be defined again
+	defined map[JSMap]bool
+	// Maps that are inline tables, or were created by dotted keys within inline tables; these can't be extended
+	sealed map[JSMap]bool
+	// Lists that were created by arrays of tables, as opposed to array values
+	tableArrays map[JSList]bool
+}
+
+func (p *tomlParser) fail(problem ...any) {
+	panic(&TomlError{Line: p.line, Problem: ToString(problem...)})
+}
+
+func (p *tomlParser) atEnd() bool {
+	return p.cursor >= len(p.text)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.text[p.cursor]
+}
+
+func (p *tomlParser) read() byte {
+	c := p.peek()
+	if c == '\n' {
+		p.line++
+	}
+	p.cursor++
+	return c
+}
+
+func (p *tomlParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("expected", Quoted(string(c)))
+	}
+	p.read()
+}
+
+// Skip spaces and tabs
+func (p *tomlParser) skipSpaces() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.read()
+	}
+}
+
+// Skip whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpaces()
+		switch p.peek() {
+		case '\n':
+			p.read()
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	for !p.atEnd() && p.peek() != '\n' {
+		p.read()
+	}
+}
+
+// Verify that the rest of the line contains only whitespace or a comment
+func (p *tomlParser) expectEndOfLine() {
+	p.skipSpaces()
+	if p.peek() == '#' {
+		p.skipComment()
+	}
+	if !p.atEnd() && p.read() != '\n' {
+		p.fail("expected end of line")
+	}
+}
+
+func (p *tomlParser) parse() {
+	p.current = p.root
+	p.defined = map[JSMap]bool{p.root: true}
+	p.sealed = make(map[JSMap]bool)
+	p.tableArrays = make(map[JSList]bool)
+	for {
+		p.skipBlank()
+		if p.atEnd() {
+			return
+		}
+		if p.peek() == '[' {
+			p.parseHeader()
+		} else {
+			p.parseKeyValue(p.current)
+		}
+		p.expectEndOfLine()
+	}
+}
+
+// Parse a [table] or [[array of tables]] header
+func (p *tomlParser) parseHeader() {
+	p.read()
+	isArray := p.peek() == '['
+	if isArray {
+		p.read()
+	}
+	p.skipSpaces()
+	keys := p.parseKey()
+	p.skipSpaces()
+	p.expect(']')
+	if isArray {
+		p.expect(']')
+	}
+
+	// Find (or create) the parent table
+	parent := p.root
+	for _, k := range keys[:len(keys)-1] {
+		parent = p.descend(parent, k)
+	}
+	last := keys[len(keys)-1]
+	existing := parent.wrappedMap[last]
+	if isArray {
+		list, ok := existing.(JSList)
+		if existing == nil {
+			list = NewJSList()
+			p.tableArrays[list] = true
+			parent.wrappedMap[last] = list
+		} else if !ok || !p.tableArrays[list] {
+			p.fail("can't define array of tables:", Quoted(strings.Join(keys, ".")))
+		}
+		table := NewJSMap()
+		list.wrappedList = append(list.wrappedList, table)
+		p.defined[table] = true
+		p.current = table
+		return
+	}
+	var table JSMap
+	if existing == nil {
+		table = NewJSMap()
+		parent.wrappedMap[last] = table
+	} else {
+		m, ok := existing.(JSMap)
+		if !ok || p.defined[m] || p.sealed[m] {
+			p.fail("can't define table:", Quoted(strings.Join(keys, ".")))
+		}
+		table = m
+	}
+	p.defined[table] = true
+	p.current = table
+}
+
+// Get the table addressed by a key (within a header), creating it if necessary; if the key refers to an
+// array of tables, use its last element
+func (p *tomlParser) descend(parent JSMap, key string) JSMap {
+	switch x := parent.wrappedMap[key].(type) {
+	case nil:
+		m := NewJSMap()
+		parent.wrappedMap[key] = m
+		return m
+	case JSMap:
+		if p.sealed[x] {
+			p.fail("can't extend inline table:", Quoted(key))
+		}
+		return x
+	case JSList:
+		if p.tableArrays[x] {
+			return x.wrappedList[len(x.wrappedList)-1].(JSMap)
+		}
+	}
+	p.fail("key doesn't refer to a table:", Quoted(key))
+	return nil
+}
+
+// Parse a key/value pair and add it to a table
+func (p *tomlParser) parseKeyValue(table JSMap) {
+	keys := p.parseKey()
+	p.skipSpaces()
+	p.expect('=')
+	p.skipSpaces()
+	value := p.parseValue()
+
+	// Dotted keys create (or extend) tables
+	for _, k := range keys[:len(keys)-1] {
+		switch x := table.wrappedMap[k].(type) {
+		case nil:
+			m := NewJSMap()
+			if p.sealed[table] {
+				p.sealed[m] = true
+			}
+			table.wrappedMap[k] = m
+			table = m
+		case JSMap:
+			if p.defined[x] || (p.sealed[x] && !p.sealed[table]) {
+				p.fail("can't extend table:", Quoted(k))
+			}
+			table = x
+		default:
+			p.fail("key doesn't refer to a table:", Quoted(k))
+		}
+	}
+	last := keys[len(keys)-1]
+	if table.HasKey(last) {
+		p.fail("duplicate key:", Quoted(strings.Join(keys, ".")))
+	}
+	table.wrappedMap[last] = value
+}
+
+// Parse a (possibly dotted) key
+func (p *tomlParser) parseKey() []string {
+	var keys []string
+	for {
+		p.skipSpaces()
+		var key string
+		switch p.peek() {
+		case '"':
+			key = p.parseBasicString()
+		case '\'':
+			key = p.parseLiteralString()
+		default:
+			start := p.cursor
+			for c := p.peek(); isTomlBareKeyByte(c); c = p.peek() {
+				p.read()
+			}
+			if start == p.cursor {
+				p.fail("expected a key")
+			}
+			key = p.text[start:p.cursor]
+		}
+		keys = append(keys, key)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys
+		}
+		p.read()
+	}
+}
+
+func isTomlBareKeyByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() JSEntity {
+	switch c := p.peek(); c {
+	case '"':
+		if strings.HasPrefix(p.text[p.cursor:], `"""`) {
+			return JString(p.parseMultiLineString('"'))
+		}
+		return JString(p.parseBasicString())
+	case '\'':
+		if strings.HasPrefix(p.text[p.cursor:], `'''`) {
+			return JString(p.parseMultiLineString('\''))
+		}
+		return JString(p.parseLiteralString())
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+	return p.parseScalar()
+}
+
+func (p *tomlParser) parseBasicString() string {
+	p.read()
+	sb := strings.Builder{}
+	for {
+		c := p.read()
+		switch c {
+		case '"':
+			return sb.String()
+		case '\\':
+			p.parseEscape(&sb)
+		case 0, '\n':
+			p.fail("unterminated string")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() string {
+	p.read()
+	start := p.cursor
+	for {
+		switch p.read() {
+		case '\'':
+			return p.text[start : p.cursor-1]
+		case 0, '\n':
+			p.fail("unterminated string")
+		}
+	}
+}
+
+// Parse a string delimited by """ or ”'
+func (p *tomlParser) parseMultiLineString(quote byte) string {
+	delimiter := strings.Repeat(string(quote), 3)
+	p.cursor += 3
+	// A newline immediately following the opening delimiter is trimmed
+	if p.peek() == '\n' {
+		p.read()
+	}
+	sb := strings.Builder{}
+	for {
+		if strings.HasPrefix(p.text[p.cursor:], delimiter) {
+			p.cursor += 3
+			// Up to two additional quotes can precede the closing delimiter
+			for i := 0; i < 2 && p.peek() == quote; i++ {
+				sb.WriteByte(quote)
+				p.read()
+			}
+			return sb.String()
+		}
+		c := p.read()
+		switch {
+		case c == 0 && p.cursor > len(p.text):
+			p.fail("unterminated string")
+		case c == '\\' && quote == '"':
+			// A backslash at the end of a line removes the newline and any following whitespace
+			rest := strings.TrimLeft(p.text[p.cursor:], " \t")
+			if strings.HasPrefix(rest, "\n") {
+				for c := p.peek(); c == ' ' || c == '\t' || c == '\n'; c = p.peek() {
+					p.read()
+				}
+				continue
+			}
+			p.parseEscape(&sb)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// Parse an escape sequence, following its backslash
+func (p *tomlParser) parseEscape(sb *strings.Builder) {
+	c := p.read()
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'e':
+		sb.WriteByte(0x1b)
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		digits := Ternary(c == 'u', 4, 8)
+		if p.cursor+digits > len(p.text) {
+			p.fail("incomplete escape sequence")
+		}
+		v, err := strconv.ParseUint(p.text[p.cursor:p.cursor+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			p.fail("bad unicode escape sequence")
+		}
+		p.cursor += digits
+		sb.WriteRune(rune(v))
+	default:
+		p.fail("unsupported escape sequence:", Quoted("\\"+string(c)))
+	}
+}
+
+func (p *tomlParser) parseArray() JSList {
+	p.read()
+	list := NewJSList()
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			break
+		}
+		list.wrappedList = append(list.wrappedList, p.parseValue())
+		p.skipBlank()
+		if p.peek() != ',' {
+			break
+		}
+		p.read()
+	}
+	p.expect(']')
+	return list
+}
+
+func (p *tomlParser) parseInlineTable() JSMap {
+	p.read()
+	table := NewJSMap()
+	p.sealed[table] = true
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.read()
+		return table
+	}
+	for {
+		p.skipSpaces()
+		p.parseKeyValue(table)
+		p.skipSpaces()
+		if p.peek() != ',' {
+			break
+		}
+		p.read()
+	}
+	p.expect('}')
+	return table
+}
+
+var tomlDateExpr = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+var tomlDateTimeExpr = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[-+]\d{2}:\d{2})?)?|\d{2}:\d{2}(:\d{2}(\.\d+)?)?)$`)
+var tomlDecimalExpr = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+var tomlFloatExpr = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+
+// Parse a boolean, number, date or time
+func (p *tomlParser) parseScalar() JSEntity {
+	start := p.cursor
+	for c := p.peek(); isTomlBareKeyByte(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+		p.read()
+	}
+	token := p.text[start:p.cursor]
+	// A date can be separated from its time by a space
+	if tomlDateExpr.MatchString(token) && p.peek() == ' ' && p.cursor+1 < len(p.text) && p.text[p.cursor+1] >= '0' && p.text[p.cursor+1] <= '9' {
+		p.read()
+		for c := p.peek(); isTomlBareKeyByte(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+			p.read()
+		}
+		token = p.text[start:p.cursor]
+	}
+
+	switch token {
+	case "true":
+		return JBoolTrue
+	case "false":
+		return JBoolFalse
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		// These have no JSON representation
+		p.fail("infinite and NaN values are not supported:", Quoted(token))
+	case "":
+		p.fail("expected a value")
+	}
+	if tomlDateTimeExpr.MatchString(token) {
+		return JString(token)
+	}
+	digits := strings.ReplaceAll(token, "_", "")
+	if len(token) > 2 && token[0] == '0' && strings.IndexByte("xob", token[1]) >= 0 {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[token[1]]
+		if v, err := strconv.ParseInt(digits[2:], base, 64); err == nil && !strings.Contains(token, "__") {
+			return JInteger(v)
+		}
+	} else if tomlDecimalExpr.MatchString(token) {
+		if v, err := strconv.ParseInt(digits, 10, 64); err == nil {
+			return JInteger(v)
+		}
+		p.fail("integer out of range:", token)
+	} else if tomlFloatExpr.MatchString(token) {
+		if v, err := strconv.ParseFloat(digits, 64); err == nil {
+			return JFloat(v)
+		}
+	}
+	p.fail("invalid value:", Quoted(token))
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------
+// Writing
+// ---------------------------------------------------------------------------------------
+
+// Convert a JSMap to a TOML document.  It is an error if the map contains any null values,
+// since TOML can't represent them.
+func ToToml(m JSMap) (string, error) {
+	sb := strings.Builder{}
+	err := writeTomlTable(&sb, m, nil)
+	return sb.String(), err
+}
+
+func ToTomlM(m JSMap) string {
+	return CheckOkWith(ToToml(m))
+}
+
+// Determine if a value is a list that can be written as an array of tables
+func isTomlTableArray(v JSEntity) bool {
+	list, ok := v.(JSList)
+	if !ok || list.Length() == 0 {
+		return false
+	}
+	for _, x := range list.wrappedList {
+		if _, ok := x.(JSMap); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTomlTable(sb *strings.Builder, m JSMap, path []string) error {
+	var tables, tableArrays []string
+	for _, k := range m.OrderedKeys() {
+		v := m.wrappedMap[k]
+		if x, ok := v.(JSMap); ok && x.Size() != 0 {
+			tables = append(tables, k)
+			continue
+		}
+		if isTomlTableArray(v) {
+			tableArrays = append(tableArrays, k)
+			continue
+		}
+		sb.WriteString(tomlKey(k))
+		sb.WriteString(" = ")
+		if err := writeTomlValue(sb, v, append(path[:len(path):len(path)], k)); err != nil {
+			return err
+		}
+		sb.WriteByte('\n')
+	}
+	for _, k := range tables {
+		childPath := append(path[:len(path):len(path)], k)
+		child := m.wrappedMap[k].(JSMap)
+		// A header is unnecessary if the table contains only subtables, since they define it implicitly
+		if tomlHasPlainValues(child) {
+			sb.WriteString("\n[" + tomlKeyPath(childPath) + "]\n")
+		}
+		if err := writeTomlTable(sb, child, childPath); err != nil {
+			return err
+		}
+	}
+	for _, k := range tableArrays {
+		childPath := append(path[:len(path):len(path)], k)
+		for _, x := range m.wrappedMap[k].(JSList).wrappedList {
+			sb.WriteString("\n[[" + tomlKeyPath(childPath) + "]]\n")
+			if err := writeTomlTable(sb, x.(JSMap), childPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tomlHasPlainValues(m JSMap) bool {
+	for _, v := range m.wrappedMap {
+		if x, ok := v.(JSMap); !(ok && x.Size() != 0) && !isTomlTableArray(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a value inline (i.e., following a key's '=', or within an array or inline table)
+func writeTomlValue(sb *strings.Builder, v JSEntity, path []string) error {
+	switch x := v.(type) {
+	case JString:
+		sb.WriteString(tomlString(string(x)))
+	case JBytes:
+		sb.WriteString(tomlString(x.AsString()))
+	case JInteger, JBool, JNumber:
+		sb.WriteString(PrintJSEntity(x, false))
+	case JFloat:
+		sb.WriteString(tomlFloatString(float64(x)))
+	case JSList:
+		sb.WriteByte('[')
+		for i, elem := range x.wrappedList {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			if err := writeTomlValue(sb, elem, append(path[:len(path):len(path)], IntToString(i))); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(']')
+	case JSMap:
+		sb.WriteByte('{')
+		for i, k := range x.OrderedKeys() {
+			sb.WriteString(Ternary(i == 0, " ", ", "))
+			sb.WriteString(tomlKey(k))
+			sb.WriteString(" = ")
+			if err := writeTomlValue(sb, x.wrappedMap[k], append(path[:len(path):len(path)], k)); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(Ternary(x.Size() == 0, "}", " }"))
+	default:
+		return Error("toml can't represent null; at:", JsonPointer(path...))
+	}
+	return nil
+}
+
+func tomlFloatString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func tomlKey(k string) string {
+	if k != "" && strings.IndexFunc(k, func(r rune) bool { return r > 127 || !isTomlBareKeyByte(byte(r)) }) < 0 {
+		return k
+	}
+	// Keys can't be multi-line strings
+	return tomlBasicString(k, false)
+}
+
+func tomlKeyPath(keys []string) string {
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, tomlKey(k))
+	}
+	return strings.Join(parts, ".")
+}
+
+// Get the representation of a string as a basic string, or a multi-line basic string if it contains newlines
+func tomlString(s string) string {
+	return tomlBasicString(s, strings.Contains(s, "\n"))
+}
+
+// Get the representation of a string as a basic string (with any newlines escaped), or a multi-line basic string
+func tomlBasicString(s string, multiLine bool) string {
+	sb := strings.Builder{}
+	if multiLine {
+		sb.WriteString("\"\"\"\n")
+	} else {
+		sb.WriteByte('"')
+	}
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(Ternary(multiLine, "\n", `\n`))
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < ' ' || r == 0x7f {
+				sb.WriteString(`\u`)
+				sb.Write(toHex(nil, int(r), 4))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteString(Ternary(multiLine, `"""`, `"`))
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"strings"
+	"testing"
+)
+
+var sampleToml = `# A sample config
+title = "TOML \"example\""
+count = 1_000
+mask = 0xff
+ratio = 6.5e-1
+enabled = true
+site."google.com" = 'C:\Users\jeff'
+born = 1979-05-27T07:32:00-08:00
+day = 1979-05-27
+
+[owner]
+name = "Tom"
+colors = [ "red",
+  "green", # a comment
+]
+
+[database.server]
+ports = [8000, 8001]
+limits = { min = 1, max = 2 }
+notes = """
+Roses are red
+  Violets are blue"""
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+name = "Nail"
+color = "gray"
+`
+
+func TestTomlParse(t *testing.T) {
+	j := jt.New(t)
+	m, err := JSMapFromToml(sampleToml)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m.GetString("title"), `TOML "example"`)
+	j.AssertEqual(m.GetInt("count"), 1000)
+	j.AssertEqual(m.GetInt("mask"), 255)
+	j.AssertEqual(m.GetPathM("/site/google.com"), JString(`C:\Users\jeff`))
+	j.AssertEqual(m.GetString("born"), "1979-05-27T07:32:00-08:00")
+	j.AssertEqual(m.GetPathM("/database/server/limits/max"), JInteger(2))
+	j.AssertEqual(m.GetPathM("/database/server/notes"), JString("Roses are red\n  Violets are blue"))
+	j.AssertEqual(m.GetList("products").Length(), 3)
+	j.AssertMessage(m)
+}
+
+func TestTomlWrite(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromTomlM(sampleToml)
+	j.AssertMessage(ToTomlM(m))
+}
+
+func TestTomlRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromTomlM(sampleToml)
+	m2, err := JSMapFromToml(ToTomlM(m))
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+
+	m = JSMapFromStringM(`{"a b":"tab\there","c":[[1,2],[{"d":3}]],"e":{},"f":1.0,"g":[{"h":{"i":"j"}}],"k":"multi\nline \"\"\" text"}`)
+	m2, err = JSMapFromToml(ToTomlM(m))
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+}
+
+func TestTomlRoundTripAwkwardKeys(t *testing.T) {
+	j := jt.New(t)
+	m := NewJSMap()
+	sub := NewJSMap()
+	for i, k := range []string{"a\nb", "\n", "q\"uote", "back\\slash", "tab\t", "\u0001", "\u007f", "é", "a.b", "a b", "", "[x]", "=", "#"} {
+		m.Put(k, i)
+		sub.Put(k, "v\n"+k)
+	}
+	m.Put("sub", sub)
+	text := ToTomlM(m)
+	j.AssertFalse(strings.Contains(text, `""" =`))
+	m2, err := JSMapFromToml(text)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+}
+
+func TestTomlSpecialFloats(t *testing.T) {
+	j := jt.New(t)
+	// Infinities and NaN have no JSON representation
+	for _, text := range []string{"a = inf\n", "b = -inf\n", "c = nan\n", "d = [1.0, +inf]\n"} {
+		_, err := JSMapFromToml(text)
+		_, ok := err.(*TomlError)
+		j.AssertTrue(ok, text)
+	}
+	m := JSMapFromTomlM("d = 3.0\n")
+	j.AssertEqual(m.OptAny("d"), JFloat(3))
+}
+
+func TestTomlErrors(t *testing.T) {
+	j := jt.New(t)
+	for _, text := range []string{
+		"a = 1\na = 2\n",
+		"[t]\nx = 1\n[t]\ny = 2\n",
+		"a = {x = 1}\n[a]\ny = 2\n",
+		"a = [1, 2]\n[[a]]\n",
+		"a = 01\n",
+		"a = \"unterminated\n",
+		"a = 1 b = 2\n",
+		"a = 99999999999999999999\n",
+	} {
+		_, err := JSMapFromToml(text)
+		_, ok := err.(*TomlError)
+		j.AssertTrue(ok, text)
+	}
+}
+
+func TestTomlNullNotSupported(t *testing.T) {
+	j := jt.New(t)
+	_, err := ToToml(JSMapFromStringM(`{"a":{"b":[1,null]}}`))
+	j.AssertTrue(err != nil)
+	j.AssertMessage(err)
+}
+
+func TestTomlFile(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromTomlM(sampleToml)
+	file := j.GetTestResultsDir().JoinM("sample.toml")
+	WriteTomlFileM(file, m)
+	j.AssertEqual(JSMapFromTomlFileM(file).CompactString(), m.CompactString())
+}
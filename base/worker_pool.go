@@ -0,0 +1,225 @@
+package base
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// A fixed number of goroutines that perform jobs, e.g.
+//
+//	pool := NewWorkerPool(ctx, 4)
+//	for _, photo := range photos {
+//		photo := photo
+//		pool.Submit(func(ctx context.Context) error { return resize(ctx, photo) })
+//	}
+//	errs := pool.Wait()
+//
+// Errors returned by jobs (and panics, converted to errors) are gathered in an ErrorHolder.
+type WorkerPoolStruct struct {
+	BaseObject
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   chan func(ctx context.Context) error
+	// Held (for reading) while submitting jobs, and (for writing) while closing the pool
+	submitLock sync.RWMutex
+	closed     bool
+	workers    sync.WaitGroup
+	errorLock  sync.Mutex
+	errors     ErrorHolder
+}
+
+type WorkerPool = *WorkerPoolStruct
+
+// Construct a pool with a number of workers (if zero, the number of CPUs).  If the context is cancelled
+// (or Cancel is called), jobs that haven't started are discarded.
+func NewWorkerPool(ctx context.Context, workers int) WorkerPool {
+	CheckArg(workers >= 0)
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	p := &WorkerPoolStruct{
+		jobs:   make(chan func(ctx context.Context) error),
+		errors: NewErrorHolder(),
+	}
+	p.SetName("WorkerPool")
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Get the context passed to the jobs; it is cancelled when the pool is
+func (p WorkerPool) Context() context.Context {
+	return p.ctx
+}
+
+// Submit a job, waiting until a worker is available to perform it.  Returns false if the pool was
+// cancelled before a worker became available.
+func (p WorkerPool) Submit(job func(ctx context.Context) error) bool {
+	p.submitLock.RLock()
+	defer p.submitLock.RUnlock()
+	CheckState(!p.closed, "pool has been closed")
+	if p.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// Discard any jobs that haven't started, and cancel the context passed to those that have
+func (p WorkerPool) Cancel() {
+	p.cancel()
+}
+
+// Wait for the submitted jobs to finish, and return any errors they produced.  No further jobs can be submitted.
+func (p WorkerPool) Wait() ErrorHolder {
+	p.submitLock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.submitLock.Unlock()
+	p.workers.Wait()
+	return p.errors
+}
+
+func (p WorkerPool) work() {
+	defer p.workers.Done()
+	for job := range p.jobs {
+		if err := callJob(p.ctx, job); err != nil {
+			p.errorLock.Lock()
+			p.errors.Add(err)
+			p.errorLock.Unlock()
+		}
+	}
+}
+
+// Perform a job, converting any panic to an error
+func callJob(ctx context.Context, job func(ctx context.Context) error) (err error) {
+	panicErr := catchPanicAsError(func() { err = job(ctx) })
+	if panicErr != nil {
+		err = Error("panic:", panicErr)
+	}
+	return
+}
+
+// ---------------------------------------------------------------------------------------
+// Parallel map and for-each
+// ---------------------------------------------------------------------------------------
+
+// Options for ParallelMap and ParallelForEach (and their Array variants); a nil Parallel uses the defaults
+type ParallelStruct struct {
+	ctx         context.Context
+	workers     int
+	progress    func(done int, total int)
+	stopOnError bool
+}
+
+type Parallel = *ParallelStruct
+
+func NewParallel() Parallel {
+	return &ParallelStruct{ctx: context.Background()}
+}
+
+// Stop processing items if a context is cancelled
+func (p Parallel) WithContext(ctx context.Context) Parallel {
+	p.ctx = ctx
+	return p
+}
+
+// Set the maximum number of items processed at once (default: the number of CPUs)
+func (p Parallel) WithWorkers(workers int) Parallel {
+	CheckArg(workers >= 0)
+	p.workers = workers
+	return p
+}
+
+// Call a function as each item is finished, with the number finished so far; the calls are not concurrent
+func (p Parallel) WithProgress(progress func(done int, total int)) Parallel {
+	p.progress = progress
+	return p
+}
+
+// Stop processing items once one fails
+func (p Parallel) WithStopOnError() Parallel {
+	p.stopOnError = true
+	return p
+}
+
+// Apply a function to each item of a slice concurrently, returning the results in the same order as
+// the items, along with any errors (ordered by item).  If an item's function fails (or the items aren't
+// all processed, e.g. because the context was cancelled), its result is the zero value.
+func ParallelMap[T any, R any](p Parallel, items []T, fn func(ctx context.Context, index int, item T) (R, error)) ([]R, ErrorHolder) {
+	if p == nil {
+		p = NewParallel()
+	}
+	results := make([]R, len(items))
+	itemErrors := make([]error, len(items))
+	pool := NewWorkerPool(p.ctx, p.workers)
+
+	var progressLock sync.Mutex
+	done := 0
+	submitted := 0
+	for i, item := range items {
+		i, item := i, item
+		if !pool.Submit(func(ctx context.Context) error {
+			err := callJob(ctx, func(ctx context.Context) (jobErr error) {
+				results[i], jobErr = fn(ctx, i, item)
+				return
+			})
+			itemErrors[i] = err
+			if err != nil && p.stopOnError {
+				pool.Cancel()
+			}
+			if p.progress != nil {
+				progressLock.Lock()
+				defer progressLock.Unlock()
+				done++
+				p.progress(done, len(items))
+			}
+			return nil
+		}) {
+			break
+		}
+		submitted++
+	}
+	pool.Wait()
+	pool.Cancel()
+
+	holder := NewErrorHolder()
+	for _, err := range itemErrors {
+		holder.Add(err)
+	}
+	if submitted < len(items) && p.ctx.Err() != nil {
+		holder.Add(p.ctx.Err())
+	}
+	return results, holder
+}
+
+// Call a function for each item of a slice concurrently, returning any errors (ordered by item)
+func ParallelForEach[T any](p Parallel, items []T, fn func(ctx context.Context, index int, item T) error) ErrorHolder {
+	_, errs := ParallelMap(p, items, func(ctx context.Context, index int, item T) (struct{}, error) {
+		return struct{}{}, fn(ctx, index, item)
+	})
+	return errs
+}
+
+// Apply a function to each item of an Array concurrently; see ParallelMap
+func ParallelMapArray[T any, R any](p Parallel, items *Array[T], fn func(ctx context.Context, index int, item T) (R, error)) (*Array[R], ErrorHolder) {
+	results, errs := ParallelMap(p, items.Array(), fn)
+	array := NewArray[R]()
+	array.Append(results...)
+	return array, errs
+}
+
+// Call a function for each item of an Array concurrently; see ParallelForEach
+func ParallelForEachArray[T any](p Parallel, items *Array[T], fn func(ctx context.Context, index int, item T) error) ErrorHolder {
+	return ParallelForEach(p, items.Array(), fn)
+}
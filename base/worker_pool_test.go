@@ -0,0 +1,129 @@
+package base_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolConcurrencyLimit(t *testing.T) {
+	j := jt.New(t)
+	pool := NewWorkerPool(context.Background(), 3)
+	var active, maxActive, total atomic.Int32
+	for i := 0; i < 20; i++ {
+		pool.Submit(func(ctx context.Context) error {
+			n := active.Add(1)
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			active.Add(-1)
+			total.Add(1)
+			return nil
+		})
+	}
+	errs := pool.Wait()
+	j.AssertEqual(errs.First(), nil)
+	j.AssertEqual(total.Load(), int32(20))
+	j.AssertTrue(maxActive.Load() <= 3)
+}
+
+func TestWorkerPoolErrorsAndPanics(t *testing.T) {
+	j := jt.New(t)
+	pool := NewWorkerPool(context.Background(), 2)
+	pool.Submit(func(ctx context.Context) error { return Error("failed") })
+	pool.Submit(func(ctx context.Context) error { panic("oops") })
+	pool.Submit(func(ctx context.Context) error { return nil })
+	errs := pool.Wait()
+	j.AssertEqual(errs.ErrorList.Size(), 2)
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	j := jt.New(t)
+	pool := NewWorkerPool(context.Background(), 1)
+	started := make(chan struct{})
+	pool.Submit(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	pool.Cancel()
+	j.AssertFalse(pool.Submit(func(ctx context.Context) error { return nil }))
+	errs := pool.Wait()
+	j.AssertTrue(errors.Is(errs.First(), context.Canceled))
+}
+
+func TestParallelMapKeepsOrder(t *testing.T) {
+	j := jt.New(t)
+	items := NewArray[int]()
+	for i := 0; i < 100; i++ {
+		items.Add(i)
+	}
+	var lastDone, progressCalls atomic.Int32
+	p := NewParallel().WithWorkers(8).WithProgress(func(done int, total int) {
+		j.AssertEqual(total, 100)
+		lastDone.Store(int32(done))
+		progressCalls.Add(1)
+	})
+	results, errs := ParallelMapArray(p, items, func(ctx context.Context, index int, item int) (string, error) {
+		// Finish the items out of order
+		time.Sleep(time.Duration(item%7) * 100 * time.Microsecond)
+		return IntToString(item * item), nil
+	})
+	j.AssertEqual(errs.First(), nil)
+	j.AssertEqual(results.Size(), 100)
+	for i, r := range results.Array() {
+		j.AssertEqual(r, IntToString(i*i))
+	}
+	j.AssertEqual(progressCalls.Load(), int32(100))
+	j.AssertEqual(lastDone.Load(), int32(100))
+}
+
+func TestParallelForEachErrors(t *testing.T) {
+	j := jt.New(t)
+	items := []string{"a", "bad1", "c", "bad2", "e"}
+	errs := ParallelForEach(nil, items, func(ctx context.Context, index int, item string) error {
+		if item[0] == 'b' {
+			return Error(item)
+		}
+		return nil
+	})
+	// The errors are ordered by item
+	j.AssertEqual(errs.ErrorList.Size(), 2)
+	j.AssertEqual(errs.ErrorList.Get(0).Error(), "bad1")
+	j.AssertEqual(errs.ErrorList.Get(1).Error(), "bad2")
+}
+
+func TestParallelStopOnError(t *testing.T) {
+	j := jt.New(t)
+	items := make([]int, 1000)
+	var processed atomic.Int32
+	errs := ParallelForEach(NewParallel().WithWorkers(2).WithStopOnError(), items,
+		func(ctx context.Context, index int, item int) error {
+			processed.Add(1)
+			if index == 10 {
+				return Error("stop here")
+			}
+			return nil
+		})
+	j.AssertEqual(errs.First().Error(), "stop here")
+	j.AssertTrue(processed.Load() < 1000)
+}
+
+func TestParallelContextCancelled(t *testing.T) {
+	j := jt.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errs := ParallelMap(NewParallel().WithContext(ctx), []int{1, 2, 3}, func(ctx context.Context, index int, item int) (int, error) {
+		return item, nil
+	})
+	j.AssertTrue(errors.Is(errs.First(), context.Canceled))
+}
@@ -0,0 +1,1018 @@
+package base
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Support for reading and writing YAML documents as JSEntities.
+//
+// The reader handles the common subset of YAML 1.2: block and flow maps and sequences, plain,
+// quoted, and block (| and >) scalars, and comments.  Anchors, aliases, tags, complex keys and
+// multiple documents are not supported.  Plain scalars are resolved using the core schema
+// (null, booleans, integers, floats); integers too large for an int64 become JNumbers.
+//
+// Since JSMaps don't retain the order of their keys, the writer emits them in the order
+// given by OrderedKeys.
+
+type YamlError struct {
+	Line    int
+	Problem string
+}
+
+func (e *YamlError) Error() string {
+	return "Problem parsing yaml at line " + IntToString(e.Line) + ": " + e.Problem
+}
+
+// Parse a YAML document that contains a map.  An empty document produces an empty map.
+func JSMapFromYaml(text string) (JSMap, error) {
+	var result JSMap
+	e, err := JSEntityFromYaml(text)
+	if err == nil {
+		if e == JNullValue {
+			result = NewJSMap()
+		} else if m, ok := e.(JSMap); ok {
+			result = m
+		} else {
+			err = Error("yaml document is not a map")
+		}
+	}
+	return result, err
+}
+
+func JSMapFromYamlM(text string) JSMap {
+	return CheckOkWith(JSMapFromYaml(text))
+}
+
+// Parse a YAML document containing any value
+func JSEntityFromYaml(text string) (result JSEntity, err error) {
+	// The final line break ends the last line, rather than starting another (which would add a blank
+	// line to a block scalar that keeps its trailing line breaks)
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	p := &yamlParser{lines: strings.Split(text, "\n")}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*YamlError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e
+		}
+	}()
+	result = p.parseDocument()
+	return
+}
+
+// ---------------------------------------------------------------------------------------
+// Reading
+// ---------------------------------------------------------------------------------------
+
+type yamlParser struct {
+	lines []string
+	// Index of the current line
+	pos int
+}
+
+func (p *yamlParser) fail(problem ...any) {
+	line := MinInt(p.pos, len(p.lines)-1) + 1
+	panic(&YamlError{Line: line, Problem: ToString(problem...)})
+}
+
+func (p *yamlParser) parseDocument() JSEntity {
+	p.skipBlank()
+	for p.pos < len(p.lines) && strings.HasPrefix(p.lines[p.pos], "%") {
+		// Ignore directives
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos < len(p.lines) && isYamlDocumentMarker(p.lines[p.pos], "---") {
+		// Any content following the marker is treated as if it were on the next line
+		p.lines[p.pos] = "   " + p.lines[p.pos][3:]
+	}
+	result := p.parseBlockNode(-1)
+	if !p.skipBlank() && p.pos < len(p.lines) && isYamlDocumentMarker(p.lines[p.pos], "...") {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos < len(p.lines) {
+		if isYamlDocumentMarker(p.lines[p.pos], "---") {
+			p.fail("multiple documents aren't supported")
+		}
+		p.fail("unexpected text:", Quoted(strings.TrimSpace(p.lines[p.pos])))
+	}
+	return result
+}
+
+func isYamlDocumentMarker(line string, marker string) bool {
+	return strings.HasPrefix(line, marker) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t')
+}
+
+// Skip blank lines and comments; return true if there is a line with content remaining
+// (that isn't a document marker)
+func (p *yamlParser) skipBlank() bool {
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		t := strings.TrimSpace(line)
+		if t != "" && t[0] != '#' {
+			return !isYamlDocumentMarker(line, "---") && !isYamlDocumentMarker(line, "...")
+		}
+		p.pos++
+	}
+	return false
+}
+
+// Determine the indentation of a (non-blank) line
+func (p *yamlParser) indentOf(line string) int {
+	n := countLeadingSpaces(line)
+	if n < len(line) && line[n] == '\t' && strings.TrimSpace(line) != "" {
+		p.fail("tabs can't be used for indentation")
+	}
+	return n
+}
+
+func countLeadingSpaces(line string) int {
+	n := 0
+	for n < len(line) && line[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// Parse the node (if any) starting on the next line with content, if it is indented more than its parent
+func (p *yamlParser) parseBlockNode(parent int) JSEntity {
+	if !p.skipBlank() {
+		return JNullValue
+	}
+	ind := p.indentOf(p.lines[p.pos])
+	if ind <= parent {
+		return JNullValue
+	}
+	return p.parseNodeAt(ind, parent)
+}
+
+// Parse the node starting on the current line, which has a particular indentation
+func (p *yamlParser) parseNodeAt(ind int, parent int) JSEntity {
+	content := p.lines[p.pos][ind:]
+	if isYamlSequenceItem(content) {
+		return p.parseSequence(ind)
+	}
+	if _, _, ok := splitYamlKey(content); ok {
+		return p.parseMapping(ind)
+	}
+	return p.parseInlineValue(content, parent)
+}
+
+func isYamlSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ") || strings.HasPrefix(content, "-\t")
+}
+
+func (p *yamlParser) parseSequence(ind int) JSList {
+	list := NewJSList()
+	for p.skipBlank() {
+		line := p.lines[p.pos]
+		if p.indentOf(line) != ind || !isYamlSequenceItem(line[ind:]) {
+			break
+		}
+		rest := line[ind+1:]
+		trimmed := strings.TrimLeft(rest, " \t")
+		var value JSEntity
+		if trimmed == "" || trimmed[0] == '#' {
+			p.pos++
+			value = p.parseBlockNode(ind)
+		} else {
+			// Treat the item's content as if it started a line of its own, so that e.g.
+			// a map's first key can appear on the same line as the '-'
+			col := ind + 1 + len(rest) - len(trimmed)
+			p.lines[p.pos] = Spaces(col) + trimmed
+			value = p.parseNodeAt(col, ind)
+		}
+		list.wrappedList = append(list.wrappedList, value)
+	}
+	return list
+}
+
+func (p *yamlParser) parseMapping(ind int) JSMap {
+	m := NewJSMap()
+	for p.skipBlank() {
+		line := p.lines[p.pos]
+		i := p.indentOf(line)
+		if i < ind {
+			break
+		}
+		if i > ind {
+			p.fail("unexpected indentation")
+		}
+		key, rest, ok := splitYamlKey(line[ind:])
+		if !ok {
+			if isYamlSequenceItem(line[ind:]) {
+				break
+			}
+			p.fail("expected a key")
+		}
+		if m.HasKey(key) {
+			p.fail("duplicate key:", Quoted(key))
+		}
+		m.wrappedMap[key] = p.parseMapValue(rest, ind)
+	}
+	return m
+}
+
+// Parse the value following a key (whose indentation is ind)
+func (p *yamlParser) parseMapValue(rest string, ind int) JSEntity {
+	trimmed := strings.TrimLeft(rest, " \t")
+	if trimmed != "" && trimmed[0] != '#' {
+		return p.parseInlineValue(trimmed, ind)
+	}
+	p.pos++
+	if p.skipBlank() {
+		line := p.lines[p.pos]
+		i := p.indentOf(line)
+		if i > ind {
+			return p.parseNodeAt(i, ind)
+		}
+		// A sequence can have the same indentation as its key
+		if i == ind && isYamlSequenceItem(line[i:]) {
+			return p.parseSequence(ind)
+		}
+	}
+	return JNullValue
+}
+
+// Split a line into a key and the text following its ':', if it has that form
+func splitYamlKey(content string) (key string, rest string, ok bool) {
+	if content == "" {
+		return
+	}
+	if c := content[0]; c == '"' || c == '\'' {
+		value, end, err := scanYamlQuoted(content, 0)
+		if err != nil || end < 0 {
+			return
+		}
+		t := strings.TrimLeft(content[end:], " \t")
+		if strings.HasPrefix(t, ":") && (len(t) == 1 || t[1] == ' ' || t[1] == '\t') {
+			return value, t[1:], true
+		}
+		return
+	}
+	if strings.IndexByte("[{#&*!|>%@`", content[0]) >= 0 {
+		return
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if c == '#' && i > 0 && (content[i-1] == ' ' || content[i-1] == '\t') {
+			return
+		}
+		if c == ':' && (i+1 == len(content) || content[i+1] == ' ' || content[i+1] == '\t') {
+			key = strings.TrimRight(content[:i], " \t")
+			return key, content[i+1:], key != ""
+		}
+	}
+	return
+}
+
+// Parse a value that starts partway through the current line, and may continue on subsequent lines
+// that are indented more than its parent
+func (p *yamlParser) parseInlineValue(text string, parent int) JSEntity {
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlockScalar(text, parent)
+	case '&', '*', '!':
+		p.fail("anchors, aliases and tags aren't supported")
+	case '"', '\'':
+		return p.parseQuoted(text)
+	case '[', '{':
+		return p.parseFlow(text)
+	}
+	return p.parsePlain(text, parent)
+}
+
+// Remove a comment (and any whitespace preceding it) from the end of some text
+func stripYamlComment(text string) string {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			text = text[:i]
+			break
+		}
+	}
+	return strings.TrimRight(text, " \t")
+}
+
+func (p *yamlParser) parsePlain(text string, parent int) JSEntity {
+	value := stripYamlComment(text)
+	p.pos++
+	sb := strings.Builder{}
+	sb.WriteString(value)
+	multiLine := false
+	newlines := 0
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		t := strings.TrimSpace(line)
+		if t == "" {
+			newlines++
+			p.pos++
+			continue
+		}
+		if t[0] == '#' || countLeadingSpaces(line) <= parent || isYamlDocumentMarker(line, "---") || isYamlDocumentMarker(line, "...") {
+			break
+		}
+		// Fold the lines together
+		if newlines == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(strings.Repeat("\n", newlines))
+		}
+		newlines = 0
+		sb.WriteString(stripYamlComment(t))
+		multiLine = true
+		p.pos++
+	}
+	if multiLine {
+		return JString(sb.String())
+	}
+	v, ok := readYamlScalar(value)
+	if !ok {
+		p.fail("infinite and NaN values are not supported:", Quoted(value))
+	}
+	return v
+}
+
+// Parse a quoted scalar, which may continue onto subsequent lines
+func (p *yamlParser) parseQuoted(text string) JSEntity {
+	startLine := p.pos
+	for {
+		value, end, err := scanYamlQuoted(text, 0)
+		if err != nil {
+			p.pos = startLine
+			p.fail(err)
+		}
+		p.pos++
+		if end >= 0 {
+			if rest := stripYamlComment(text[end:]); rest != "" {
+				p.pos--
+				p.fail("unexpected text after quoted string:", Quoted(rest))
+			}
+			return JString(value)
+		}
+		if p.pos == len(p.lines) {
+			p.pos = startLine
+			p.fail("unterminated quoted string")
+		}
+		text += "\n" + p.lines[p.pos]
+	}
+}
+
+// Scan a quoted string (which may contain newlines) starting at a particular position.  Returns the
+// unescaped value and the position following the closing quote, or -1 if the closing quote is missing.
+func scanYamlQuoted(s string, start int) (string, int, error) {
+	quote := s[start]
+	sb := strings.Builder{}
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				sb.WriteByte('\'')
+				i++
+				continue
+			}
+			return sb.String(), i + 1, nil
+		case c == '\n':
+			// Fold line breaks: a single one becomes a space, and blank lines become newlines
+			str := strings.TrimRight(sb.String(), " \t")
+			sb.Reset()
+			sb.WriteString(str)
+			newlines := 0
+			for i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t' || s[i+1] == '\n') {
+				i++
+				if s[i] == '\n' {
+					newlines++
+				}
+			}
+			if newlines == 0 {
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteString(strings.Repeat("\n", newlines))
+			}
+		case c == '\\' && quote == '"':
+			if i+1 == len(s) {
+				return "", -1, nil
+			}
+			i++
+			if s[i] == '\n' {
+				// An escaped line break is removed, along with leading whitespace on the next line
+				for i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t') {
+					i++
+				}
+				continue
+			}
+			var err error
+			i, err = yamlEscape(&sb, s, i)
+			if err != nil {
+				return "", 0, err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", -1, nil
+}
+
+// Process the escape sequence whose first character (following the backslash) is at position i;
+// return the position of its last character
+func yamlEscape(sb *strings.Builder, s string, i int) (int, error) {
+	simple := map[byte]string{'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+		'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+		'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029"}
+	c := s[i]
+	if x, ok := simple[c]; ok {
+		sb.WriteString(x)
+		return i, nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if digits == 0 {
+		return i, Error("unsupported escape sequence:", Quoted("\\"+string(c)))
+	}
+	if i+digits >= len(s) {
+		return i, Error("incomplete escape sequence")
+	}
+	v, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+	if err != nil {
+		return i, Error("bad escape sequence:", Quoted(s[i-1:i+1+digits]))
+	}
+	i += digits
+	r := rune(v)
+	// Combine UTF-16 surrogate pairs
+	if utf16.IsSurrogate(r) && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+		if v2, err := strconv.ParseUint(s[i+3:i+7], 16, 32); err == nil {
+			r = utf16.DecodeRune(r, rune(v2))
+			i += 6
+		}
+	}
+	if !utf8.ValidRune(r) {
+		return i, Error("bad escape sequence; not a valid character")
+	}
+	sb.WriteRune(r)
+	return i, nil
+}
+
+// Parse a flow collection ([...] or {...}), which may continue onto subsequent lines
+func (p *yamlParser) parseFlow(text string) JSEntity {
+	buf := strings.Builder{}
+	depth := 0
+	inQuote := byte(0)
+	startLine := p.pos
+	s := text
+	p.pos++
+	for {
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if inQuote != 0 {
+				buf.WriteByte(c)
+				if c == '\\' && inQuote == '"' && i+1 < len(s) {
+					i++
+					buf.WriteByte(s[i])
+				} else if c == inQuote {
+					inQuote = 0
+				}
+				continue
+			}
+			if c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+				break
+			}
+			buf.WriteByte(c)
+			switch c {
+			case '"', '\'':
+				inQuote = c
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					if rest := stripYamlComment(s[i+1:]); rest != "" {
+						p.fail("unexpected text after flow collection:", Quoted(rest))
+					}
+					f := yamlFlowParser{text: buf.String(), line: startLine + 1}
+					return f.parseValue()
+				}
+			}
+		}
+		if p.pos == len(p.lines) {
+			p.pos = startLine
+			p.fail("unterminated flow collection")
+		}
+		buf.WriteByte('\n')
+		s = p.lines[p.pos]
+		p.pos++
+	}
+}
+
+type yamlFlowParser struct {
+	text   string
+	cursor int
+	// Line number where the collection starts
+	line int
+}
+
+func (f *yamlFlowParser) fail(problem ...any) {
+	panic(&YamlError{Line: f.line, Problem: ToString(problem...)})
+}
+
+func (f *yamlFlowParser) skipWhitespace() {
+	for f.cursor < len(f.text) && strings.IndexByte(" \t\n", f.text[f.cursor]) >= 0 {
+		f.cursor++
+	}
+}
+
+func (f *yamlFlowParser) peek() byte {
+	f.skipWhitespace()
+	if f.cursor == len(f.text) {
+		f.fail("unexpected end of flow collection")
+	}
+	return f.text[f.cursor]
+}
+
+func (f *yamlFlowParser) parseValue() JSEntity {
+	switch f.peek() {
+	case '[':
+		return f.parseList()
+	case '{':
+		return f.parseMap()
+	case '"', '\'':
+		return JString(f.parseQuoted())
+	}
+	text := f.parsePlain()
+	v, ok := readYamlScalar(text)
+	if !ok {
+		f.fail("infinite and NaN values are not supported:", Quoted(text))
+	}
+	return v
+}
+
+func (f *yamlFlowParser) parseQuoted() string {
+	value, end, err := scanYamlQuoted(f.text, f.cursor)
+	if err != nil {
+		f.fail(err)
+	}
+	if end < 0 {
+		f.fail("unterminated quoted string")
+	}
+	f.cursor = end
+	return value
+}
+
+// Read a plain scalar, up to the next indicator that ends it
+func (f *yamlFlowParser) parsePlain() string {
+	start := f.cursor
+	for f.cursor < len(f.text) {
+		c := f.text[f.cursor]
+		if strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		if c == ':' && (f.cursor+1 == len(f.text) || strings.IndexByte(" \t\n,[]{}", f.text[f.cursor+1]) >= 0) {
+			break
+		}
+		f.cursor++
+	}
+	return strings.Join(strings.Fields(f.text[start:f.cursor]), " ")
+}
+
+func (f *yamlFlowParser) parseKey() string {
+	if c := f.peek(); c == '"' || c == '\'' {
+		return f.parseQuoted()
+	}
+	return f.parsePlain()
+}
+
+func (f *yamlFlowParser) parseList() JSList {
+	list := NewJSList()
+	f.cursor++
+	for f.peek() != ']' {
+		var value JSEntity
+		c := f.peek()
+		if c == '[' || c == '{' {
+			value = f.parseValue()
+		} else {
+			// This could be the key of a single-pair map
+			start := f.cursor
+			key := f.parseKey()
+			if f.peek() == ':' {
+				f.cursor++
+				m := NewJSMap()
+				m.wrappedMap[key] = f.parseOptionalValue()
+				value = m
+			} else {
+				f.cursor = start
+				value = f.parseValue()
+			}
+		}
+		list.wrappedList = append(list.wrappedList, value)
+		if f.peek() != ']' {
+			f.expect(',')
+		}
+	}
+	f.cursor++
+	return list
+}
+
+func (f *yamlFlowParser) parseMap() JSMap {
+	m := NewJSMap()
+	f.cursor++
+	for f.peek() != '}' {
+		key := f.parseKey()
+		var value JSEntity = JNullValue
+		if f.peek() == ':' {
+			f.cursor++
+			value = f.parseOptionalValue()
+		}
+		if m.HasKey(key) {
+			f.fail("duplicate key:", Quoted(key))
+		}
+		m.wrappedMap[key] = value
+		if f.peek() != '}' {
+			f.expect(',')
+		}
+	}
+	f.cursor++
+	return m
+}
+
+// Parse the value following a ':', which may be omitted (denoting null)
+func (f *yamlFlowParser) parseOptionalValue() JSEntity {
+	if c := f.peek(); c == ',' || c == '}' || c == ']' {
+		return JNullValue
+	}
+	return f.parseValue()
+}
+
+func (f *yamlFlowParser) expect(c byte) {
+	if f.peek() != c {
+		f.fail("expected", Quoted(string(c)), "in flow collection")
+	}
+	f.cursor++
+}
+
+// Parse a block scalar (| or >) given its header
+func (p *yamlParser) parseBlockScalar(header string, parent int) JSEntity {
+	h := stripYamlComment(header)
+	literal := h[0] == '|'
+	chomp := byte(0)
+	explicitIndent := 0
+	for _, c := range []byte(h[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			explicitIndent = int(c - '0')
+		default:
+			p.fail("bad block scalar header:", Quoted(h))
+		}
+	}
+	p.pos++
+
+	contentIndent := -1
+	if explicitIndent != 0 {
+		contentIndent = MaxInt(parent, 0) + explicitIndent
+	}
+	var lines []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		ind := countLeadingSpaces(line)
+		if strings.TrimSpace(line) == "" {
+			// Preserve any spaces beyond the content's indentation
+			if contentIndent >= 0 && ind > contentIndent {
+				lines = append(lines, line[contentIndent:])
+			} else {
+				lines = append(lines, "")
+			}
+			p.pos++
+			continue
+		}
+		if contentIndent < 0 {
+			if ind <= parent {
+				break
+			}
+			contentIndent = ind
+		}
+		if ind < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+		p.pos++
+	}
+
+	// Separate any trailing blank lines from the content
+	n := len(lines)
+	for n > 0 && strings.TrimSpace(lines[n-1]) == "" {
+		n--
+	}
+	trailing := len(lines) - n
+	body := lines[:n]
+
+	var text string
+	if literal {
+		text = strings.Join(body, "\n")
+	} else {
+		text = foldYamlLines(body)
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		text += strings.Repeat("\n", trailing+Ternary(n > 0, 1, 0))
+	default:
+		if n > 0 {
+			text += "\n"
+		}
+	}
+	return JString(text)
+}
+
+// Fold the lines of a '>' block scalar: line breaks between lines become spaces, except around
+// blank lines and lines that are more indented
+func foldYamlLines(lines []string) string {
+	sb := strings.Builder{}
+	moreIndented := func(s string) bool {
+		return s != "" && (s[0] == ' ' || s[0] == '\t')
+	}
+	prev := -1
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		blanks := i - prev - 1
+		if prev >= 0 {
+			if blanks == 0 && !moreIndented(line) && !moreIndented(lines[prev]) {
+				sb.WriteByte(' ')
+			} else {
+				extra := Ternary(moreIndented(line) || moreIndented(lines[prev]), 1, 0)
+				sb.WriteString(strings.Repeat("\n", blanks+extra))
+			}
+		} else {
+			sb.WriteString(strings.Repeat("\n", blanks))
+		}
+		sb.WriteString(line)
+		prev = i
+	}
+	return sb.String()
+}
+
+var yamlIntExpr = regexp.MustCompile(`^[-+]?[0-9]+$`)
+var yamlFloatExpr = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// Determine the value of a plain scalar read from a document; returns false if it is infinite or NaN,
+// since those have no JSON representation
+func readYamlScalar(s string) (JSEntity, bool) {
+	v := resolveYamlScalar(s)
+	if f, ok := v.(JFloat); ok && (math.IsInf(float64(f), 0) || math.IsNaN(float64(f))) {
+		return nil, false
+	}
+	return v, true
+}
+
+// Determine the value of a plain scalar, using the YAML 1.2 core schema
+func resolveYamlScalar(s string) JSEntity {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return JNullValue
+	case "true", "True", "TRUE":
+		return JBoolTrue
+	case "false", "False", "FALSE":
+		return JBoolFalse
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return JFloat(math.Inf(1))
+	case "-.inf", "-.Inf", "-.INF":
+		return JFloat(math.Inf(-1))
+	case ".nan", ".NaN", ".NAN":
+		return JFloat(math.NaN())
+	}
+	if yamlIntExpr.MatchString(s) {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return JInteger(v)
+		}
+		// Normalize the text (e.g. remove a leading '+' or zeros), so it is a valid JSON number
+		v, _ := new(big.Int).SetString(s, 10)
+		return JNumber(v.String())
+	}
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o') {
+		if v, err := strconv.ParseInt(s[2:], Ternary(s[1] == 'x', 16, 8), 64); err == nil {
+			return JInteger(v)
+		}
+	}
+	if yamlFloatExpr.MatchString(s) {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return JFloat(v)
+		}
+	}
+	return JString(s)
+}
+
+// ---------------------------------------------------------------------------------------
+// Writing
+// ---------------------------------------------------------------------------------------
+
+// Convert a JSEntity to a YAML document, using block style for maps and lists
+func ToYaml(e JSEntity) string {
+	sb := strings.Builder{}
+	if isNonEmptyCollection(e) {
+		writeYamlBlock(&sb, e, 0)
+	} else {
+		writeYamlValue(&sb, e, 0)
+	}
+	return sb.String()
+}
+
+func isNonEmptyCollection(e JSEntity) bool {
+	switch x := e.(type) {
+	case JSMap:
+		return x.Size() != 0
+	case JSList:
+		return x.Length() != 0
+	}
+	return false
+}
+
+// Write a non-empty map or list, each of whose lines has a particular indentation
+func writeYamlBlock(sb *strings.Builder, e JSEntity, indent int) {
+	if m, ok := e.(JSMap); ok {
+		for _, k := range m.OrderedKeys() {
+			sb.WriteString(Spaces(indent))
+			sb.WriteString(yamlScalarString(k))
+			sb.WriteByte(':')
+			v := m.wrappedMap[k]
+			if isNonEmptyCollection(v) {
+				sb.WriteByte('\n')
+				writeYamlBlock(sb, v, indent+2)
+			} else {
+				sb.WriteByte(' ')
+				writeYamlValue(sb, v, indent)
+			}
+		}
+		return
+	}
+	for _, v := range e.(JSList).wrappedList {
+		sb.WriteString(Spaces(indent))
+		sb.WriteByte('-')
+		if isNonEmptyCollection(v) {
+			// Write the collection's first line following the '-'
+			nested := strings.Builder{}
+			writeYamlBlock(&nested, v, indent+2)
+			sb.WriteByte(' ')
+			sb.WriteString(nested.String()[indent+2:])
+		} else {
+			sb.WriteByte(' ')
+			writeYamlValue(sb, v, indent)
+		}
+	}
+}
+
+// Write a scalar (or empty collection) following a key or '-' whose indentation is given
+func writeYamlValue(sb *strings.Builder, e JSEntity, indent int) {
+	if s, ok := e.(JString); ok && yamlUseBlockScalar(string(s)) {
+		writeYamlBlockScalar(sb, string(s), indent+2)
+		return
+	}
+	switch x := e.(type) {
+	case JSMap:
+		sb.WriteString("{}")
+	case JSList:
+		sb.WriteString("[]")
+	case JString:
+		sb.WriteString(yamlScalarString(string(x)))
+	case JBytes:
+		sb.WriteString(yamlScalarString(x.AsString()))
+	case JFloat:
+		sb.WriteString(yamlFloatString(float64(x)))
+	default:
+		sb.WriteString(PrintJSEntity(e, false))
+	}
+	sb.WriteByte('\n')
+}
+
+func yamlFloatString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Make sure it isn't read back as an integer
+		s += ".0"
+	}
+	return s
+}
+
+// Determine if a string should be written as a literal block scalar: it must contain multiple
+// lines, and no characters (or whitespace-only lines) that such scalars can't represent
+func yamlUseBlockScalar(s string) bool {
+	if !strings.Contains(strings.TrimRight(s, "\n"), "\n") {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimSpace(line) == "" {
+			return false
+		}
+	}
+	for _, r := range s {
+		if (r < ' ' && r != '\n' && r != '\t') || r == 0x7f || r == utf8.RuneError {
+			return false
+		}
+	}
+	return true
+}
+
+func writeYamlBlockScalar(sb *strings.Builder, s string, indent int) {
+	body := strings.TrimRight(s, "\n")
+	trailing := len(s) - len(body)
+	sb.WriteByte('|')
+	if body[0] == ' ' || body[0] == '\n' {
+		// Content indentation can't be detected automatically
+		sb.WriteByte('2')
+	}
+	switch {
+	case trailing == 0:
+		sb.WriteByte('-')
+	case trailing > 1:
+		sb.WriteByte('+')
+	}
+	sb.WriteByte('\n')
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			sb.WriteString(Spaces(indent))
+			sb.WriteString(line)
+		}
+		sb.WriteByte('\n')
+	}
+	for i := 1; i < trailing; i++ {
+		sb.WriteByte('\n')
+	}
+}
+
+// Get the representation of a string, as a plain scalar if possible, or double-quoted
+func yamlScalarString(s string) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	sb := strings.Builder{}
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < ' ' || r == 0x7f {
+				sb.WriteString(`\x`)
+				sb.Write(toHex(nil, int(r), 2))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func yamlPlainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	// At the start of a line, these could be read as document markers
+	if strings.HasPrefix(s, "---") || strings.HasPrefix(s, "...") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == utf8.RuneError {
+			return false
+		}
+	}
+	// Strings that YAML 1.1 consumers would read as booleans must also be quoted
+	if yaml11Booleans[s] {
+		return false
+	}
+	_, isString := resolveYamlScalar(s).(JString)
+	return isString
+}
+
+var yaml11Booleans = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
@@ -0,0 +1,144 @@
+package base_test
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/jt"
+	"strconv"
+	"testing"
+)
+
+var sampleYaml = `# A sample config
+name: fido
+age: 3
+weight: 12.5
+active: true
+nickname: ~
+owner:
+  first: jeff
+  last: "O'Brien"
+  phones:
+    - 555-1234
+    - '555-9876'
+tags: [dog, "good boy", 7]
+limits: {min: 1, max: 0x10}
+pets:
+- kind: cat
+  lives: 9
+- kind: fish
+description: |
+  Line one
+    indented
+  Line three
+summary: >-
+  folded text
+  on two lines
+`
+
+func TestYamlParse(t *testing.T) {
+	j := jt.New(t)
+	m, err := JSMapFromYaml(sampleYaml)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m.GetString("name"), "fido")
+	j.AssertEqual(m.GetInt("age"), 3)
+	j.AssertEqual(m.OptAny("nickname"), JSEntity(JNullValue))
+	j.AssertEqual(m.GetPathM("/owner/phones/1"), JString("555-9876"))
+	j.AssertEqual(m.GetPathM("/limits/max"), JInteger(16))
+	j.AssertEqual(m.GetPathM("/pets/0/lives"), JInteger(9))
+	j.AssertEqual(m.GetString("description"), "Line one\n  indented\nLine three\n")
+	j.AssertEqual(m.GetString("summary"), "folded text on two lines")
+	j.AssertMessage(m)
+}
+
+func TestYamlWrite(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromYamlM(sampleYaml)
+	j.AssertMessage(ToYaml(m))
+}
+
+func TestYamlRoundTrip(t *testing.T) {
+	j := jt.New(t)
+	m := JSMapFromStringM(`{"a":"","b":"true","c":"12","d":" padded ","e":"x: y","f":"multi\nline","g":[],"h":{},` +
+		`"i":[[1,2],{"j":null}],"k":"- dash","l":"#hash","m":1.0,"n":"tab\there","o":"été"}`)
+	text := ToYaml(m)
+	m2, err := JSMapFromYaml(text)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+}
+
+func TestYamlRoundTripAwkwardStrings(t *testing.T) {
+	j := jt.New(t)
+	awkward := []string{"", " ", "...", "---", "... x", "--- y", "...x", "---y", "\\}>", "}", "]", "{a}", "[a]", ",",
+		"a, b", "a: b", "a:b", ":", "::", "-", "- ", "-x", "-1", "?", "? x", "?x", "x #y", "x#y", "#", "%d", "@a", "`a`",
+		"!tag", "&a", "*a", "*", "|", ">", "<<", "=", "'q'", "\"q\"", "it's", "~", "null", "Null", "NULL", "true", "False",
+		"1e3", ".5", "+1", "0x1F", "0o17", "1_000", ".inf", ".NaN", "2001-12-14", "a\tb", "a\nb", "\n", "\\", "é",
+		"\u007f", "\u2028", "x ", " x", "a  b", "a\\b", "x\n\n", "\nb\n\n"}
+	m := NewJSMap()
+	for i, s := range awkward {
+		m.Put(s, s)
+		m.Put(strconv.Itoa(i), s)
+	}
+	m.Put("list", JSListWith(awkward))
+	text := ToYaml(m)
+	m2, err := JSMapFromYaml(text)
+	j.AssertEqual(err, nil)
+	j.AssertEqual(m2.CompactString(), m.CompactString())
+
+	// A block scalar that keeps its trailing line breaks, at the end of the document
+	m = NewJSMap().Put("z", "x\n\n")
+	j.AssertEqual(JSMapFromYamlM(ToYaml(m)).CompactString(), m.CompactString())
+}
+
+func TestYamlSpecialFloats(t *testing.T) {
+	j := jt.New(t)
+	// Infinities and NaN have no JSON representation
+	for _, text := range []string{"a: .inf\n", "b: -.Inf\n", "c: .nan\n", "d: [1, .inf]\n"} {
+		_, err := JSMapFromYaml(text)
+		_, ok := err.(*YamlError)
+		j.AssertTrue(ok, text)
+	}
+	m := JSMapFromYamlM("a: '.inf'\nd: 1e3\ne: +0123456789012345678901234567890\n")
+	j.AssertEqual(m.OptAny("a"), JString(".inf"))
+	j.AssertEqual(m.OptFloat64("d", 0), 1000.0)
+	j.AssertEqual(m.OptAny("e"), JNumber("123456789012345678901234567890"))
+}
+
+func TestYamlQuotesYaml11Booleans(t *testing.T) {
+	j := jt.New(t)
+	m := NewJSMap()
+	for _, s := range []string{"yes", "No", "ON", "off", "y", "N", "maybe"} {
+		m.Put(s, s)
+	}
+	text := ToYaml(m)
+	j.AssertEqual(text, `"N": "N"
+"No": "No"
+"ON": "ON"
+maybe: maybe
+"off": "off"
+"y": "y"
+"yes": "yes"
+`)
+	j.AssertEqual(JSMapFromYamlM(text).CompactString(), m.CompactString())
+}
+
+func TestYamlErrors(t *testing.T) {
+	j := jt.New(t)
+	for _, text := range []string{
+		"a: 1\n\tb: 2\n",
+		"a: [1, 2\n",
+		"a: 1\na: 2\n",
+		"a: \"unterminated\n",
+	} {
+		_, err := JSMapFromYaml(text)
+		_, ok := err.(*YamlError)
+		j.AssertTrue(ok, text)
+	}
+}
+
+func TestYamlFile(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	m := JSMapFromYamlM(sampleYaml)
+	file := dir.JoinM("sample.yaml")
+	WriteYamlFileM(file, m)
+	j.AssertEqual(JSMapFromYamlFileM(file).CompactString(), m.CompactString())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	. "github.com/jpsember/golang-base/app"
+	. "github.com/jpsember/golang-base/base"
+	"github.com/jpsember/golang-base/datagen"
+	. "github.com/jpsember/golang-base/gen/datagen"
+)
+
+// Generates Go data classes from .dat files, e.g.
+//
+//	datagen language go format source_path gen clean
+
+const clArgExceptions = "exceptions"
+
+type DatagenOper struct {
+	BaseObject
+	config DatagenConfig
+}
+
+func (oper *DatagenOper) UserCommand() string {
+	return "datagen"
+}
+
+func (oper *DatagenOper) GetHelp() (summary, usage string) {
+	summary = "Generates Go data classes from .dat files."
+	return
+}
+
+func (oper *DatagenOper) GetArguments() DataClass {
+	return DefaultDatagenConfig
+}
+
+func (oper *DatagenOper) ArgsFileMustExist() bool { return false }
+
+func (oper *DatagenOper) AcceptArguments(a DataClass) {
+	oper.config = a.(DatagenConfig)
+}
+
+func (oper *DatagenOper) Perform(app *App) {
+	c := oper.config
+	if c.Language() != "go" {
+		app.SetError("unsupported language:", Quoted(c.Language()))
+		return
+	}
+	g := datagen.NewGenerator(c.DatPath(), c.SourcePath()).WithFormat(c.Format()).WithClean(c.Clean())
+	g.SetVerbose(oper.Verbose())
+	files, err := g.Generate()
+	if err != nil {
+		if app.CmdLineArgs().Get(clArgExceptions) {
+			Die(err)
+		}
+		app.SetError(err)
+		return
+	}
+	oper.Log("generated", len(files), "file(s)")
+}
+
+func main() {
+	var oper = &DatagenOper{}
+	oper.ProvideName(oper)
+	var app = NewApp()
+	app.SetName("datagen")
+	app.Version = "1.0"
+	app.CmdLineArgs().Add(clArgExceptions).Desc("Show stack traces for errors")
+	app.RegisterOper(oper)
+	app.Start()
+}
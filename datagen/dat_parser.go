@@ -0,0 +1,294 @@
+package datagen
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"strings"
+)
+
+// The contents of a .dat file, which defines either a data class or an enum, e.g.
+//
+//	class {
+//	  string name;
+//	  int target = 12;
+//	  *Attachment attachments;     // list
+//	  map string string folder_map;
+//	  enum UserState state;
+//	}
+//
+//	enum {
+//	  user_state_waiting_activation,
+//	  user_state_active,
+//	}
+//
+// Any 'sql { ... }' sections are ignored.
+type DatFile struct {
+	// Name of the class or enum, derived from the file name (e.g. "DemoConfig")
+	Name string
+	// Name of the Go package (e.g. "sample")
+	Package    string
+	IsEnum     bool
+	Fields     []*DatField
+	EnumValues []string
+}
+
+type DatField struct {
+	// Key as it appears in the .dat file and in json, e.g. "password_hash"
+	Key string
+	// Type as it appears in the .dat file, e.g. "int", "File", "Attachment"; for maps, the type of the values
+	TypeName string
+	Enum     bool
+	List     bool
+	Map      bool
+	// Text of the default value, or "" if there is none
+	DefaultText string
+	// Parsed default value (unless the field is an enum), or nil if there is none
+	Default JSEntity
+}
+
+type DatError struct {
+	Line    int
+	Problem string
+}
+
+func (e *DatError) Error() string {
+	return "Problem parsing .dat file at line " + IntToString(e.Line) + ": " + e.Problem
+}
+
+// Parse the contents of a .dat file; name is the file's base name (e.g. "demo_config")
+func ParseDat(text string, name string, packageName string) (result *DatFile, err error) {
+	p := &datParser{text: text, line: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*DatError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e
+		}
+	}()
+	result = &DatFile{Name: CamelCase(name), Package: packageName}
+	p.parse(result)
+	return result, nil
+}
+
+type datParser struct {
+	text   string
+	cursor int
+	line   int
+}
+
+func (p *datParser) fail(problem ...any) {
+	panic(&DatError{Line: p.line, Problem: ToString(problem...)})
+}
+
+// Skip whitespace and comments
+func (p *datParser) skip() {
+	for p.cursor < len(p.text) {
+		c := p.text[p.cursor]
+		switch {
+		case c == '\n':
+			p.line++
+			p.cursor++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.cursor++
+		case strings.HasPrefix(p.text[p.cursor:], "//"):
+			for p.cursor < len(p.text) && p.text[p.cursor] != '\n' {
+				p.cursor++
+			}
+		case strings.HasPrefix(p.text[p.cursor:], "/*"):
+			end := strings.Index(p.text[p.cursor+2:], "*/")
+			if end < 0 {
+				p.fail("unterminated comment")
+			}
+			p.advance(end + 4)
+		default:
+			return
+		}
+	}
+}
+
+// Advance the cursor, counting any newlines
+func (p *datParser) advance(count int) {
+	p.line += strings.Count(p.text[p.cursor:p.cursor+count], "\n")
+	p.cursor += count
+}
+
+func (p *datParser) atEnd() bool {
+	p.skip()
+	return p.cursor >= len(p.text)
+}
+
+func (p *datParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.text[p.cursor]
+}
+
+func (p *datParser) readIf(c byte) bool {
+	if p.peek() == c {
+		p.cursor++
+		return true
+	}
+	return false
+}
+
+func (p *datParser) expect(c byte) {
+	if !p.readIf(c) {
+		p.fail("expected", Quoted(string(c)))
+	}
+}
+
+func (p *datParser) readIdentifier() string {
+	p.skip()
+	start := p.cursor
+	for p.cursor < len(p.text) && isIdentifierByte(p.text[p.cursor], p.cursor == start) {
+		p.cursor++
+	}
+	if start == p.cursor {
+		p.fail("expected an identifier")
+	}
+	return p.text[start:p.cursor]
+}
+
+func isIdentifierByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+func (p *datParser) parse(dat *DatFile) {
+	declared := false
+	for !p.atEnd() {
+		switch keyword := p.readIdentifier(); keyword {
+		case "class", "enum":
+			if declared {
+				p.fail("only one class or enum can be declared per file")
+			}
+			declared = true
+			p.expect('{')
+			if keyword == "enum" {
+				dat.IsEnum = true
+				p.parseEnumValues(dat)
+			} else {
+				p.parseFields(dat)
+			}
+		case "sql":
+			p.skipBlock()
+		default:
+			p.fail("unexpected:", Quoted(keyword))
+		}
+	}
+	if !declared {
+		p.fail("no class or enum declared")
+	}
+}
+
+func (p *datParser) parseEnumValues(dat *DatFile) {
+	names := NewSet[string]()
+	for !p.readIf('}') {
+		name := p.readIdentifier()
+		if !names.Add(name) {
+			p.fail("duplicate enum value:", name)
+		}
+		dat.EnumValues = append(dat.EnumValues, name)
+		if !p.readIf(',') {
+			p.expect('}')
+			break
+		}
+	}
+	if len(dat.EnumValues) == 0 {
+		p.fail("enum has no values")
+	}
+}
+
+func (p *datParser) parseFields(dat *DatFile) {
+	keys := NewSet[string]()
+	for !p.readIf('}') {
+		f := &DatField{}
+		f.List = p.readIf('*')
+		f.TypeName = p.readIdentifier()
+		switch f.TypeName {
+		case "enum":
+			f.Enum = true
+			f.TypeName = p.readIdentifier()
+		case "map":
+			if f.List {
+				p.fail("lists of maps are not supported")
+			}
+			f.Map = true
+			if keyType := p.readIdentifier(); keyType != "string" {
+				p.fail("map keys must be strings")
+			}
+			if p.peek() == '*' {
+				p.fail("maps of lists are not supported")
+			}
+			f.TypeName = p.readIdentifier()
+		}
+		f.Key = p.readIdentifier()
+		if p.readIf('=') {
+			f.DefaultText = p.readDefault()
+			if !f.Enum {
+				list, err := JSListFromString("[" + f.DefaultText + "]")
+				if err != nil || list.Length() != 1 {
+					p.fail("bad default value:", f.DefaultText)
+				}
+				f.Default = list.Get(0)
+			}
+		}
+		p.expect(';')
+		if !keys.Add(f.Key) {
+			p.fail("duplicate field:", f.Key)
+		}
+		dat.Fields = append(dat.Fields, f)
+	}
+}
+
+// Read the text of a default value, up to (but not including) the ';' that follows it
+func (p *datParser) readDefault() string {
+	p.skip()
+	start := p.cursor
+	inString := false
+	for ; p.cursor < len(p.text); p.cursor++ {
+		c := p.text[p.cursor]
+		switch {
+		case inString && c == '\\':
+			p.cursor++
+		case c == '"':
+			inString = !inString
+		case c == '\n':
+			p.fail("missing ';'")
+		case c == ';' && !inString:
+			return strings.TrimSpace(p.text[start:p.cursor])
+		}
+	}
+	p.fail("missing ';'")
+	return ""
+}
+
+// Skip a '{ ... }' block, including any nested blocks
+func (p *datParser) skipBlock() {
+	p.expect('{')
+	for depth := 1; depth > 0; {
+		if p.atEnd() {
+			p.fail("unterminated block")
+		}
+		switch p.text[p.cursor] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		p.cursor++
+	}
+}
+
+// Convert a name like "demo_config" to "DemoConfig"
+func CamelCase(name string) string {
+	sb := strings.Builder{}
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			sb.WriteString(strings.ToUpper(part[:1]))
+			sb.WriteString(part[1:])
+		}
+	}
+	return sb.String()
+}
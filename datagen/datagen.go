@@ -0,0 +1,104 @@
+package datagen
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"sort"
+)
+
+// Generates Go source files from .dat files.
+//
+// Each subdirectory <pkg> of the dat directory holds the .dat files for a Go package;
+// dat/<pkg>/<name>.dat generates source/<pkg>/<name>.go.
+type Generator struct {
+	BaseObject
+	datPath    Path
+	sourcePath Path
+	format     bool
+	clean      bool
+}
+
+func NewGenerator(datPath Path, sourcePath Path) *Generator {
+	g := &Generator{datPath: datPath.AssertNonEmpty(), sourcePath: sourcePath.AssertNonEmpty()}
+	g.SetName("Generator")
+	return g
+}
+
+// Format the generated source with gofmt
+func (g *Generator) WithFormat(flag bool) *Generator {
+	g.format = flag
+	return g
+}
+
+// Delete each generated package's directory before writing its source files, so that
+// files whose .dat files no longer exist are removed
+func (g *Generator) WithClean(flag bool) *Generator {
+	g.clean = flag
+	return g
+}
+
+// Generate source files for all the .dat files, returning the files written
+func (g *Generator) Generate() ([]Path, error) {
+	pr := g.Log
+	if !g.datPath.IsDir() {
+		return nil, Error("no such dat directory:", g.datPath)
+	}
+	var written []Path
+	for _, datFile := range g.datFiles() {
+		packageName := datFile.Parent().Base()
+		name := datFile.TrimExtension().Base()
+		pr("processing:", datFile)
+
+		text, err := datFile.ReadString()
+		if err != nil {
+			return written, err
+		}
+		dat, err := ParseDat(text, name, packageName)
+		if err == nil {
+			var source []byte
+			source, err = GenerateGo(dat, g.format)
+			if err == nil {
+				target := g.sourcePath.JoinM(packageName).JoinM(name + ".go")
+				err = g.write(target, source, written)
+				if err == nil {
+					written = append(written, target)
+				}
+			}
+		}
+		if err != nil {
+			return written, Error("problem generating source for:", datFile, INDENT, err)
+		}
+	}
+	return written, nil
+}
+
+// Get the .dat files within each package subdirectory, sorted by path
+func (g *Generator) datFiles() []Path {
+	var files []Path
+	for _, dir := range NewDirWalk(g.datPath).WithDirNames().Files() {
+		if !dir.IsDir() {
+			continue
+		}
+		files = append(files, NewDirWalk(dir).IncludeExtensions("dat").Files()...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].String() < files[j].String() })
+	return files
+}
+
+func (g *Generator) write(target Path, source []byte, previous []Path) error {
+	dir := target.Parent()
+	if g.clean && dir.Exists() {
+		// Only clean the package directory before writing its first file
+		firstInPackage := len(previous) == 0 || previous[len(previous)-1].Parent() != dir
+		if firstInPackage {
+			g.Log("cleaning:", dir)
+			if err := dir.DeleteDirectory(dir.String()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := dir.MkDirs(); err != nil {
+		return err
+	}
+	g.Log("writing:", target)
+	return target.WriteBytes(source)
+}
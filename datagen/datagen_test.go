@@ -0,0 +1,140 @@
+package datagen_test
+
+import (
+	"github.com/jpsember/golang-base/datagen"
+	"github.com/jpsember/golang-base/jt"
+	"strings"
+	"testing"
+)
+
+var kitchenSinkDat = `
+// A data class that uses every kind of field
+class {
+  bool enabled = true;
+  byte level = 3;
+  int count;
+  long timestamp = 1234567890123;
+  float ratio = 0.5;
+  double weight;
+  string name = "sink \"deluxe\"";
+  File root = "a/b";
+  *byte data;
+  enum AnimalState state = animal_state_active;
+  IPoint location = [3, 4];
+  Attachment attachment;
+  *string tags;
+  *double samples;
+  *Attachment attachments;
+  map string int counts;
+  map string Attachment attachment_map;
+  /* Go keywords can be used as keys */
+  string type;
+}
+
+sql { index name }
+`
+
+func generate(j jt.JTest, text string, name string) string {
+	dat, err := datagen.ParseDat(text, name, "sample")
+	j.AssertEqual(err, nil)
+	source, err := datagen.GenerateGo(dat, true)
+	j.AssertEqual(err, nil)
+	return string(source)
+}
+
+func TestRegenerateSample(t *testing.T) {
+	j := jt.New(t)
+	moduleDir := j.GetModuleDir()
+	text := moduleDir.JoinM("dat_files/sample/demo_config.dat").ReadStringM()
+	expected := moduleDir.JoinM("gen/sample/demo_config.go").ReadStringM()
+	j.AssertEqual(generate(j, text, "demo_config"), expected)
+}
+
+func TestGenerateClass(t *testing.T) {
+	j := jt.New(t)
+	j.AssertMessage(generate(j, kitchenSinkDat, "kitchen_sink"))
+}
+
+func TestGenerateEnum(t *testing.T) {
+	j := jt.New(t)
+	j.AssertMessage(generate(j, "enum { animal_state_unknown, animal_state_active, }", "animal_state"))
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	j := jt.New(t)
+	first := generate(j, kitchenSinkDat, "kitchen_sink")
+	for i := 0; i < 5; i++ {
+		j.AssertEqual(generate(j, kitchenSinkDat, "kitchen_sink"), first)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	j := jt.New(t)
+	cases := []string{
+		"",
+		"class { int x }",
+		"class { int x; int x; }",
+		"class { int x = ; }",
+		"class { map int string m; }",
+		"class { *map string int m; }",
+		"class {} enum { a }",
+		"enum { }",
+		"enum { a, a }",
+		"class { int x; /* unterminated",
+		"struct { int x; }",
+	}
+	sb := strings.Builder{}
+	for _, c := range cases {
+		_, err := datagen.ParseDat(c, "bad", "sample")
+		j.AssertTrue(err != nil, "expected error for:", c)
+		sb.WriteString(c + "\n  " + err.Error() + "\n")
+	}
+	j.AssertMessage(sb.String())
+}
+
+func TestGenerateErrors(t *testing.T) {
+	j := jt.New(t)
+	cases := []string{
+		"class { int to_json; }",
+		"class { *File files; }",
+		"class { map string enum Color colors; }",
+		"class { int x = \"hello\"; }",
+		"class { bool x = 3; }",
+		"class { point p; }",
+	}
+	for _, c := range cases {
+		dat, err := datagen.ParseDat(c, "bad", "sample")
+		if err == nil {
+			_, err = datagen.GenerateGo(dat, true)
+		}
+		j.AssertTrue(err != nil, "expected error for:", c)
+	}
+}
+
+func TestGenerateDirectory(t *testing.T) {
+	j := jt.New(t)
+	dir := j.GetTestResultsDir()
+	datDir := dir.JoinM("dat_files")
+	sourceDir := dir.JoinM("gen")
+	datDir.JoinM("alpha").MkDirsM()
+	datDir.JoinM("alpha/kitchen_sink.dat").WriteStringM(kitchenSinkDat)
+	datDir.JoinM("alpha/animal_state.dat").WriteStringM("enum { animal_state_unknown }")
+	datDir.JoinM("beta").MkDirsM()
+	datDir.JoinM("beta/demo_config.dat").WriteStringM("class { int target = 12; }")
+
+	// A stale file that cleaning should remove
+	sourceDir.JoinM("beta").MkDirsM()
+	stale := sourceDir.JoinM("beta/obsolete.go")
+	stale.WriteStringM("package beta\n")
+
+	files, err := datagen.NewGenerator(datDir, sourceDir).WithFormat(true).WithClean(true).Generate()
+	j.AssertEqual(err, nil)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Parent().Base()+"/"+f.Base())
+	}
+	j.AssertEqual(strings.Join(names, " "), "alpha/animal_state.go alpha/kitchen_sink.go beta/demo_config.go")
+	j.AssertFalse(stale.Exists())
+	expected := strings.Replace(generate(j, kitchenSinkDat, "kitchen_sink"), "package sample", "package alpha", 1)
+	j.AssertEqual(sourceDir.JoinM("alpha/kitchen_sink.go").ReadStringM(), expected)
+}
@@ -0,0 +1,469 @@
+package datagen
+
+import (
+	. "github.com/jpsember/golang-base/base"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// Generates Go source for a data class or enum.
+//
+// A data class Xxx is represented by an immutable interface Xxx (implemented by a private struct sXxx),
+// and a mutable XxxBuilder; these convert to and from json via ToJson and Parse.  Fields can be
+// primitives (bool, byte, int, long, float, double, string), Files (Paths), byte arrays (*byte), enums,
+// other data classes (including IPoint), and lists or string-keyed maps of primitives or data classes.
+
+// Generate the source for a .dat file; if format is true, it is formatted with gofmt
+func GenerateGo(dat *DatFile, format bool) ([]byte, error) {
+	g := &goGenerator{dat: dat, name: dat.Name}
+	var err error
+	if dat.IsEnum {
+		g.generateEnum()
+	} else {
+		err = g.generateClass()
+	}
+	if err != nil {
+		return nil, err
+	}
+	source := []byte(g.sb.String())
+	if format {
+		return formatSource(source)
+	}
+	return source, nil
+}
+
+func formatSource(source []byte) ([]byte, error) {
+	formatted, err := format.Source(source)
+	if err != nil {
+		return nil, Error("problem formatting generated source:", err)
+	}
+	return formatted, nil
+}
+
+type goGenerator struct {
+	dat    *DatFile
+	name   string
+	fields []*goField
+	sb     strings.Builder
+}
+
+// How a field is represented in Go
+type fieldKind int
+
+const (
+	kindPrimitive fieldKind = iota
+	kindFile
+	kindBytes
+	kindEnum
+	kindDataClass
+	kindPrimitiveList
+	kindDataClassList
+	kindPrimitiveMap
+	kindDataClassMap
+)
+
+type goField struct {
+	dat  *DatField
+	kind fieldKind
+	// Name of private struct field, e.g. "passwordHash"
+	name string
+	// Name of getter, e.g. "PasswordHash"
+	getter string
+	// Name of the constant holding the field's key, e.g. "User_PasswordHash"
+	constant string
+	// Go type, e.g. "[]Attachment"
+	goType string
+	// Go type of elements (for lists, maps) or the field itself (otherwise), e.g. "Attachment"
+	elemType string
+	// For primitives, the JSMap method used to parse the value, e.g. "OptInt"
+	optMethod string
+	// Expression for the default value, or "" if it is the zero value
+	defaultExpr string
+}
+
+type primitiveInfo struct {
+	goType    string
+	optMethod string
+	zero      string
+}
+
+var datPrimitives = map[string]primitiveInfo{
+	"bool":   {"bool", "OptBool", "false"},
+	"byte":   {"byte", "OptByte", "0"},
+	"int":    {"int", "OptInt", "0"},
+	"long":   {"int64", "OptLong", "0"},
+	"float":  {"float32", "OptFloat32", "0"},
+	"double": {"float64", "OptFloat64", "0"},
+	"string": {"string", "OptString", `""`},
+}
+
+// Names of methods that every data class has, and which fields therefore can't use
+var reservedGetters = []string{"Build", "ToBuilder", "ToJson", "Parse", "String"}
+
+var goKeywords = NewSet[string]()
+
+func init() {
+	goKeywords.AddAll(strings.Fields("break case chan const continue default defer else fallthrough for func go goto if " +
+		"import interface map package range return select struct switch type var"))
+}
+
+func (g *goGenerator) pr(text ...string) {
+	for _, t := range text {
+		g.sb.WriteString(t)
+	}
+	g.sb.WriteByte('\n')
+}
+
+func (g *goGenerator) header() {
+	g.pr("package ", g.dat.Package)
+	g.pr()
+	g.pr("import (")
+	g.pr("\t. \"github.com/jpsember/golang-base/base\"")
+	g.pr(")")
+	g.pr()
+}
+
+func (g *goGenerator) separator(title string) {
+	g.pr("// ---------------------------------------------------------------------------------------")
+	g.pr("// ", title)
+	g.pr("// ---------------------------------------------------------------------------------------")
+	g.pr()
+}
+
+// ---------------------------------------------------------------------------------------
+// Enums
+// ---------------------------------------------------------------------------------------
+
+func (g *goGenerator) generateEnum() {
+	n := g.name
+	g.header()
+	g.pr("type ", n, " int")
+	g.pr()
+	g.pr("const (")
+	for i, v := range g.dat.EnumValues {
+		if i == 0 {
+			g.pr("\t", CamelCase(v), " ", n, " = iota")
+		} else {
+			g.pr("\t", CamelCase(v))
+		}
+	}
+	g.pr(")")
+	g.pr()
+	g.pr("var ", n, "EnumInfo = NewEnumInfo(", strconv.Quote(strings.Join(g.dat.EnumValues, " ")), ")")
+	g.pr()
+	g.pr("func (x ", n, ") String() string {")
+	g.pr("\treturn ", n, "EnumInfo.EnumNames[x]")
+	g.pr("}")
+	g.pr()
+	g.pr("// Convenience method to parse an enum value from its name")
+	g.pr("func Parse", n, "(name string) (", n, ", error) {")
+	g.pr("\tid, err := ", n, "EnumInfo.ValueOf(name)")
+	g.pr("\treturn ", n, "(id), err")
+	g.pr("}")
+}
+
+// ---------------------------------------------------------------------------------------
+// Data classes
+// ---------------------------------------------------------------------------------------
+
+func (g *goGenerator) generateClass() error {
+	for _, f := range g.dat.Fields {
+		gf, err := g.describeField(f)
+		if err != nil {
+			return Error("problem with field", Quoted(f.Key)+":", err)
+		}
+		g.fields = append(g.fields, gf)
+	}
+
+	n := g.name
+	s := "s" + n
+	b := n + "Builder"
+
+	g.header()
+	g.pr("type ", s, " struct {")
+	for _, f := range g.fields {
+		g.pr("\t", f.name, " ", f.goType)
+	}
+	g.pr("}")
+	g.pr()
+	g.pr("type ", b, "Obj struct {")
+	g.pr("\t// We embed the static struct")
+	g.pr("\t", s)
+	g.pr("}")
+	g.pr()
+	g.pr("type ", b, " = *", b, "Obj")
+	g.pr()
+	g.separator(n + " interface")
+	g.pr("type ", n, " interface {")
+	g.pr("\tDataClass")
+	for _, f := range g.fields {
+		g.pr("\t", f.getter, "() ", f.goType)
+	}
+	g.pr("\tBuild() ", n)
+	g.pr("\tToBuilder() ", b)
+	g.pr("}")
+	g.pr()
+	g.pr("var Default", n, " = new", n, "()")
+	g.pr()
+	g.pr("// Convenience method to get a fresh builder.")
+	g.pr("func New", n, "() ", b, " {")
+	g.pr("\treturn Default", n, ".ToBuilder()")
+	g.pr("}")
+	g.pr()
+	g.pr("// Construct a new static object, with fields initialized appropriately")
+	g.pr("func new", n, "() ", n, " {")
+	g.pr("\tvar m = ", s, "{}")
+	for _, f := range g.fields {
+		if f.defaultExpr != "" {
+			g.pr("\tm.", f.name, " = ", f.defaultExpr)
+		}
+	}
+	g.pr("\treturn &m")
+	g.pr("}")
+	g.pr()
+	g.separator("Implementation of static (built) object")
+	for _, f := range g.fields {
+		g.pr("func (v *", s, ") ", f.getter, "() ", f.goType, " {")
+		g.pr("\treturn v.", f.name)
+		g.pr("}")
+		g.pr()
+	}
+	g.pr("func (v *", s, ") Build() ", n, " {")
+	g.pr("\t// This is already the immutable (built) version.")
+	g.pr("\treturn v")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v *", s, ") ToBuilder() ", b, " {")
+	g.pr("\treturn &", b, "Obj{", s, ": *v}")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v *", s, ") ToJson() JSEntity {")
+	g.pr("\tvar m = NewJSMap()")
+	for _, f := range g.fields {
+		g.pr("\tm.Put(", f.constant, ", ", f.toJsonExpr(), ")")
+	}
+	g.pr("\treturn m")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v *", s, ") Parse(source JSEntity) DataClass {")
+	g.pr("\tvar s = source.AsJSMap()")
+	g.pr("\tvar n = new", n, "().(*", s, ")")
+	for _, f := range g.fields {
+		f.writeParse(g)
+	}
+	g.pr("\treturn n")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v *", s, ") String() string {")
+	g.pr("\tvar x = v.ToJson().AsJSMap()")
+	g.pr("\treturn PrintJSEntity(x, true)")
+	g.pr("}")
+	g.pr()
+	g.separator("Implementation of builder")
+	for _, f := range g.fields {
+		g.pr("func (v ", b, ") ", f.getter, "() ", f.goType, " {")
+		g.pr("\treturn v.", f.name)
+		g.pr("}")
+		g.pr()
+	}
+	for _, f := range g.fields {
+		g.pr("func (v ", b, ") Set", f.getter, "(", f.name, " ", f.goType, ") ", b, " {")
+		g.pr("\tv.", f.name, " = ", f.name)
+		g.pr("\treturn v")
+		g.pr("}")
+		g.pr()
+	}
+	g.pr("func (v ", b, ") Build() ", n, " {")
+	g.pr("\t// Construct a copy of the embedded static struct")
+	g.pr("\tvar b = v.", s)
+	g.pr("\treturn &b")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v ", b, ") ToBuilder() ", b, " {")
+	g.pr("\treturn v")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v ", b, ") ToJson() JSEntity {")
+	g.pr("\treturn v.Build().ToJson()")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v ", b, ") Parse(source JSEntity) DataClass {")
+	g.pr("\treturn Default", n, ".Parse(source)")
+	g.pr("}")
+	g.pr()
+	g.pr("func (v ", b, ") String() string {")
+	g.pr("\treturn v.Build().String()")
+	g.pr("}")
+	g.pr()
+	for _, f := range g.fields {
+		g.pr("const ", f.constant, " = ", strconv.Quote(f.dat.Key))
+	}
+	g.pr()
+	g.pr("// Convenience method to parse a ", n, " from a JSMap")
+	g.pr("func Parse", n, "(jsmap JSEntity) ", n, " {")
+	g.pr("\tm := jsmap.(JSMap)")
+	g.pr("\treturn Default", n, ".Parse(m).(", n, ")")
+	g.pr("}")
+	return nil
+}
+
+func (g *goGenerator) describeField(f *DatField) (*goField, error) {
+	gf := &goField{dat: f}
+	gf.getter = CamelCase(f.Key)
+	if gf.getter == "" {
+		return nil, Error("bad field name")
+	}
+	for _, r := range reservedGetters {
+		if gf.getter == r {
+			return nil, Error("field name conflicts with method", r)
+		}
+	}
+	gf.name = strings.ToLower(gf.getter[:1]) + gf.getter[1:]
+	if goKeywords.Contains(gf.name) {
+		gf.name += "_"
+	}
+	gf.constant = g.name + "_" + gf.getter
+
+	prim, isPrimitive := datPrimitives[f.TypeName]
+	gf.elemType = Ternary(isPrimitive, prim.goType, f.TypeName)
+	switch {
+	case f.Enum:
+		if f.List || f.Map {
+			return nil, Error("lists or maps of enums are not supported")
+		}
+		gf.kind = kindEnum
+		if f.DefaultText != "" {
+			gf.defaultExpr = CamelCase(f.DefaultText)
+		}
+	case f.TypeName == "byte" && f.List:
+		gf.kind = kindBytes
+		gf.elemType = "[]byte"
+	case f.TypeName == "File":
+		if f.List || f.Map {
+			return nil, Error("lists or maps of Files are not supported")
+		}
+		gf.kind = kindFile
+		gf.elemType = "Path"
+	case isPrimitive:
+		gf.kind = Ternary(f.List, kindPrimitiveList, Ternary(f.Map, kindPrimitiveMap, kindPrimitive))
+		gf.optMethod = prim.optMethod
+	default:
+		if !isExportedName(f.TypeName) {
+			return nil, Error("unsupported type:", f.TypeName)
+		}
+		gf.kind = Ternary(f.List, kindDataClassList, Ternary(f.Map, kindDataClassMap, kindDataClass))
+	}
+
+	switch gf.kind {
+	case kindPrimitiveList, kindDataClassList:
+		gf.goType = "[]" + gf.elemType
+	case kindPrimitiveMap, kindDataClassMap:
+		gf.goType = "map[string]" + gf.elemType
+	default:
+		gf.goType = gf.elemType
+	}
+
+	if f.Default != nil {
+		expr, err := gf.defaultValueExpr(f.Default, prim)
+		if err != nil {
+			return nil, err
+		}
+		gf.defaultExpr = expr
+	} else if gf.kind == kindDataClass {
+		// Data class fields are interfaces, so they must be initialized to something
+		gf.defaultExpr = "Default" + gf.elemType
+	}
+	return gf, nil
+}
+
+func isExportedName(s string) bool {
+	return s != "" && s[0] >= 'A' && s[0] <= 'Z'
+}
+
+// Get a Go expression for a field's default value
+func (f *goField) defaultValueExpr(value JSEntity, prim primitiveInfo) (string, error) {
+	text := f.dat.DefaultText
+	switch f.kind {
+	case kindPrimitive:
+		switch value.(type) {
+		case JString:
+			if f.elemType == "string" {
+				return strconv.Quote(value.AsString()), nil
+			}
+		case JBool:
+			if f.elemType == "bool" {
+				return text, nil
+			}
+		case JInteger, JFloat:
+			if f.elemType != "string" && f.elemType != "bool" {
+				return text, nil
+			}
+		}
+	case kindFile:
+		if s, ok := value.(JString); ok {
+			return "NewPathOrEmptyM(" + strconv.Quote(string(s)) + ")", nil
+		}
+	case kindDataClass:
+		switch value.(type) {
+		case JSList:
+			return "Default" + f.elemType + ".Parse(JSListFromStringM(" + strconv.Quote(value.(JSList).CompactString()) +
+				")).(" + f.elemType + ")", nil
+		case JSMap:
+			return "Default" + f.elemType + ".Parse(JSMapFromStringM(" + strconv.Quote(value.(JSMap).CompactString()) +
+				")).(" + f.elemType + ")", nil
+		}
+	}
+	return "", Error("unsupported default value:", text)
+}
+
+func (f *goField) toJsonExpr() string {
+	v := "v." + f.name
+	switch f.kind {
+	case kindFile, kindEnum:
+		return v + ".String()"
+	case kindBytes:
+		return "JBytes(" + v + ")"
+	case kindDataClass:
+		return v + ".ToJson()"
+	case kindPrimitiveList, kindDataClassList:
+		return "JSListWith(" + v + ")"
+	case kindPrimitiveMap, kindDataClassMap:
+		return "JSMapWith(" + v + ")"
+	}
+	return v
+}
+
+func (f *goField) writeParse(g *goGenerator) {
+	target := "\tn." + f.name + " = "
+	key := f.constant
+	defaultOr := func(zero string) string {
+		return Ternary(f.defaultExpr != "", f.defaultExpr, zero)
+	}
+	switch f.kind {
+	case kindPrimitive:
+		g.pr(target, "s.", f.optMethod, "(", key, ", ", defaultOr(datPrimitives[f.dat.TypeName].zero), ")")
+	case kindFile:
+		defaultText := `""`
+		if f.dat.Default != nil {
+			defaultText = strconv.Quote(f.dat.Default.AsString())
+		}
+		g.pr(target, "NewPathOrEmptyM(s.OptString(", key, ", ", defaultText, "))")
+	case kindBytes:
+		g.pr(target, "s.OptBytes(", key, ", nil)")
+	case kindEnum:
+		g.pr(target, f.elemType, "(ParseEnumFromMap(", f.elemType, "EnumInfo, s, ", key, ", int(", defaultOr("0"), ")))")
+	case kindDataClass:
+		g.pr("\tif x := s.OptAny(", key, "); x != nil {")
+		g.pr("\t", target, "Default", f.elemType, ".Parse(x).(", f.elemType, ")")
+		g.pr("\t}")
+	case kindPrimitiveList:
+		g.pr(target, "ParseListOf[", f.elemType, "](s.OptList(", key, "))")
+	case kindDataClassList:
+		g.pr(target, "ParseDataClassList[", f.elemType, "](s.OptList(", key, "), Default", f.elemType, ")")
+	case kindPrimitiveMap:
+		g.pr(target, "ParseMapOf[", f.elemType, "](s.OptMap(", key, "))")
+	case kindDataClassMap:
+		g.pr(target, "ParseDataClassMap[", f.elemType, "](s.OptMap(", key, "), Default", f.elemType, ")")
+	}
+}
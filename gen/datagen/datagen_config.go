@@ -0,0 +1,193 @@
+package datagen
+
+import (
+	. "github.com/jpsember/golang-base/base"
+)
+
+type sDatagenConfig struct {
+	language   string
+	format     bool
+	sourcePath Path
+	datPath    Path
+	clean      bool
+}
+
+type DatagenConfigBuilderObj struct {
+	// We embed the static struct
+	sDatagenConfig
+}
+
+type DatagenConfigBuilder = *DatagenConfigBuilderObj
+
+// ---------------------------------------------------------------------------------------
+// DatagenConfig interface
+// ---------------------------------------------------------------------------------------
+
+type DatagenConfig interface {
+	DataClass
+	Language() string
+	Format() bool
+	SourcePath() Path
+	DatPath() Path
+	Clean() bool
+	Build() DatagenConfig
+	ToBuilder() DatagenConfigBuilder
+}
+
+var DefaultDatagenConfig = newDatagenConfig()
+
+// Convenience method to get a fresh builder.
+func NewDatagenConfig() DatagenConfigBuilder {
+	return DefaultDatagenConfig.ToBuilder()
+}
+
+// Construct a new static object, with fields initialized appropriately
+func newDatagenConfig() DatagenConfig {
+	var m = sDatagenConfig{}
+	m.language = "go"
+	m.sourcePath = NewPathOrEmptyM("gen")
+	m.datPath = NewPathOrEmptyM("dat_files")
+	return &m
+}
+
+// ---------------------------------------------------------------------------------------
+// Implementation of static (built) object
+// ---------------------------------------------------------------------------------------
+
+func (v *sDatagenConfig) Language() string {
+	return v.language
+}
+
+func (v *sDatagenConfig) Format() bool {
+	return v.format
+}
+
+func (v *sDatagenConfig) SourcePath() Path {
+	return v.sourcePath
+}
+
+func (v *sDatagenConfig) DatPath() Path {
+	return v.datPath
+}
+
+func (v *sDatagenConfig) Clean() bool {
+	return v.clean
+}
+
+func (v *sDatagenConfig) Build() DatagenConfig {
+	// This is already the immutable (built) version.
+	return v
+}
+
+func (v *sDatagenConfig) ToBuilder() DatagenConfigBuilder {
+	return &DatagenConfigBuilderObj{sDatagenConfig: *v}
+}
+
+func (v *sDatagenConfig) ToJson() JSEntity {
+	var m = NewJSMap()
+	m.Put(DatagenConfig_Language, v.language)
+	m.Put(DatagenConfig_Format, v.format)
+	m.Put(DatagenConfig_SourcePath, v.sourcePath.String())
+	m.Put(DatagenConfig_DatPath, v.datPath.String())
+	m.Put(DatagenConfig_Clean, v.clean)
+	return m
+}
+
+func (v *sDatagenConfig) Parse(source JSEntity) DataClass {
+	var s = source.AsJSMap()
+	var n = newDatagenConfig().(*sDatagenConfig)
+	n.language = s.OptString(DatagenConfig_Language, "go")
+	n.format = s.OptBool(DatagenConfig_Format, false)
+	n.sourcePath = NewPathOrEmptyM(s.OptString(DatagenConfig_SourcePath, "gen"))
+	n.datPath = NewPathOrEmptyM(s.OptString(DatagenConfig_DatPath, "dat_files"))
+	n.clean = s.OptBool(DatagenConfig_Clean, false)
+	return n
+}
+
+func (v *sDatagenConfig) String() string {
+	var x = v.ToJson().AsJSMap()
+	return PrintJSEntity(x, true)
+}
+
+// ---------------------------------------------------------------------------------------
+// Implementation of builder
+// ---------------------------------------------------------------------------------------
+
+func (v DatagenConfigBuilder) Language() string {
+	return v.language
+}
+
+func (v DatagenConfigBuilder) Format() bool {
+	return v.format
+}
+
+func (v DatagenConfigBuilder) SourcePath() Path {
+	return v.sourcePath
+}
+
+func (v DatagenConfigBuilder) DatPath() Path {
+	return v.datPath
+}
+
+func (v DatagenConfigBuilder) Clean() bool {
+	return v.clean
+}
+
+func (v DatagenConfigBuilder) SetLanguage(language string) DatagenConfigBuilder {
+	v.language = language
+	return v
+}
+
+func (v DatagenConfigBuilder) SetFormat(format bool) DatagenConfigBuilder {
+	v.format = format
+	return v
+}
+
+func (v DatagenConfigBuilder) SetSourcePath(sourcePath Path) DatagenConfigBuilder {
+	v.sourcePath = sourcePath
+	return v
+}
+
+func (v DatagenConfigBuilder) SetDatPath(datPath Path) DatagenConfigBuilder {
+	v.datPath = datPath
+	return v
+}
+
+func (v DatagenConfigBuilder) SetClean(clean bool) DatagenConfigBuilder {
+	v.clean = clean
+	return v
+}
+
+func (v DatagenConfigBuilder) Build() DatagenConfig {
+	// Construct a copy of the embedded static struct
+	var b = v.sDatagenConfig
+	return &b
+}
+
+func (v DatagenConfigBuilder) ToBuilder() DatagenConfigBuilder {
+	return v
+}
+
+func (v DatagenConfigBuilder) ToJson() JSEntity {
+	return v.Build().ToJson()
+}
+
+func (v DatagenConfigBuilder) Parse(source JSEntity) DataClass {
+	return DefaultDatagenConfig.Parse(source)
+}
+
+func (v DatagenConfigBuilder) String() string {
+	return v.Build().String()
+}
+
+const DatagenConfig_Language = "language"
+const DatagenConfig_Format = "format"
+const DatagenConfig_SourcePath = "source_path"
+const DatagenConfig_DatPath = "dat_path"
+const DatagenConfig_Clean = "clean"
+
+// Convenience method to parse a DatagenConfig from a JSMap
+func ParseDatagenConfig(jsmap JSEntity) DatagenConfig {
+	m := jsmap.(JSMap)
+	return DefaultDatagenConfig.Parse(m).(DatagenConfig)
+}
@@ -0,0 +1,266 @@
+package sample
+
+import (
+	. "github.com/jpsember/golang-base/base"
+)
+
+type sSampleRecord struct {
+	id        int
+	name      string
+	createdMs int64
+	weight    float64
+	active    bool
+	photo     []byte
+	tags      []string
+	location  IPoint
+	counts    map[string]int
+}
+
+type SampleRecordBuilderObj struct {
+	// We embed the static struct
+	sSampleRecord
+}
+
+type SampleRecordBuilder = *SampleRecordBuilderObj
+
+// ---------------------------------------------------------------------------------------
+// SampleRecord interface
+// ---------------------------------------------------------------------------------------
+
+type SampleRecord interface {
+	DataClass
+	Id() int
+	Name() string
+	CreatedMs() int64
+	Weight() float64
+	Active() bool
+	Photo() []byte
+	Tags() []string
+	Location() IPoint
+	Counts() map[string]int
+	Build() SampleRecord
+	ToBuilder() SampleRecordBuilder
+}
+
+var DefaultSampleRecord = newSampleRecord()
+
+// Convenience method to get a fresh builder.
+func NewSampleRecord() SampleRecordBuilder {
+	return DefaultSampleRecord.ToBuilder()
+}
+
+// Construct a new static object, with fields initialized appropriately
+func newSampleRecord() SampleRecord {
+	var m = sSampleRecord{}
+	m.weight = 1.5
+	m.location = DefaultIPoint
+	return &m
+}
+
+// ---------------------------------------------------------------------------------------
+// Implementation of static (built) object
+// ---------------------------------------------------------------------------------------
+
+func (v *sSampleRecord) Id() int {
+	return v.id
+}
+
+func (v *sSampleRecord) Name() string {
+	return v.name
+}
+
+func (v *sSampleRecord) CreatedMs() int64 {
+	return v.createdMs
+}
+
+func (v *sSampleRecord) Weight() float64 {
+	return v.weight
+}
+
+func (v *sSampleRecord) Active() bool {
+	return v.active
+}
+
+func (v *sSampleRecord) Photo() []byte {
+	return v.photo
+}
+
+func (v *sSampleRecord) Tags() []string {
+	return v.tags
+}
+
+func (v *sSampleRecord) Location() IPoint {
+	return v.location
+}
+
+func (v *sSampleRecord) Counts() map[string]int {
+	return v.counts
+}
+
+func (v *sSampleRecord) Build() SampleRecord {
+	// This is already the immutable (built) version.
+	return v
+}
+
+func (v *sSampleRecord) ToBuilder() SampleRecordBuilder {
+	return &SampleRecordBuilderObj{sSampleRecord: *v}
+}
+
+func (v *sSampleRecord) ToJson() JSEntity {
+	var m = NewJSMap()
+	m.Put(SampleRecord_Id, v.id)
+	m.Put(SampleRecord_Name, v.name)
+	m.Put(SampleRecord_CreatedMs, v.createdMs)
+	m.Put(SampleRecord_Weight, v.weight)
+	m.Put(SampleRecord_Active, v.active)
+	m.Put(SampleRecord_Photo, JBytes(v.photo))
+	m.Put(SampleRecord_Tags, JSListWith(v.tags))
+	m.Put(SampleRecord_Location, v.location.ToJson())
+	m.Put(SampleRecord_Counts, JSMapWith(v.counts))
+	return m
+}
+
+func (v *sSampleRecord) Parse(source JSEntity) DataClass {
+	var s = source.AsJSMap()
+	var n = newSampleRecord().(*sSampleRecord)
+	n.id = s.OptInt(SampleRecord_Id, 0)
+	n.name = s.OptString(SampleRecord_Name, "")
+	n.createdMs = s.OptLong(SampleRecord_CreatedMs, 0)
+	n.weight = s.OptFloat64(SampleRecord_Weight, 1.5)
+	n.active = s.OptBool(SampleRecord_Active, false)
+	n.photo = s.OptBytes(SampleRecord_Photo, nil)
+	n.tags = ParseListOf[string](s.OptList(SampleRecord_Tags))
+	if x := s.OptAny(SampleRecord_Location); x != nil {
+		n.location = DefaultIPoint.Parse(x).(IPoint)
+	}
+	n.counts = ParseMapOf[int](s.OptMap(SampleRecord_Counts))
+	return n
+}
+
+func (v *sSampleRecord) String() string {
+	var x = v.ToJson().AsJSMap()
+	return PrintJSEntity(x, true)
+}
+
+// ---------------------------------------------------------------------------------------
+// Implementation of builder
+// ---------------------------------------------------------------------------------------
+
+func (v SampleRecordBuilder) Id() int {
+	return v.id
+}
+
+func (v SampleRecordBuilder) Name() string {
+	return v.name
+}
+
+func (v SampleRecordBuilder) CreatedMs() int64 {
+	return v.createdMs
+}
+
+func (v SampleRecordBuilder) Weight() float64 {
+	return v.weight
+}
+
+func (v SampleRecordBuilder) Active() bool {
+	return v.active
+}
+
+func (v SampleRecordBuilder) Photo() []byte {
+	return v.photo
+}
+
+func (v SampleRecordBuilder) Tags() []string {
+	return v.tags
+}
+
+func (v SampleRecordBuilder) Location() IPoint {
+	return v.location
+}
+
+func (v SampleRecordBuilder) Counts() map[string]int {
+	return v.counts
+}
+
+func (v SampleRecordBuilder) SetId(id int) SampleRecordBuilder {
+	v.id = id
+	return v
+}
+
+func (v SampleRecordBuilder) SetName(name string) SampleRecordBuilder {
+	v.name = name
+	return v
+}
+
+func (v SampleRecordBuilder) SetCreatedMs(createdMs int64) SampleRecordBuilder {
+	v.createdMs = createdMs
+	return v
+}
+
+func (v SampleRecordBuilder) SetWeight(weight float64) SampleRecordBuilder {
+	v.weight = weight
+	return v
+}
+
+func (v SampleRecordBuilder) SetActive(active bool) SampleRecordBuilder {
+	v.active = active
+	return v
+}
+
+func (v SampleRecordBuilder) SetPhoto(photo []byte) SampleRecordBuilder {
+	v.photo = photo
+	return v
+}
+
+func (v SampleRecordBuilder) SetTags(tags []string) SampleRecordBuilder {
+	v.tags = tags
+	return v
+}
+
+func (v SampleRecordBuilder) SetLocation(location IPoint) SampleRecordBuilder {
+	v.location = location
+	return v
+}
+
+func (v SampleRecordBuilder) SetCounts(counts map[string]int) SampleRecordBuilder {
+	v.counts = counts
+	return v
+}
+
+func (v SampleRecordBuilder) Build() SampleRecord {
+	// Construct a copy of the embedded static struct
+	var b = v.sSampleRecord
+	return &b
+}
+
+func (v SampleRecordBuilder) ToBuilder() SampleRecordBuilder {
+	return v
+}
+
+func (v SampleRecordBuilder) ToJson() JSEntity {
+	return v.Build().ToJson()
+}
+
+func (v SampleRecordBuilder) Parse(source JSEntity) DataClass {
+	return DefaultSampleRecord.Parse(source)
+}
+
+func (v SampleRecordBuilder) String() string {
+	return v.Build().String()
+}
+
+const SampleRecord_Id = "id"
+const SampleRecord_Name = "name"
+const SampleRecord_CreatedMs = "created_ms"
+const SampleRecord_Weight = "weight"
+const SampleRecord_Active = "active"
+const SampleRecord_Photo = "photo"
+const SampleRecord_Tags = "tags"
+const SampleRecord_Location = "location"
+const SampleRecord_Counts = "counts"
+
+// Convenience method to parse a SampleRecord from a JSMap
+func ParseSampleRecord(jsmap JSEntity) SampleRecord {
+	m := jsmap.(JSMap)
+	return DefaultSampleRecord.Parse(m).(SampleRecord)
+}
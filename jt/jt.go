@@ -171,7 +171,7 @@ func (j JTest) AssertMessage(message ...any) {
 var hasher = fnv.New32a()
 
 func HashOfJSMap(jsonMap *JSMapStruct) int32 {
-	return HashOfString(jsonMap.CompactString())
+	return HashOfString(CanonicalJSON(jsonMap))
 }
 
 func HashOfString(str string) int32 {
@@ -0,0 +1,438 @@
+package sqlite
+
+import (
+	"database/sql"
+	. "github.com/jpsember/golang-base/base"
+	"strings"
+)
+
+// Maps a DataClass to a table in an SQLite database.
+//
+// The table's columns are derived from the json of a prototype instance (e.g. DefaultAnimal): each
+// top-level key becomes a column, with its type determined by the key's value:
+//
+//	integer       INTEGER
+//	float         REAL
+//	bool          INTEGER (0 or 1)
+//	string        TEXT
+//	byte array    BLOB
+//	map or list   TEXT (holding json)
+//
+// One column (by default, "id", if there is one) can be the primary key; if it is an integer, rows
+// inserted with a zero key are assigned a new one.  The database driver must be registered by the
+// client, e.g. by importing github.com/mattn/go-sqlite3.
+type Table[T DataClass] struct {
+	db         Executor
+	name       string
+	parser     DataClass
+	columns    []*column
+	primaryKey *column
+	indexes    [][]string
+	unique     []bool
+}
+
+// The methods of sql.DB and sql.Tx used by a Table
+type Executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+var RowNotFoundError = Error("row not found")
+
+type columnKind int
+
+const (
+	kindInteger columnKind = iota
+	kindReal
+	kindBool
+	kindText
+	kindBlob
+	kindJson
+)
+
+var columnSqlTypes = []string{"INTEGER", "REAL", "INTEGER", "TEXT", "BLOB", "TEXT"}
+
+type column struct {
+	name string
+	kind columnKind
+}
+
+// Construct a table for a DataClass, using a prototype (e.g. DefaultAnimal) to determine its columns
+func NewTable[T DataClass](db Executor, name string, prototype T) *Table[T] {
+	t := &Table[T]{db: db, name: name, parser: prototype}
+	m := prototype.ToJson().AsJSMap()
+	for _, key := range m.OrderedKeys() {
+		kind, ok := columnKindOf(m.OptAny(key))
+		CheckArg(ok, "can't determine column type for:", key)
+		t.columns = append(t.columns, &column{name: key, kind: kind})
+	}
+	if m.HasKey("id") {
+		t.WithPrimaryKey("id")
+	}
+	return t
+}
+
+func columnKindOf(e JSEntity) (columnKind, bool) {
+	switch e.(type) {
+	case JInteger:
+		return kindInteger, true
+	case JFloat:
+		return kindReal, true
+	case JBool:
+		return kindBool, true
+	case JString, JNumber:
+		return kindText, true
+	case JBytes:
+		return kindBlob, true
+	case JSMap, JSList:
+		return kindJson, true
+	}
+	return 0, false
+}
+
+// Use a field other than "id" as the primary key
+func (t *Table[T]) WithPrimaryKey(field string) *Table[T] {
+	t.primaryKey = t.column(field)
+	return t
+}
+
+// Add an index on one or more fields
+func (t *Table[T]) WithIndex(fields ...string) *Table[T] {
+	return t.addIndex(false, fields)
+}
+
+// Add a unique index on one or more fields
+func (t *Table[T]) WithUniqueIndex(fields ...string) *Table[T] {
+	return t.addIndex(true, fields)
+}
+
+func (t *Table[T]) addIndex(unique bool, fields []string) *Table[T] {
+	CheckArg(len(fields) != 0, "no fields given for index")
+	for _, f := range fields {
+		t.column(f)
+	}
+	t.indexes = append(t.indexes, fields)
+	t.unique = append(t.unique, unique)
+	return t
+}
+
+// Get a copy of the table that performs its operations using a different executor, e.g. a transaction
+func (t *Table[T]) Using(db Executor) *Table[T] {
+	c := *t
+	c.db = db
+	return &c
+}
+
+func (t *Table[T]) Name() string {
+	return t.name
+}
+
+func (t *Table[T]) column(name string) *column {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c
+		}
+	}
+	BadArg("no such field:", Quoted(name), "in table", t.name)
+	return nil
+}
+
+func (t *Table[T]) autoIncrement() bool {
+	return t.primaryKey != nil && t.primaryKey.kind == kindInteger
+}
+
+func (t *Table[T]) assertPrimaryKey() {
+	CheckState(t.primaryKey != nil, "table", t.name, "has no primary key")
+}
+
+// Get the statements that create the table and its indexes, if they don't already exist
+func (t *Table[T]) CreateDDL() []string {
+	sb := strings.Builder{}
+	sb.WriteString("CREATE TABLE IF NOT EXISTS " + quoteIdentifier(t.name) + " (")
+	for i, c := range t.columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdentifier(c.name) + " " + columnSqlTypes[c.kind])
+		if c == t.primaryKey {
+			sb.WriteString(" PRIMARY KEY")
+			if t.autoIncrement() {
+				sb.WriteString(" AUTOINCREMENT")
+			}
+		} else {
+			sb.WriteString(" NOT NULL")
+		}
+	}
+	sb.WriteString(")")
+	result := []string{sb.String()}
+
+	for i, fields := range t.indexes {
+		var quoted []string
+		for _, f := range fields {
+			quoted = append(quoted, quoteIdentifier(f))
+		}
+		result = append(result, "CREATE "+Ternary(t.unique[i], "UNIQUE ", "")+"INDEX IF NOT EXISTS "+
+			quoteIdentifier(t.name+"_"+strings.Join(fields, "_"))+" ON "+quoteIdentifier(t.name)+
+			" ("+strings.Join(quoted, ", ")+")")
+	}
+	return result
+}
+
+// Create the table and its indexes, if they don't already exist
+func (t *Table[T]) Create() error {
+	for _, stmt := range t.CreateDDL() {
+		if _, err := t.db.Exec(stmt); err != nil {
+			return Error("problem creating table", t.name+":", INDENT, err)
+		}
+	}
+	return nil
+}
+
+func (t *Table[T]) CreateM() *Table[T] {
+	CheckOk(t.Create())
+	return t
+}
+
+// Insert a value.  If the primary key is an integer and the value's key is zero, it is assigned a new key;
+// returns the value as stored (with its new key, if one was assigned).
+func (t *Table[T]) Insert(value T) (T, error) {
+	m := value.ToJson().AsJSMap()
+	var names, placeholders []string
+	var args []any
+	assignKey := t.autoIncrement() && m.OptLong(t.primaryKey.name, 0) == 0
+	for _, c := range t.columns {
+		if assignKey && c == t.primaryKey {
+			continue
+		}
+		names = append(names, quoteIdentifier(c.name))
+		placeholders = append(placeholders, "?")
+		args = append(args, c.toSql(m))
+	}
+	result, err := t.db.Exec("INSERT INTO "+quoteIdentifier(t.name)+" ("+strings.Join(names, ", ")+
+		") VALUES ("+strings.Join(placeholders, ", ")+")", args...)
+	if err == nil && assignKey {
+		var id int64
+		id, err = result.LastInsertId()
+		if err == nil {
+			m = DeepCopyJSEntity(m).AsJSMap().Put(t.primaryKey.name, id)
+			err = t.parse(m, &value)
+		}
+	}
+	if err != nil {
+		return value, Error("problem inserting into", t.name+":", INDENT, err)
+	}
+	return value, nil
+}
+
+func (t *Table[T]) InsertM(value T) T {
+	return CheckOkWith(t.Insert(value))
+}
+
+// Update the row with the same primary key as a value; returns RowNotFoundError if there is no such row
+func (t *Table[T]) Update(value T) error {
+	t.assertPrimaryKey()
+	m := value.ToJson().AsJSMap()
+	var assignments []string
+	var args []any
+	for _, c := range t.columns {
+		if c != t.primaryKey {
+			assignments = append(assignments, quoteIdentifier(c.name)+" = ?")
+			args = append(args, c.toSql(m))
+		}
+	}
+	args = append(args, t.primaryKey.toSql(m))
+	result, err := t.db.Exec("UPDATE "+quoteIdentifier(t.name)+" SET "+strings.Join(assignments, ", ")+
+		" WHERE "+quoteIdentifier(t.primaryKey.name)+" = ?", args...)
+	return t.checkRowAffected(result, err, "updating")
+}
+
+func (t *Table[T]) UpdateM(value T) {
+	CheckOk(t.Update(value))
+}
+
+// Delete the row with a particular primary key; returns RowNotFoundError if there is no such row
+func (t *Table[T]) Delete(key any) error {
+	t.assertPrimaryKey()
+	result, err := t.db.Exec("DELETE FROM "+quoteIdentifier(t.name)+" WHERE "+quoteIdentifier(t.primaryKey.name)+" = ?", key)
+	return t.checkRowAffected(result, err, "deleting from")
+}
+
+func (t *Table[T]) DeleteM(key any) {
+	CheckOk(t.Delete(key))
+}
+
+func (t *Table[T]) checkRowAffected(result sql.Result, err error, action string) error {
+	if err == nil {
+		var count int64
+		count, err = result.RowsAffected()
+		if err == nil && count == 0 {
+			return RowNotFoundError
+		}
+	}
+	if err != nil {
+		return Error("problem "+action, t.name+":", INDENT, err)
+	}
+	return nil
+}
+
+// Read the row with a particular primary key; returns RowNotFoundError if there is no such row
+func (t *Table[T]) Read(key any) (T, error) {
+	t.assertPrimaryKey()
+	results, err := t.Query(quoteIdentifier(t.primaryKey.name)+" = ?", key)
+	var result T
+	if err == nil {
+		if len(results) == 0 {
+			return result, RowNotFoundError
+		}
+		result = results[0]
+	}
+	return result, err
+}
+
+func (t *Table[T]) ReadM(key any) T {
+	return CheckOkWith(t.Read(key))
+}
+
+// Read the rows satisfying an SQL condition (e.g. "name = ? ORDER BY id"), or all rows if the condition is empty
+func (t *Table[T]) Query(condition string, args ...any) ([]T, error) {
+	var names []string
+	for _, c := range t.columns {
+		names = append(names, quoteIdentifier(c.name))
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + quoteIdentifier(t.name)
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	rows, err := t.db.Query(query, args...)
+	if err != nil {
+		return nil, Error("problem querying", t.name+":", INDENT, err)
+	}
+	defer rows.Close()
+
+	var results []T
+	values := make([]any, len(t.columns))
+	pointers := make([]any, len(t.columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			break
+		}
+		m := NewJSMap()
+		for i, c := range t.columns {
+			var e JSEntity
+			if e, err = c.fromSql(values[i]); err != nil {
+				break
+			}
+			if e != nil {
+				m.Put(c.name, e)
+			}
+		}
+		var value T
+		if err == nil {
+			err = t.parse(m, &value)
+		}
+		if err != nil {
+			break
+		}
+		results = append(results, value)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return nil, Error("problem reading from", t.name+":", INDENT, err)
+	}
+	return results, nil
+}
+
+func (t *Table[T]) QueryM(condition string, args ...any) []T {
+	return CheckOkWith(t.Query(condition, args...))
+}
+
+// Parse a row's json into a value, converting any panic to an error
+func (t *Table[T]) parse(m JSMap, value *T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error("can't parse row:", r)
+		}
+	}()
+	*value = t.parser.Parse(m).(T)
+	return nil
+}
+
+// Get the value to store in a column from a DataClass's json
+func (c *column) toSql(m JSMap) any {
+	e := m.OptAny(c.name)
+	if e == nil {
+		return nil
+	}
+	switch c.kind {
+	case kindInteger:
+		return e.AsInteger()
+	case kindReal:
+		return e.AsFloat()
+	case kindBool:
+		return Ternary(e.AsBool(), 1, 0)
+	case kindBlob:
+		// A nil slice would be stored as NULL
+		return append([]byte{}, DecodeBase64Maybe(e)...)
+	case kindJson:
+		return PrintJSEntity(e, false)
+	}
+	return e.AsString()
+}
+
+// Convert a value read from a column to json; returns nil if the value is NULL
+func (c *column) fromSql(value any) (JSEntity, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch c.kind {
+	case kindInteger, kindBool:
+		n, ok := value.(int64)
+		if !ok {
+			break
+		}
+		if c.kind == kindBool {
+			return JBool(n != 0), nil
+		}
+		return JInteger(n), nil
+	case kindReal:
+		switch v := value.(type) {
+		case float64:
+			return JFloat(v), nil
+		case int64:
+			return JFloat(v), nil
+		}
+	case kindBlob:
+		if b, ok := value.([]byte); ok {
+			return JBytes(b), nil
+		}
+	default:
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case []byte:
+			text = string(v)
+		default:
+			return nil, Error("unexpected value for column", c.name+":", Info(value))
+		}
+		if c.kind == kindText {
+			return JString(text), nil
+		}
+		list, err := JSListFromString("[" + text + "]")
+		if err != nil || list.Length() != 1 {
+			return nil, Error("bad json in column", c.name+":", Truncated(text))
+		}
+		return list.Get(0), nil
+	}
+	return nil, Error("unexpected value for column", c.name+":", Info(value))
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,115 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	. "github.com/jpsember/golang-base/base"
+	. "github.com/jpsember/golang-base/gen/sample"
+	"github.com/jpsember/golang-base/jt"
+	. "github.com/jpsember/golang-base/sqlite"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"testing"
+)
+
+func openDatabase() *sql.DB {
+	db := CheckOkWith(sql.Open("sqlite3", ":memory:"))
+	// Each connection to an in-memory database gets its own database
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func openTable(db *sql.DB) *Table[SampleRecord] {
+	return NewTable(db, "record", DefaultSampleRecord).WithUniqueIndex("name").WithIndex("active", "created_ms").CreateM()
+}
+
+func sampleRecord(name string) SampleRecordBuilder {
+	return NewSampleRecord().SetName(name).SetCreatedMs(1700000000123).SetActive(true).
+		SetPhoto([]byte{0, 1, 2, 255}).SetTags([]string{"a", "b"}).SetLocation(IPointWith(3, -4)).
+		SetCounts(map[string]int{"x": 7})
+}
+
+func TestTableDDL(t *testing.T) {
+	j := jt.New(t)
+	table := NewTable[SampleRecord](nil, "record", DefaultSampleRecord).WithUniqueIndex("name").WithIndex("active", "created_ms")
+	j.AssertMessage(strings.Join(table.CreateDDL(), ";\n"))
+}
+
+func TestTableInsertRead(t *testing.T) {
+	j := jt.New(t)
+	table := openTable(openDatabase())
+	a := table.InsertM(sampleRecord("alpha").Build())
+	b := table.InsertM(sampleRecord("beta").SetWeight(2.25).Build())
+	j.AssertEqual(a.Id(), 1)
+	j.AssertEqual(b.Id(), 2)
+
+	r := table.ReadM(1)
+	j.AssertTrue(DataClassesEqual(r, a))
+	j.AssertEqual(r.Photo(), []byte{0, 1, 2, 255})
+	j.AssertEqual(r.Location(), IPointWith(3, -4))
+	j.AssertEqual(r.Counts()["x"], 7)
+	j.AssertEqual(table.ReadM(2).Weight(), 2.25)
+
+	_, err := table.Read(3)
+	j.AssertEqual(err, RowNotFoundError)
+
+	// Explicit keys are used as given
+	c := table.InsertM(sampleRecord("gamma").SetId(10).Build())
+	j.AssertEqual(c.Id(), 10)
+
+	// Default values (e.g. nil lists and byte arrays) can be stored
+	d := table.InsertM(NewSampleRecord().SetName("delta").Build())
+	j.AssertEqual(len(table.ReadM(d.Id()).Photo()), 0)
+
+	// The unique index is enforced
+	_, err = table.Insert(sampleRecord("alpha").Build())
+	j.AssertTrue(err != nil)
+}
+
+func TestTableUpdateDelete(t *testing.T) {
+	j := jt.New(t)
+	table := openTable(openDatabase())
+	a := table.InsertM(sampleRecord("alpha").Build())
+	table.UpdateM(a.ToBuilder().SetName("omega").SetActive(false).Build())
+	r := table.ReadM(a.Id())
+	j.AssertEqual(r.Name(), "omega")
+	j.AssertFalse(r.Active())
+
+	j.AssertEqual(table.Update(a.ToBuilder().SetId(99).Build()), RowNotFoundError)
+	table.DeleteM(a.Id())
+	j.AssertEqual(table.Delete(a.Id()), RowNotFoundError)
+	j.AssertEqual(len(table.QueryM("")), 0)
+}
+
+func TestTableQuery(t *testing.T) {
+	j := jt.New(t)
+	table := openTable(openDatabase())
+	for i, name := range []string{"d", "b", "a", "c"} {
+		table.InsertM(sampleRecord(name).SetActive(i%2 == 0).Build())
+	}
+	results := table.QueryM("active = ? ORDER BY name", true)
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name())
+	}
+	j.AssertEqual(strings.Join(names, " "), "a d")
+	j.AssertEqual(len(table.QueryM("")), 4)
+
+	_, err := table.Query("no_such_column = 1")
+	j.AssertTrue(err != nil)
+}
+
+func TestTableTransaction(t *testing.T) {
+	j := jt.New(t)
+	db := openDatabase()
+	table := openTable(db)
+
+	tx := CheckOkWith(db.Begin())
+	table.Using(tx).InsertM(sampleRecord("alpha").Build())
+	CheckOk(tx.Rollback())
+	j.AssertEqual(len(table.QueryM("")), 0)
+
+	tx = CheckOkWith(db.Begin())
+	table.Using(tx).InsertM(sampleRecord("beta").Build())
+	CheckOk(tx.Commit())
+	j.AssertEqual(len(table.QueryM("")), 1)
+}
@@ -16,6 +16,55 @@ type BlobHelper interface {
 	ReadBlobWithName(name string) (AbstractBlob, error)
 }
 
+// A blob whose content is held in a BlobStore; e.g. a database row might hold the blob's id, name, and
+// the hash of its content
+type BlobRef struct {
+	Id   int
+	Name string
+	Hash BlobHash
+}
+
+type storedBlob struct {
+	ref  BlobRef
+	data []byte
+}
+
+func (b storedBlob) Id() int      { return b.ref.Id }
+func (b storedBlob) Name() string { return b.ref.Name }
+func (b storedBlob) Data() []byte { return b.data }
+
+type blobStoreHelper struct {
+	store  BlobStore
+	byId   func(id int) (BlobRef, error)
+	byName func(name string) (BlobRef, error)
+}
+
+// Construct a BlobHelper that finds blobs using lookup functions, and reads their content from a BlobStore.
+// If a blob isn't found, the helper returns an error and a blob with id zero.
+func NewBlobStoreHelper(store BlobStore, byId func(id int) (BlobRef, error),
+	byName func(name string) (BlobRef, error)) BlobHelper {
+	return blobStoreHelper{store: store, byId: byId, byName: byName}
+}
+
+func (h blobStoreHelper) ReadBlob(id int) (AbstractBlob, error) {
+	return h.read(h.byId(id))
+}
+
+func (h blobStoreHelper) ReadBlobWithName(name string) (AbstractBlob, error) {
+	return h.read(h.byName(name))
+}
+
+func (h blobStoreHelper) read(ref BlobRef, err error) (AbstractBlob, error) {
+	var data []byte
+	if err == nil {
+		data, err = h.store.Get(ref.Hash)
+	}
+	if err != nil {
+		return storedBlob{}, err
+	}
+	return storedBlob{ref: ref, data: data}, nil
+}
+
 type blobName = string
 type blobData = AbstractBlob
 
@@ -80,11 +80,27 @@ func (s *FileSystemSessionManager) flush() {
 	if s.modified {
 		pth := s.getPath()
 		Pr("writing session map to path:", pth)
+
+		// Other processes may be sharing the session map, so hold its lock while reading and rewriting it
+		// (if it can't be locked, write it anyway, as we did before there were locks)
+		lock, err := pth.Lock()
+		if err != nil {
+			Pr("*** unable to lock session map:", INDENT, err)
+		} else {
+			defer lock.Unlock()
+		}
+
+		// Retain any sessions that other processes have written
 		jsm := NewJSMap()
+		if pth.Exists() {
+			if existing, err := JSMapFromFile(pth); err == nil {
+				jsm = existing
+			}
+		}
 		for k, v := range s.sessionMap {
 			jsm.Put(k, v.ToJson())
 		}
-		pth.WriteStringM(jsm.CompactString())
+		CheckOk(pth.WriteStringAtomic(jsm.CompactString()))
 		s.lastWrittenMs = CurrentTimeMs()
 		Pr("flushed modified session map to:", pth)
 		s.modified = false
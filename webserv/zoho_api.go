@@ -175,7 +175,7 @@ func (z Zoho) flushConfig() {
 		pr := PrIf("flushConfig", true)
 		z.modified = false
 		f := z.cacheFile()
-		f.WriteStringM(z.config.String())
+		f.WriteStringAtomicM(z.config.String())
 		pr("flushed:", INDENT, z.config)
 	}
 }